@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileID is unavailable here; rotation is then detected by truncation only.
+func fileID(fi os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,243 @@
+// Command agent tails local log files and forwards error entries to the
+// error-logs API, for services that only write logs to disk.
+//
+// Configuration comes from AGENT_* environment variables (see
+// config.LoadAgent); AGENT_FILES takes a comma-separated list of globs.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"error-logs/internal/config"
+	"error-logs/internal/models"
+	"error-logs/internal/spool"
+	"error-logs/pkg/client"
+)
+
+const (
+	pollInterval     = time.Second
+	discoverInterval = 10 * time.Second
+	multilineTimeout = 2 * time.Second
+	batchSize        = 100
+	batchInterval    = 5 * time.Second
+)
+
+type agent struct {
+	cfg      *config.AgentConfig
+	include  *regexp.Regexp
+	minRank  int
+	hostname string
+
+	tailers    map[string]*tailer
+	assemblers map[string]*assembler
+	state      *stateStore
+	spool      *spool.Spool
+
+	pending        []*models.CreateErrorRequest
+	pendingOffsets map[string]fileOffset
+	lastFlush      time.Time
+	started        bool
+}
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system environment variables")
+	}
+
+	cfg := config.LoadAgent()
+	if len(cfg.Files) == 0 {
+		log.Fatal("AGENT_FILES is required")
+	}
+	if cfg.APIKey == "" {
+		log.Fatal("AGENT_API_KEY is required")
+	}
+
+	if err := os.MkdirAll(cfg.StateDir, 0o700); err != nil {
+		log.Fatalf("Failed to create state directory: %v", err)
+	}
+	state, err := loadState(filepath.Join(cfg.StateDir, "offsets.json"))
+	if err != nil {
+		log.Fatalf("Failed to load state: %v", err)
+	}
+	sp, err := spool.New(filepath.Join(cfg.StateDir, "spool"), cfg.SpoolMaxMB)
+	if err != nil {
+		log.Fatalf("Failed to open spool: %v", err)
+	}
+
+	c, err := client.New(client.Options{
+		Endpoint:    cfg.Endpoint,
+		APIKey:      cfg.APIKey,
+		Source:      cfg.Source,
+		Environment: cfg.Environment,
+		BatchSize:   batchSize,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create client: %v", err)
+	}
+
+	a := &agent{
+		cfg:            cfg,
+		minRank:        levelRank[cfg.MinLevel],
+		tailers:        make(map[string]*tailer),
+		assemblers:     make(map[string]*assembler),
+		state:          state,
+		spool:          sp,
+		pendingOffsets: make(map[string]fileOffset),
+		lastFlush:      time.Now(),
+	}
+	a.hostname, _ = os.Hostname()
+	if cfg.Include != "" {
+		if a.include, err = regexp.Compile(cfg.Include); err != nil {
+			log.Fatalf("Invalid AGENT_INCLUDE pattern: %v", err)
+		}
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	sendCtx, stopSending := context.WithCancel(context.Background())
+	sent := make(chan struct{})
+	go func() {
+		sp.Run(sendCtx, c)
+		close(sent)
+	}()
+
+	log.Printf("Agent started, watching %v", cfg.Files)
+	a.run(ctx)
+
+	log.Println("Shutting down agent...")
+	a.shutdown()
+
+	// Give the sender a moment to ship what was just spooled; anything left
+	// stays on disk for the next start.
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline) && sp.Len() > 0; {
+		time.Sleep(100 * time.Millisecond)
+	}
+	stopSending()
+	<-sent
+	c.Close(context.Background())
+}
+
+func (a *agent) run(ctx context.Context) {
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	var lastDiscover time.Time
+
+	for {
+		now := time.Now()
+		if now.Sub(lastDiscover) >= discoverInterval {
+			a.discover()
+			lastDiscover = now
+		}
+
+		for path, t := range a.tailers {
+			asm := a.assemblers[path]
+			if err := t.poll(func(l line) { a.handle(asm.add(l, now)) }); err != nil {
+				log.Printf("Failed to read %s: %v", path, err)
+			}
+			a.handle(asm.flushIdle(now, multilineTimeout))
+		}
+
+		if len(a.pending) >= batchSize || now.Sub(a.lastFlush) >= batchInterval {
+			a.flush()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		}
+	}
+}
+
+func (a *agent) discover() {
+	for _, pattern := range a.cfg.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Printf("Invalid file pattern %q: %v", pattern, err)
+			continue
+		}
+		for _, path := range matches {
+			if _, ok := a.tailers[path]; ok {
+				continue
+			}
+			// Files present at startup honour AGENT_START_AT; files that
+			// appear later are new and read from the beginning.
+			startAtEnd := !a.started && a.cfg.StartAt == "end"
+			t, err := openTailer(path, a.state.get(path), startAtEnd)
+			if err != nil {
+				log.Printf("Failed to open %s: %v", path, err)
+				continue
+			}
+			log.Printf("Tailing %s from offset %d", path, t.offset)
+			a.tailers[path] = t
+			a.assemblers[path] = &assembler{path: path}
+		}
+	}
+	a.started = true
+}
+
+func (a *agent) handle(e *entry) {
+	if e == nil {
+		return
+	}
+	a.pendingOffsets[e.path] = fileOffset{ID: e.id, Offset: e.offset}
+	if len(e.lines) == 0 {
+		return
+	}
+
+	event := parseEntry(e)
+	if levelRank[event.Level] < a.minRank {
+		return
+	}
+	if a.include != nil && !a.include.MatchString(event.Message) {
+		return
+	}
+
+	event.Context["file"] = e.path
+	if a.hostname != "" {
+		event.Context["host"] = a.hostname
+	}
+	if a.cfg.Source == "" {
+		event.Source = filepath.Base(e.path)
+	}
+	a.pending = append(a.pending, event)
+}
+
+// flush spools pending events and only then records the new offsets, so a
+// crash can duplicate entries but never lose them.
+func (a *agent) flush() {
+	a.lastFlush = time.Now()
+	if len(a.pending) > 0 {
+		if err := a.spool.Write(a.pending); err != nil {
+			log.Printf("Failed to spool %d events: %v", len(a.pending), err)
+			return
+		}
+		a.pending = a.pending[:0]
+	}
+
+	for path, off := range a.pendingOffsets {
+		a.state.set(path, off)
+	}
+	clear(a.pendingOffsets)
+	if err := a.state.save(); err != nil {
+		log.Printf("Failed to save offsets: %v", err)
+	}
+}
+
+func (a *agent) shutdown() {
+	for path, asm := range a.assemblers {
+		a.handle(asm.flushIdle(time.Now(), 0))
+		a.tailers[path].Close()
+	}
+	a.flush()
+}
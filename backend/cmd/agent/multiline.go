@@ -0,0 +1,125 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+type entry struct {
+	path   string
+	lines  []string
+	offset int64
+	id     uint64
+	panic  bool
+}
+
+// pythonTraceback heads the output of an uncaught Python exception.
+const pythonTraceback = "Traceback (most recent call last):"
+
+const (
+	modeNone = iota
+	modeGoPanic
+	modePython
+)
+
+var (
+	goroutineHeader = regexp.MustCompile(`^goroutine \d+ \[`)
+	javaMore        = regexp.MustCompile(`^\.\.\. \d+ (more|common frames omitted)`)
+	// recordStart matches lines that clearly begin a new log record, which
+	// ends Go panic output since its frames are not indented.
+	recordStart = regexp.MustCompile(`^(\{|\d{4}[-/]\d{2}[-/]\d{2}|[A-Z][a-z]{2} [ \d]\d \d{2}:|\[?(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL)\b)`)
+)
+
+// assembler joins continuation lines (stack frames, "Caused by:", Python
+// tracebacks and Go panics) onto the record they belong to.
+type assembler struct {
+	path      string
+	current   *entry
+	mode      int
+	sawIndent bool
+	lastLine  time.Time
+}
+
+// add consumes a line and returns the previous entry if this line starts a
+// new one.
+func (a *assembler) add(l line, now time.Time) *entry {
+	a.lastLine = now
+
+	if a.current != nil && a.isContinuation(l.text) {
+		a.current.lines = append(a.current.lines, l.text)
+		a.current.offset = l.offset
+		return nil
+	}
+
+	done := a.current
+	a.current = nil
+	a.mode = modeNone
+
+	if strings.TrimSpace(l.text) == "" {
+		// Blank lines outside a stack trace carry nothing; still advance the
+		// offset so they are not re-read after a restart.
+		if done != nil {
+			return done
+		}
+		return &entry{path: a.path, offset: l.offset, id: l.id}
+	}
+
+	a.current = &entry{path: a.path, lines: []string{l.text}, offset: l.offset, id: l.id}
+	switch {
+	case strings.HasPrefix(l.text, "panic: "), strings.HasPrefix(l.text, "fatal error: "):
+		a.mode = modeGoPanic
+		a.current.panic = true
+	case strings.HasPrefix(l.text, pythonTraceback):
+		// Python writes uncaught exceptions without a log line before them
+		a.mode = modePython
+		a.sawIndent = false
+	}
+	return done
+}
+
+// flushIdle returns the pending entry once no line has arrived for timeout,
+// since the last record of a burst has nothing after it to terminate it.
+func (a *assembler) flushIdle(now time.Time, timeout time.Duration) *entry {
+	if a.current == nil || now.Sub(a.lastLine) < timeout {
+		return nil
+	}
+	done := a.current
+	a.current = nil
+	a.mode = modeNone
+	return done
+}
+
+func (a *assembler) isContinuation(text string) bool {
+	switch a.mode {
+	case modeGoPanic:
+		return !recordStart.MatchString(text)
+	case modePython:
+		if isIndented(text) {
+			a.sawIndent = true
+			return true
+		}
+		// The unindented "SomeError: message" line closes the traceback.
+		a.mode = modeNone
+		return a.sawIndent && strings.TrimSpace(text) != ""
+	}
+
+	switch {
+	case isIndented(text):
+		return true
+	case strings.HasPrefix(text, "Caused by:"), javaMore.MatchString(text):
+		return true
+	case strings.HasPrefix(text, pythonTraceback):
+		a.mode = modePython
+		a.sawIndent = false
+		return true
+	case goroutineHeader.MatchString(text):
+		a.mode = modeGoPanic
+		return true
+	}
+	return false
+}
+
+func isIndented(text string) bool {
+	return len(text) > 1 && (text[0] == ' ' || text[0] == '\t') && strings.TrimSpace(text) != ""
+}
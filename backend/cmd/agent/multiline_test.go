@@ -0,0 +1,164 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// assemble feeds lines through an assembler and returns the lines of every
+// entry it produces, flushing the last one as if the file went idle.
+func assemble(lines ...string) [][]string {
+	a := &assembler{path: "app.log"}
+	now := time.Now()
+	var got [][]string
+	for i, text := range lines {
+		if e := a.add(line{text: text, offset: int64(i + 1)}, now); e != nil {
+			got = append(got, e.lines)
+		}
+	}
+	if e := a.flushIdle(now.Add(time.Minute), time.Second); e != nil {
+		got = append(got, e.lines)
+	}
+	return got
+}
+
+func TestAssembler(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  [][]string
+	}{
+		{
+			name:  "plain lines",
+			lines: []string{"first", "second"},
+			want:  [][]string{{"first"}, {"second"}},
+		},
+		{
+			name: "java exception",
+			lines: []string{
+				"ERROR request failed",
+				"java.lang.IllegalStateException: boom",
+				"\tat com.example.App.run(App.java:10)",
+				"Caused by: java.io.IOException: closed",
+				"\tat com.example.Conn.read(Conn.java:42)",
+				"\t... 3 more",
+				"INFO recovered",
+			},
+			want: [][]string{
+				{"ERROR request failed"},
+				{
+					"java.lang.IllegalStateException: boom",
+					"\tat com.example.App.run(App.java:10)",
+					"Caused by: java.io.IOException: closed",
+					"\tat com.example.Conn.read(Conn.java:42)",
+					"\t... 3 more",
+				},
+				{"INFO recovered"},
+			},
+		},
+		{
+			name: "python traceback starting an entry",
+			lines: []string{
+				"Traceback (most recent call last):",
+				`  File "app.py", line 3, in <module>`,
+				"    raise ValueError('boom')",
+				"ValueError: boom",
+				"next record",
+			},
+			want: [][]string{
+				{
+					"Traceback (most recent call last):",
+					`  File "app.py", line 3, in <module>`,
+					"    raise ValueError('boom')",
+					"ValueError: boom",
+				},
+				{"next record"},
+			},
+		},
+		{
+			name: "python traceback after a log line",
+			lines: []string{
+				"ERROR:root:request failed",
+				"Traceback (most recent call last):",
+				`  File "app.py", line 3, in handle`,
+				"KeyError: 'id'",
+				"next record",
+			},
+			want: [][]string{
+				{
+					"ERROR:root:request failed",
+					"Traceback (most recent call last):",
+					`  File "app.py", line 3, in handle`,
+					"KeyError: 'id'",
+				},
+				{"next record"},
+			},
+		},
+		{
+			name: "go panic",
+			lines: []string{
+				"panic: runtime error: index out of range",
+				"",
+				"goroutine 1 [running]:",
+				"main.main()",
+				"\t/app/main.go:5 +0x1d",
+				"2024-01-02 03:04:05 restarted",
+			},
+			want: [][]string{
+				{
+					"panic: runtime error: index out of range",
+					"",
+					"goroutine 1 [running]:",
+					"main.main()",
+					"\t/app/main.go:5 +0x1d",
+				},
+				{"2024-01-02 03:04:05 restarted"},
+			},
+		},
+		{
+			name:  "blank lines",
+			lines: []string{"", "record", ""},
+			// The blank line after a record only terminates it
+			want: [][]string{nil, {"record"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := assemble(tt.lines...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssemblerPanicFlag(t *testing.T) {
+	a := &assembler{}
+	now := time.Now()
+	a.add(line{text: "fatal error: all goroutines are asleep"}, now)
+	e := a.add(line{text: "INFO next"}, now)
+	if e == nil || !e.panic {
+		t.Fatalf("got %+v, want a panic entry", e)
+	}
+	if e := a.flushIdle(now.Add(time.Minute), time.Second); e == nil || e.panic {
+		t.Errorf("got %+v, want a plain entry", e)
+	}
+}
+
+func TestAssemblerFlushIdle(t *testing.T) {
+	a := &assembler{}
+	now := time.Now()
+	a.add(line{text: "record", offset: 7}, now)
+
+	if e := a.flushIdle(now.Add(time.Second), 2*time.Second); e != nil {
+		t.Fatalf("flushed %+v before the timeout", e)
+	}
+	e := a.flushIdle(now.Add(2*time.Second), 2*time.Second)
+	if e == nil || e.offset != 7 {
+		t.Fatalf("got %+v, want the pending entry", e)
+	}
+	if e := a.flushIdle(now.Add(time.Hour), 2*time.Second); e != nil {
+		t.Errorf("flushed %+v twice", e)
+	}
+}
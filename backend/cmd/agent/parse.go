@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"error-logs/internal/models"
+)
+
+var (
+	textLevel = regexp.MustCompile(`(?i)\b(fatal|panic|critical|crit|error|err|warning|warn|info|debug)\b`)
+
+	jsonMessageKeys = []string{"msg", "message", "error", "err"}
+	jsonLevelKeys   = []string{"level", "lvl", "severity", "log.level"}
+	jsonStackKeys   = []string{"stack", "stack_trace", "stacktrace", "exception", "trace"}
+)
+
+var levelRank = map[string]int{
+	"debug":   0,
+	"info":    1,
+	"warning": 2,
+	"error":   3,
+}
+
+// parseEntry turns an assembled entry into an event. JSON log lines have
+// their well-known fields lifted out and the rest kept as context; plain text
+// lines are scanned for a level keyword.
+func parseEntry(e *entry) *models.CreateErrorRequest {
+	first := e.lines[0]
+	rest := e.lines[1:]
+
+	req := &models.CreateErrorRequest{
+		Context: make(map[string]interface{}),
+	}
+
+	var fields map[string]interface{}
+	if strings.HasPrefix(first, "{") && json.Unmarshal([]byte(first), &fields) == nil {
+		req.Message = takeString(fields, jsonMessageKeys)
+		req.Level = normalizeLevel(take(fields, jsonLevelKeys))
+		stack := takeString(fields, jsonStackKeys)
+		if len(rest) > 0 {
+			stack = strings.TrimLeft(stack+"\n"+strings.Join(rest, "\n"), "\n")
+		}
+		if stack != "" {
+			req.StackTrace = &stack
+		}
+		if req.Message == "" {
+			req.Message = first
+		}
+		for k, v := range fields {
+			req.Context[k] = v
+		}
+	} else {
+		req.Message = first
+		if strings.HasPrefix(first, pythonTraceback) && len(rest) > 0 {
+			// The exception itself is on the last line of a bare traceback
+			req.Message = rest[len(rest)-1]
+			rest = e.lines
+		}
+		if m := textLevel.FindString(req.Message); m != "" {
+			req.Level = normalizeLevel(m)
+		}
+		if stack := strings.Trim(strings.Join(rest, "\n"), "\n"); stack != "" {
+			req.StackTrace = &stack
+		}
+	}
+
+	if req.Level == "" {
+		// An unlabelled line followed by a stack trace is almost always an
+		// uncaught exception.
+		if e.panic || len(rest) > 0 {
+			req.Level = "error"
+		} else {
+			req.Level = "info"
+		}
+	}
+	return req
+}
+
+func normalizeLevel(v interface{}) string {
+	switch level := v.(type) {
+	case string:
+		switch strings.ToLower(level) {
+		case "fatal", "panic", "critical", "crit", "error", "err", "alert", "emergency":
+			return "error"
+		case "warning", "warn":
+			return "warning"
+		case "info", "notice":
+			return "info"
+		case "debug", "trace":
+			return "debug"
+		}
+	case float64:
+		// Numeric levels as used by pino and bunyan.
+		switch {
+		case level >= 50:
+			return "error"
+		case level >= 40:
+			return "warning"
+		case level >= 30:
+			return "info"
+		default:
+			return "debug"
+		}
+	}
+	return ""
+}
+
+func take(fields map[string]interface{}, keys []string) interface{} {
+	for _, key := range keys {
+		if v, ok := fields[key]; ok {
+			delete(fields, key)
+			return v
+		}
+	}
+	return nil
+}
+
+func takeString(fields map[string]interface{}, keys []string) string {
+	for _, key := range keys {
+		if v, ok := fields[key].(string); ok && v != "" {
+			delete(fields, key)
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   entry
+		message string
+		level   string
+		stack   string
+		context map[string]interface{}
+	}{
+		{
+			name: "json",
+			entry: entry{lines: []string{
+				`{"msg":"request failed","level":"ERROR","stack":"at handler","user":"u1"}`,
+			}},
+			message: "request failed",
+			level:   "error",
+			stack:   "at handler",
+			context: map[string]interface{}{"user": "u1"},
+		},
+		{
+			name: "json with numeric level and trailing frames",
+			entry: entry{lines: []string{
+				`{"message":"slow","lvl":40}`,
+				"    at worker",
+			}},
+			message: "slow",
+			level:   "warning",
+			stack:   "    at worker",
+			context: map[string]interface{}{},
+		},
+		{
+			name:    "json without a message",
+			entry:   entry{lines: []string{`{"level":50}`}},
+			message: `{"level":50}`,
+			level:   "error",
+			context: map[string]interface{}{},
+		},
+		{
+			name:    "text with level",
+			entry:   entry{lines: []string{"2024-01-02 WARN disk almost full"}},
+			message: "2024-01-02 WARN disk almost full",
+			level:   "warning",
+			context: map[string]interface{}{},
+		},
+		{
+			name:    "text without level",
+			entry:   entry{lines: []string{"listening on :8080"}},
+			message: "listening on :8080",
+			level:   "info",
+			context: map[string]interface{}{},
+		},
+		{
+			name:    "unlabelled line with stack",
+			entry:   entry{lines: []string{"java.lang.NullPointerException", "\tat App.run"}},
+			message: "java.lang.NullPointerException",
+			level:   "error",
+			stack:   "\tat App.run",
+			context: map[string]interface{}{},
+		},
+		{
+			name:    "go panic",
+			entry:   entry{lines: []string{"fatal error: out of memory"}, panic: true},
+			message: "fatal error: out of memory",
+			level:   "error",
+			context: map[string]interface{}{},
+		},
+		{
+			name: "python traceback",
+			entry: entry{lines: []string{
+				"Traceback (most recent call last):",
+				`  File "app.py", line 3, in <module>`,
+				"ValueError: boom",
+			}},
+			message: "ValueError: boom",
+			level:   "error",
+			stack:   "Traceback (most recent call last):\n  File \"app.py\", line 3, in <module>\nValueError: boom",
+			context: map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := parseEntry(&tt.entry)
+			if req.Message != tt.message {
+				t.Errorf("message = %q, want %q", req.Message, tt.message)
+			}
+			if req.Level != tt.level {
+				t.Errorf("level = %q, want %q", req.Level, tt.level)
+			}
+			var stack string
+			if req.StackTrace != nil {
+				stack = *req.StackTrace
+			}
+			if stack != tt.stack {
+				t.Errorf("stack = %q, want %q", stack, tt.stack)
+			}
+			if !reflect.DeepEqual(req.Context, tt.context) {
+				t.Errorf("context = %v, want %v", req.Context, tt.context)
+			}
+		})
+	}
+}
+
+func TestNormalizeLevel(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{"FATAL", "error"},
+		{"warn", "warning"},
+		{"notice", "info"},
+		{"trace", "debug"},
+		{"verbose", ""},
+		{float64(60), "error"},
+		{float64(30), "info"},
+		{float64(10), "debug"},
+		{true, ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeLevel(tt.in); got != tt.want {
+			t.Errorf("normalizeLevel(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
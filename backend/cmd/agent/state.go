@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type fileOffset struct {
+	ID     uint64 `json:"id"`
+	Offset int64  `json:"offset"`
+}
+
+// stateStore persists how far each file has been read. Offsets are only
+// advanced after the entries before them are safely in the spool.
+type stateStore struct {
+	path    string
+	offsets map[string]fileOffset
+	dirty   bool
+}
+
+func loadState(path string) (*stateStore, error) {
+	s := &stateStore{path: path, offsets: make(map[string]fileOffset)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.offsets); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+	return s, nil
+}
+
+func (s *stateStore) get(path string) *fileOffset {
+	if off, ok := s.offsets[path]; ok {
+		return &off
+	}
+	return nil
+}
+
+func (s *stateStore) set(path string, off fileOffset) {
+	if s.offsets[path] != off {
+		s.offsets[path] = off
+		s.dirty = true
+	}
+}
+
+func (s *stateStore) save() error {
+	if !s.dirty {
+		return nil
+	}
+	data, err := json.Marshal(s.offsets)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Clean(s.path)); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
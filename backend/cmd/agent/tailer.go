@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+const maxLineLength = 1024 * 1024
+
+type line struct {
+	text   string
+	offset int64 // offset just past the end of this line
+	id     uint64
+}
+
+// tailer follows a single path by polling. A rename-based rotation is noticed
+// when the path points at a different file, in which case the old handle is
+// read to the end before switching; copytruncate is noticed by the size
+// shrinking below the read offset.
+type tailer struct {
+	path    string
+	file    *os.File
+	info    os.FileInfo
+	id      uint64
+	offset  int64
+	reader  *bufio.Reader
+	partial strings.Builder
+}
+
+func openTailer(path string, saved *fileOffset, startAtEnd bool) (*tailer, error) {
+	t := &tailer{path: path}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case saved != nil && saved.ID == t.id && saved.Offset <= t.info.Size():
+		t.offset = saved.Offset
+	case saved == nil && startAtEnd:
+		t.offset = t.info.Size()
+	}
+	if _, err := t.file.Seek(t.offset, io.SeekStart); err != nil {
+		t.file.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *tailer) open() error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	t.file = f
+	t.info = fi
+	t.id = fileID(fi)
+	t.offset = 0
+	t.reader = bufio.NewReader(f)
+	t.partial.Reset()
+	return nil
+}
+
+func (t *tailer) poll(emit func(line)) error {
+	t.readLines(emit)
+
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		// The file may be mid-rotation; keep the old handle until it reappears.
+		return nil
+	}
+
+	if !os.SameFile(fi, t.info) {
+		t.file.Close()
+		if err := t.open(); err != nil {
+			return err
+		}
+		t.readLines(emit)
+		return nil
+	}
+
+	if fi.Size() < t.offset {
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		t.offset = 0
+		t.reader.Reset(t.file)
+		t.partial.Reset()
+		t.readLines(emit)
+	}
+	return nil
+}
+
+func (t *tailer) readLines(emit func(line)) {
+	for {
+		chunk, err := t.reader.ReadString('\n')
+		t.partial.WriteString(chunk)
+		if err != nil && t.partial.Len() < maxLineLength {
+			// Incomplete line: wait for the writer to finish it.
+			return
+		}
+
+		text := t.partial.String()
+		t.partial.Reset()
+		t.offset += int64(len(text))
+		emit(line{
+			text:   strings.TrimRight(text, "\r\n"),
+			offset: t.offset,
+			id:     t.id,
+		})
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (t *tailer) Close() error {
+	return t.file.Close()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// pollLines runs one poll and returns the text of the lines it emitted.
+func pollLines(t *testing.T, tl *tailer) []string {
+	t.Helper()
+	var got []string
+	if err := tl.poll(func(l line) { got = append(got, l.text) }); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func openTestTailer(t *testing.T, path string, saved *fileOffset, startAtEnd bool) *tailer {
+	t.Helper()
+	tl, err := openTailer(path, saved, startAtEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tl.Close() })
+	return tl
+}
+
+func TestTailerPartialLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "one\r\ntw")
+	tl := openTestTailer(t, path, nil, false)
+
+	if got := pollLines(t, tl); !reflect.DeepEqual(got, []string{"one"}) {
+		t.Fatalf("got %q, want the complete line only", got)
+	}
+	appendFile(t, path, "o\nthree\n")
+	if got := pollLines(t, tl); !reflect.DeepEqual(got, []string{"two", "three"}) {
+		t.Fatalf("got %q", got)
+	}
+	if tl.offset != int64(len("one\r\ntwo\nthree\n")) {
+		t.Errorf("offset = %d", tl.offset)
+	}
+}
+
+func TestTailerRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "old\n")
+	tl := openTestTailer(t, path, nil, false)
+	pollLines(t, tl)
+	oldID := tl.id
+
+	// The writer finishes its line in the renamed file before reopening.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path+".1", "last old\n")
+	appendFile(t, path, "new\n")
+
+	if got := pollLines(t, tl); !reflect.DeepEqual(got, []string{"last old", "new"}) {
+		t.Fatalf("got %q", got)
+	}
+	if tl.id == oldID || tl.offset != int64(len("new\n")) {
+		t.Errorf("id = %d (was %d), offset = %d", tl.id, oldID, tl.offset)
+	}
+}
+
+func TestTailerTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "first\nsecond\n")
+	tl := openTestTailer(t, path, nil, false)
+	pollLines(t, tl)
+
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "again\n")
+
+	if got := pollLines(t, tl); !reflect.DeepEqual(got, []string{"again"}) {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestTailerStartOffset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "one\ntwo\n")
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := fileID(fi)
+
+	tests := []struct {
+		name       string
+		saved      *fileOffset
+		startAtEnd bool
+		want       []string
+	}{
+		{"from start", nil, false, []string{"one", "two"}},
+		{"from end", nil, true, nil},
+		{"saved offset", &fileOffset{ID: id, Offset: 4}, true, []string{"two"}},
+		{"saved offset of another file", &fileOffset{ID: id + 1, Offset: 4}, true, []string{"one", "two"}},
+		{"saved offset past the end", &fileOffset{ID: id, Offset: 100}, false, []string{"one", "two"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tl := openTestTailer(t, path, tt.saved, tt.startAtEnd)
+			if got := pollLines(t, tl); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
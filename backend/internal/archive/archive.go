@@ -0,0 +1,254 @@
+// Package archive exports events to gzipped NDJSON files in blob storage
+// before they are deleted, and reads them back.
+//
+// Files are laid out by project and event date, with a manifest per writer
+// run listing each file's event count, time range and checksum:
+//
+//	<project>/<YYYY-MM-DD>/<run>-<seq>.ndjson.gz
+//	<project>/<YYYY-MM-DD>/<run>.manifest.json
+//
+// <project> is the project ID, or "none" for events without one. Each line
+// of a file is one event as returned by the API, including tags,
+// breadcrumbs and frames.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/blob"
+	"error-logs/internal/models"
+)
+
+const (
+	NoProject  = "none"
+	dateFormat = "2006-01-02"
+
+	fileSuffix     = ".ndjson.gz"
+	manifestSuffix = ".manifest.json"
+)
+
+type Manifest struct {
+	Run       string    `json:"run"`
+	Project   string    `json:"project"`
+	Date      string    `json:"date"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Files     []File    `json:"files"`
+}
+
+type File struct {
+	Key            string    `json:"key"`
+	Events         int       `json:"events"`
+	Bytes          int64     `json:"bytes"`
+	SHA256         string    `json:"sha256"`
+	FirstTimestamp time.Time `json:"first_timestamp"`
+	LastTimestamp  time.Time `json:"last_timestamp"`
+}
+
+// Writer archives the events of one retention run. It is not safe for
+// concurrent use.
+type Writer struct {
+	store     blob.Store
+	run       string
+	seq       int
+	manifests map[string]*Manifest // by "<project>/<date>"
+}
+
+func NewWriter(store blob.Store) *Writer {
+	return &Writer{
+		store:     store,
+		run:       time.Now().UTC().Format("20060102T150405Z") + "-" + uuid.New().String()[:8],
+		manifests: make(map[string]*Manifest),
+	}
+}
+
+// Write archives a batch of events, one file per project and date, and
+// updates the run's manifests. Once it returns nil the events are safe to
+// delete.
+func (w *Writer) Write(ctx context.Context, events []models.Error) error {
+	groups := make(map[string][]models.Error)
+	for _, e := range events {
+		dir := ProjectDir(e.ProjectID) + "/" + e.Timestamp.UTC().Format(dateFormat)
+		groups[dir] = append(groups[dir], e)
+	}
+
+	dirs := make([]string, 0, len(groups))
+	for dir := range groups {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		if err := w.writeFile(ctx, dir, groups[dir]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) writeFile(ctx context.Context, dir string, events []models.Error) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	file := File{Events: len(events), FirstTimestamp: events[0].Timestamp, LastTimestamp: events[0].Timestamp}
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("failed to encode event %s: %w", e.ID, err)
+		}
+		if e.Timestamp.Before(file.FirstTimestamp) {
+			file.FirstTimestamp = e.Timestamp
+		}
+		if e.Timestamp.After(file.LastTimestamp) {
+			file.LastTimestamp = e.Timestamp
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress archive: %w", err)
+	}
+
+	w.seq++
+	sum := sha256.Sum256(buf.Bytes())
+	file.Key = fmt.Sprintf("%s/%s-%04d%s", dir, w.run, w.seq, fileSuffix)
+	file.Bytes = int64(buf.Len())
+	file.SHA256 = hex.EncodeToString(sum[:])
+	if err := w.store.Put(ctx, file.Key, &buf, file.Bytes, "application/gzip"); err != nil {
+		return fmt.Errorf("failed to store archive %s: %w", file.Key, err)
+	}
+
+	manifest := w.manifests[dir]
+	if manifest == nil {
+		project, date, _ := strings.Cut(dir, "/")
+		manifest = &Manifest{Run: w.run, Project: project, Date: date}
+		w.manifests[dir] = manifest
+	}
+	manifest.Files = append(manifest.Files, file)
+	manifest.UpdatedAt = time.Now().UTC()
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	key := dir + "/" + w.run + manifestSuffix
+	if err := w.store.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		return fmt.Errorf("failed to store manifest %s: %w", key, err)
+	}
+	return nil
+}
+
+// ProjectDir is the top-level directory of a project's archives.
+func ProjectDir(projectID *uuid.UUID) string {
+	if projectID == nil {
+		return NoProject
+	}
+	return projectID.String()
+}
+
+// Files lists the archive files of a project for events on dates from
+// through to, inclusive, with the checksums recorded in their manifests.
+// Files a run stored without getting to update its manifest have no
+// checksum.
+func Files(ctx context.Context, store blob.Store, project string, from, to time.Time) ([]File, error) {
+	var files []File
+	for day := from.UTC(); !day.After(to.UTC()); day = day.AddDate(0, 0, 1) {
+		keys, err := store.List(ctx, project+"/"+day.Format(dateFormat)+"/")
+		if err != nil {
+			return nil, err
+		}
+
+		recorded := make(map[string]File)
+		for _, key := range keys {
+			if !strings.HasSuffix(key, manifestSuffix) {
+				continue
+			}
+			manifest, err := readManifest(ctx, store, key)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range manifest.Files {
+				recorded[f.Key] = f
+			}
+		}
+		for _, key := range keys {
+			if !strings.HasSuffix(key, fileSuffix) {
+				continue
+			}
+			f, ok := recorded[key]
+			if !ok {
+				f = File{Key: key}
+			}
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+func readManifest(ctx context.Context, store blob.Store, key string) (*Manifest, error) {
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest %s: %w", key, err)
+	}
+	defer r.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", key, err)
+	}
+	return &manifest, nil
+}
+
+// Read returns the events of an archive file, checking it against the
+// manifest's checksum when there is one.
+func Read(ctx context.Context, store blob.Store, file File) ([]models.Error, error) {
+	r, err := store.Get(ctx, file.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", file.Key, err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", file.Key, err)
+	}
+	if file.SHA256 != "" {
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != file.SHA256 {
+			return nil, fmt.Errorf("archive %s does not match its manifest checksum", file.Key)
+		}
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress archive %s: %w", file.Key, err)
+	}
+	defer gz.Close()
+
+	var events []models.Error
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 64<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e models.Error
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("failed to decode event in %s: %w", file.Key, err)
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %w", file.Key, err)
+	}
+	return events, nil
+}
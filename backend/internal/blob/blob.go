@@ -0,0 +1,25 @@
+// Package blob stores opaque files such as event attachments and archives,
+// either on the local filesystem or in an S3-compatible bucket.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Get for keys that don't exist.
+var ErrNotFound = errors.New("blob not found")
+
+// Store is a flat key/value store for files. Keys are slash-separated paths
+// chosen by the caller and must not contain "..".
+type Store interface {
+	// Put stores r under key, replacing any existing blob. size is -1 when
+	// unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes key; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the keys under a "dir/" prefix, at any depth, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
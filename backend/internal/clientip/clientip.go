@@ -0,0 +1,216 @@
+// Package clientip works out the address of the client behind a chain of
+// reverse proxies.
+//
+// Forwarding headers are only believed when the connection comes from a
+// trusted proxy, and are read right to left: each hop appends the address it
+// saw, so the first untrusted address from the right is the client. Entries
+// further left were written by the client itself and can't be trusted.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// DefaultHeaders are consulted, in order, when a project hasn't chosen which
+// proxy headers to trust.
+var DefaultHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"}
+
+type Resolver struct {
+	trusted []*net.IPNet
+	relays  []*net.IPNet
+}
+
+// New creates a resolver trusting the forwarding headers of the given proxy
+// networks, and the client addresses that the given relay networks report
+// per event. Bare addresses are accepted as single-host networks.
+func New(trustedProxies, trustedRelays []string) (*Resolver, error) {
+	trusted, err := parseNetworks(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy %w", err)
+	}
+	relays, err := parseNetworks(trustedRelays)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted relay %w", err)
+	}
+	return &Resolver{trusted: trusted, relays: relays}, nil
+}
+
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("%q", cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolver) isTrusted(ip net.IP) bool {
+	return contains(r.trusted, ip)
+}
+
+// ClientIP returns the client address of req, looking at headers in order
+// and using the first one present. Without a trusted peer, or when none of
+// the headers is set, it is the peer address.
+func (r *Resolver) ClientIP(req *http.Request, headers []string) string {
+	peer := parseAddr(req.RemoteAddr)
+	if peer == nil {
+		return req.RemoteAddr
+	}
+	if !r.isTrusted(peer) {
+		return peer.String()
+	}
+
+	for _, header := range headers {
+		values := req.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+
+		var hops []string
+		if strings.EqualFold(header, "Forwarded") {
+			hops = forwardedFor(values)
+		} else {
+			hops = splitList(values)
+		}
+		if len(hops) == 0 {
+			continue
+		}
+		return r.walk(hops, peer).String()
+	}
+	return peer.String()
+}
+
+// Forwarded returns the client address a relay reported other than in a
+// header, such as in each event of a request body. sender is the request's
+// client address as returned by ClientIP, so a relay behind a load balancer
+// is recognized while a client behind the same load balancer isn't. Unless
+// sender is a trusted relay, or if addr isn't an IP, it returns "".
+func (r *Resolver) Forwarded(sender, addr string) string {
+	if ip := parseAddr(sender); ip == nil || !contains(r.relays, ip) {
+		return ""
+	}
+	ip := parseAddr(addr)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// walk returns the rightmost hop that isn't a trusted proxy. If a hop can't
+// be parsed (RFC 7239 allows "unknown" and obfuscated names) the chain is
+// broken there and the last good hop is used; if every hop is trusted the
+// leftmost one is.
+func (r *Resolver) walk(hops []string, peer net.IP) net.IP {
+	last := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseAddr(hops[i])
+		if ip == nil {
+			return last
+		}
+		if !r.isTrusted(ip) {
+			return ip
+		}
+		last = ip
+	}
+	return last
+}
+
+// parseAddr accepts an IP with or without a port, and with or without the
+// brackets used around IPv6 addresses.
+func parseAddr(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	if i := strings.IndexByte(s, '%'); i >= 0 {
+		s = s[:i] // IPv6 zone
+	}
+	return net.ParseIP(s)
+}
+
+// splitList flattens comma-separated header values, keeping repeated headers
+// in the order they were received.
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// forwardedFor extracts the for= parameter of each element of RFC 7239
+// Forwarded headers. Elements without one are kept as empty hops so they
+// break the chain rather than being skipped.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			if strings.TrimSpace(element) == "" {
+				continue
+			}
+			hop := ""
+			for _, pair := range splitQuoted(element, ';') {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(strings.TrimSpace(key), "for") {
+					hop = strings.Trim(strings.TrimSpace(val), `"`)
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
+
+// splitQuoted splits s on sep outside double-quoted strings.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inQuotes {
+				i++
+			}
+		case '"':
+			inQuotes = !inQuotes
+		case sep:
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
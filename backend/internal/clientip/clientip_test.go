@@ -0,0 +1,220 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	r, err := New([]string{"10.0.0.0/8", "2001:db8::1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers []string // nil for DefaultHeaders
+		request map[string][]string
+		want    string
+	}{
+		{
+			name: "untrusted peer",
+			peer: "203.0.113.9:5000",
+			request: map[string][]string{
+				"X-Forwarded-For": {"198.51.100.7"},
+				"Forwarded":       {"for=198.51.100.7"},
+			},
+			want: "203.0.113.9",
+		},
+		{
+			name: "trusted peer without headers",
+			want: "10.0.0.1",
+		},
+		{
+			name:    "peer that isn't an address",
+			peer:    "@",
+			request: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "@",
+		},
+		{
+			name:    "x-forwarded-for",
+			request: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "x-forwarded-for skips trusted hops from the right",
+			request: map[string][]string{"X-Forwarded-For": {"198.51.100.7, 10.0.0.2,10.0.0.3"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "x-forwarded-for ignores entries left of the client",
+			request: map[string][]string{"X-Forwarded-For": {"6.6.6.6, 10.0.0.4, 198.51.100.7, 10.0.0.2"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "repeated x-forwarded-for headers",
+			request: map[string][]string{"X-Forwarded-For": {"6.6.6.6", "198.51.100.7"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "x-forwarded-for of trusted hops only",
+			request: map[string][]string{"X-Forwarded-For": {"10.0.0.5, 10.0.0.6"}},
+			want:    "10.0.0.5",
+		},
+		{
+			name:    "x-forwarded-for with port",
+			request: map[string][]string{"X-Forwarded-For": {"198.51.100.7:4711"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "x-forwarded-for with unparseable hop",
+			request: map[string][]string{"X-Forwarded-For": {"198.51.100.7, garbage, 10.0.0.2"}},
+			want:    "10.0.0.2",
+		},
+		{
+			name:    "x-real-ip",
+			request: map[string][]string{"X-Real-IP": {"198.51.100.7"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "forwarded",
+			request: map[string][]string{"Forwarded": {"for=192.0.2.60;proto=http;by=203.0.113.43"}},
+			want:    "192.0.2.60",
+		},
+		{
+			name:    "forwarded parameter names are case-insensitive",
+			request: map[string][]string{"Forwarded": {"For=192.0.2.60"}},
+			want:    "192.0.2.60",
+		},
+		{
+			name:    "forwarded quoted ipv6 with port",
+			request: map[string][]string{"Forwarded": {`for="[2001:db8:cafe::17]:4711"`}},
+			want:    "2001:db8:cafe::17",
+		},
+		{
+			name:    "forwarded quoted value containing separators",
+			request: map[string][]string{"Forwarded": {`for="198.51.100.7";host="a,b;c", for=10.0.0.2`}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "forwarded elements across headers",
+			request: map[string][]string{"Forwarded": {"for=6.6.6.6, for=198.51.100.7", "for=10.0.0.2"}},
+			want:    "198.51.100.7",
+		},
+		{
+			name:    "forwarded unknown hop breaks the chain",
+			request: map[string][]string{"Forwarded": {"for=198.51.100.7, for=unknown, for=10.0.0.9"}},
+			want:    "10.0.0.9",
+		},
+		{
+			name:    "forwarded obfuscated identifier",
+			request: map[string][]string{"Forwarded": {"for=198.51.100.7, for=_hidden"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "forwarded element without for",
+			request: map[string][]string{"Forwarded": {"for=198.51.100.7, proto=https"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name: "forwarded takes precedence",
+			request: map[string][]string{
+				"Forwarded":       {"for=192.0.2.60"},
+				"X-Forwarded-For": {"198.51.100.7"},
+			},
+			want: "192.0.2.60",
+		},
+		{
+			name:    "headers in project order",
+			headers: []string{"X-Real-IP", "Forwarded"},
+			request: map[string][]string{
+				"Forwarded": {"for=192.0.2.60"},
+				"X-Real-IP": {"198.51.100.7"},
+			},
+			want: "198.51.100.7",
+		},
+		{
+			name:    "header the project doesn't trust",
+			headers: []string{"X-Real-IP"},
+			request: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "no trusted headers",
+			headers: []string{},
+			request: map[string][]string{"X-Forwarded-For": {"198.51.100.7"}},
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "trusted ipv6 peer",
+			peer:    "[2001:db8::1]:443",
+			request: map[string][]string{"X-Forwarded-For": {"2001:db8::99"}},
+			want:    "2001:db8::99",
+		},
+		{
+			name:    "ipv6 zone",
+			request: map[string][]string{"X-Forwarded-For": {"fe80::1%eth0"}},
+			want:    "fe80::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/errors", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			if tt.peer != "" {
+				req.RemoteAddr = tt.peer
+			}
+			for name, values := range tt.request {
+				for _, value := range values {
+					req.Header.Add(name, value)
+				}
+			}
+			headers := tt.headers
+			if headers == nil {
+				headers = DefaultHeaders
+			}
+			if got := r.ClientIP(req, headers); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForwarded(t *testing.T) {
+	r, err := New(nil, []string{"192.0.2.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		sender, addr, want string
+	}{
+		{"192.0.2.5", "198.51.100.7", "198.51.100.7"},
+		{"192.0.2.5", "[2001:db8::2]:80", "2001:db8::2"},
+		{"192.0.2.5", "not an ip", ""},
+		{"203.0.113.9", "198.51.100.7", ""},
+		{"", "198.51.100.7", ""},
+	}
+	for _, tt := range tests {
+		if got := r.Forwarded(tt.sender, tt.addr); got != tt.want {
+			t.Errorf("Forwarded(%q, %q) = %q, want %q", tt.sender, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, tt := range []struct {
+		proxies, relays []string
+		ok              bool
+	}{
+		{[]string{"10.0.0.0/8", "192.0.2.1", "::1"}, []string{"2001:db8::/32"}, true},
+		{[]string{"proxy.internal"}, nil, false},
+		{nil, []string{"10.0.0.0/33"}, false},
+	} {
+		if _, err := New(tt.proxies, tt.relays); (err == nil) != tt.ok {
+			t.Errorf("New(%q, %q) error = %v", tt.proxies, tt.relays, err)
+		}
+	}
+}
@@ -2,13 +2,91 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
+	// DatabaseURL is a postgres:// URL, or sqlite:<path> to keep everything
+	// in a local SQLite file
 	DatabaseURL string
 	RedisURL    string
 	Port        string
 	Environment string
+
+	// AutoMigrate applies pending database migrations on startup instead of
+	// leaving it to "migrate up"
+	AutoMigrate bool
+
+	// The errors table is partitioned by day or week; partitions older than
+	// PartitionRetentionDays are dropped (0 keeps them)
+	PartitionInterval      string
+	PartitionsAhead        int
+	PartitionRetentionDays int
+
+	// Default retention in days, overridable per project; 0 keeps events.
+	// RetentionLevels is "level=days,..."
+	RetentionDays         int
+	RetentionLevels       map[string]int
+	RetentionResolvedDays int
+
+	// Where retention archives events before deleting them: "none",
+	// "filesystem" under ArchiveDir, or "s3" in ArchiveS3Bucket (default
+	// S3Bucket) with the other S3 settings
+	ArchiveStorage  string
+	ArchiveDir      string
+	ArchiveS3Bucket string
+	ArchiveS3Prefix string
+
+	// TrustedProxies are the networks whose forwarding headers are believed
+	// when working out client IPs.
+	TrustedProxies []string
+	// TrustedRelays are the relays whose per-event client_ip is believed;
+	// they are matched against the sender after the forwarding headers.
+	TrustedRelays []string
+
+	// GeoIP databases (MaxMind City or Country, and ASN); either is optional
+	GeoIPDBPath    string
+	GeoIPASNDBPath string
+
+	// NotifyWebhookURL receives new feedback and regressions as JSON posts
+	NotifyWebhookURL string
+
+	// Files such as attachments and source maps are kept on disk under
+	// BlobDir, or in an S3-compatible bucket when BlobStorage is "s3"
+	BlobStorage string
+	BlobDir     string
+
+	AttachmentMaxSizeMB     int
+	AttachmentQuotaMB       int // per project
+	AttachmentRetentionDays int
+	AttachmentContentTypes  []string
+
+	// S3-compatible object storage, e.g. AWS or a local MinIO
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3Prefix    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+
+	SyslogUDPAddr     string
+	SyslogTCPAddr     string
+	SyslogTLSAddr     string
+	SyslogTLSCertFile string
+	SyslogTLSKeyFile  string
+	SyslogMinLevel    string
+
+	// Mode is "server" (default) or "relay"
+	Mode                string
+	RelayUpstreamURL    string
+	RelayUpstreamAPIKey string
+	RelayAPIKeys        []string
+	RelaySampleRate     float64
+	RelayScrubKeys      []string
+	RelaySpoolDir       string
+	RelaySpoolMaxMB     int
 }
 
 func Load() *Config {
@@ -17,7 +95,72 @@ func Load() *Config {
 		RedisURL:    getEnvOrDefault("REDIS_URL", "redis://localhost:6379"),
 		Port:        getEnvOrDefault("PORT", "8080"),
 		Environment: getEnvOrDefault("ENVIRONMENT", "development"),
+		AutoMigrate: getEnvBoolOrDefault("AUTO_MIGRATE", false),
+
+		PartitionInterval:      getEnvOrDefault("ERRORS_PARTITION_INTERVAL", "week"),
+		PartitionsAhead:        getEnvIntOrDefault("ERRORS_PARTITIONS_AHEAD", 2),
+		PartitionRetentionDays: getEnvIntOrDefault("ERRORS_PARTITION_RETENTION_DAYS", 0),
+
+		RetentionDays:         getEnvIntOrDefault("RETENTION_DAYS", 0),
+		RetentionLevels:       splitIntMap(os.Getenv("RETENTION_LEVELS")),
+		RetentionResolvedDays: getEnvIntOrDefault("RETENTION_RESOLVED_DAYS", 0),
+
+		ArchiveStorage:  getEnvOrDefault("ARCHIVE_STORAGE", "none"),
+		ArchiveDir:      getEnvOrDefault("ARCHIVE_DIR", "./data/archive"),
+		ArchiveS3Bucket: getEnvOrDefault("ARCHIVE_S3_BUCKET", os.Getenv("S3_BUCKET")),
+		ArchiveS3Prefix: getEnvOrDefault("ARCHIVE_S3_PREFIX", "archive"),
+
+		TrustedProxies: trustedProxies(),
+		TrustedRelays:  splitList(os.Getenv("TRUSTED_RELAYS")),
+
+		GeoIPDBPath:    os.Getenv("GEOIP_DB_PATH"),
+		GeoIPASNDBPath: os.Getenv("GEOIP_ASN_DB_PATH"),
+
+		NotifyWebhookURL: os.Getenv("NOTIFY_WEBHOOK_URL"),
+
+		BlobStorage: getEnvOrDefault("BLOB_STORAGE", "filesystem"),
+		BlobDir:     getEnvOrDefault("BLOB_DIR", "./data/blobs"),
+
+		AttachmentMaxSizeMB:     getEnvIntOrDefault("ATTACHMENT_MAX_SIZE_MB", 20),
+		AttachmentQuotaMB:       getEnvIntOrDefault("ATTACHMENT_QUOTA_MB", 1024),
+		AttachmentRetentionDays: getEnvIntOrDefault("ATTACHMENT_RETENTION_DAYS", 30),
+		AttachmentContentTypes: splitList(getEnvOrDefault("ATTACHMENT_CONTENT_TYPES",
+			"image/png,image/jpeg,image/gif,image/webp,text/plain,application/json,application/zip,application/gzip,application/octet-stream")),
+
+		S3Endpoint:  getEnvOrDefault("S3_ENDPOINT", "s3.amazonaws.com"),
+		S3Region:    os.Getenv("S3_REGION"),
+		S3Bucket:    os.Getenv("S3_BUCKET"),
+		S3Prefix:    os.Getenv("S3_PREFIX"),
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+		S3UseSSL:    getEnvBoolOrDefault("S3_USE_SSL", true),
+
+		SyslogUDPAddr:     os.Getenv("SYSLOG_UDP_ADDR"),
+		SyslogTCPAddr:     os.Getenv("SYSLOG_TCP_ADDR"),
+		SyslogTLSAddr:     os.Getenv("SYSLOG_TLS_ADDR"),
+		SyslogTLSCertFile: os.Getenv("SYSLOG_TLS_CERT_FILE"),
+		SyslogTLSKeyFile:  os.Getenv("SYSLOG_TLS_KEY_FILE"),
+		SyslogMinLevel:    getEnvOrDefault("SYSLOG_MIN_LEVEL", "warning"),
+
+		Mode:                getEnvOrDefault("MODE", "server"),
+		RelayUpstreamURL:    os.Getenv("RELAY_UPSTREAM_URL"),
+		RelayUpstreamAPIKey: os.Getenv("RELAY_UPSTREAM_API_KEY"),
+		RelayAPIKeys:        splitList(os.Getenv("RELAY_API_KEYS")),
+		RelaySampleRate:     getEnvFloatOrDefault("RELAY_SAMPLE_RATE", 1),
+		RelayScrubKeys:      splitList(os.Getenv("RELAY_SCRUB_KEYS")),
+		RelaySpoolDir:       getEnvOrDefault("RELAY_SPOOL_DIR", "./relay-spool"),
+		RelaySpoolMaxMB:     getEnvIntOrDefault("RELAY_SPOOL_MAX_MB", 1024),
+	}
+}
+
+// trustedProxies defaults to loopback and private networks, where reverse
+// proxies usually live; TRUSTED_PROXIES=none trusts no forwarding headers.
+func trustedProxies() []string {
+	value := getEnvOrDefault("TRUSTED_PROXIES", "127.0.0.0/8,::1,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16,fc00::/7")
+	if value == "none" {
+		return nil
 	}
+	return splitList(value)
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -26,3 +169,78 @@ func getEnvOrDefault(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// AgentConfig configures the log-tailing agent in cmd/agent.
+type AgentConfig struct {
+	Endpoint    string
+	APIKey      string
+	Files       []string
+	StateDir    string
+	Source      string
+	Environment string
+	MinLevel    string
+	Include     string
+	StartAt     string
+	SpoolMaxMB  int
+}
+
+func LoadAgent() *AgentConfig {
+	return &AgentConfig{
+		Endpoint:    getEnvOrDefault("AGENT_ENDPOINT", "http://localhost:8080"),
+		APIKey:      os.Getenv("AGENT_API_KEY"),
+		Files:       splitList(os.Getenv("AGENT_FILES")),
+		StateDir:    getEnvOrDefault("AGENT_STATE_DIR", "/var/lib/error-logs-agent"),
+		Source:      os.Getenv("AGENT_SOURCE"),
+		Environment: os.Getenv("AGENT_ENVIRONMENT"),
+		MinLevel:    getEnvOrDefault("AGENT_MIN_LEVEL", "error"),
+		Include:     os.Getenv("AGENT_INCLUDE"),
+		StartAt:     getEnvOrDefault("AGENT_START_AT", "end"),
+		SpoolMaxMB:  getEnvIntOrDefault("AGENT_SPOOL_MAX_MB", 100),
+	}
+}
+
+func getEnvIntOrDefault(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvBoolOrDefault(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvFloatOrDefault(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// splitIntMap parses "key=n,key=n"; entries that don't parse are skipped.
+func splitIntMap(value string) map[string]int {
+	items := make(map[string]int)
+	for _, item := range splitList(value) {
+		key, n, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		if days, err := strconv.Atoi(strings.TrimSpace(n)); err == nil {
+			items[strings.TrimSpace(key)] = days
+		}
+	}
+	return items
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"error-logs/internal/models"
+)
+
+// ListExpiredErrors returns up to limit events, oldest first and complete
+// with tags, breadcrumbs and frames, that a retention rule has expired, so
+// they can be archived before DeleteArchivedErrors removes them.
+func (db *DB) ListExpiredErrors(rule models.RetentionRule, limit int) ([]models.Error, error) {
+	where, args := retentionClause(rule)
+	args = append(args, limit)
+	query := fmt.Sprintf("SELECT %s, breadcrumbs, frames FROM errors WHERE %s ORDER BY timestamp, id LIMIT $%d",
+		errorColumns, where, len(args))
+	return db.listErrorDetails(query, args...)
+}
+
+// ListPartitionErrors pages through the events of a partition of errors in
+// (timestamp, id) order, returning up to limit events after the given one.
+// Pass a nil afterID for the first page.
+func (db *DB) ListPartitionErrors(partition string, afterTimestamp time.Time, afterID *uuid.UUID, limit int) ([]models.Error, error) {
+	// partition comes from the catalog via DropPartitionsBefore, never user input
+	query := fmt.Sprintf("SELECT %s, breadcrumbs, frames FROM %s", errorColumns, pq.QuoteIdentifier(partition))
+	args := []interface{}{limit}
+	if afterID != nil {
+		query += " WHERE (timestamp, id) > ($2, $3)"
+		args = append(args, afterTimestamp, *afterID)
+	}
+	query += " ORDER BY timestamp, id LIMIT $1"
+	return db.listErrorDetails(query, args...)
+}
+
+func (db *DB) listErrorDetails(query string, args ...interface{}) ([]models.Error, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query errors: %w", err)
+	}
+	defer rows.Close()
+
+	var errors []models.Error
+	for rows.Next() {
+		var e models.Error
+		if err := scanErrorDetail(rows, &e); err != nil {
+			return nil, fmt.Errorf("failed to scan error: %w", err)
+		}
+		errors = append(errors, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.loadTags(errors); err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
+
+// DeleteArchivedErrors deletes the given events, with their tags, provided
+// the rule that listed them still expires them, and returns how many it
+// deleted.
+func (db *DB) DeleteArchivedErrors(rule models.RetentionRule, errors []models.Error) (int64, error) {
+	if len(errors) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, len(errors))
+	timestamps := make([]string, len(errors))
+	for i, e := range errors {
+		ids[i] = e.ID.String()
+		timestamps[i] = e.Timestamp.Format(time.RFC3339Nano)
+	}
+
+	where, args := retentionClause(rule)
+	args = append(args, pq.Array(ids), pq.Array(timestamps))
+	query := fmt.Sprintf(`
+		WITH archived AS (
+			SELECT id, timestamp FROM errors
+			WHERE %s AND (id, timestamp) IN (SELECT * FROM unnest($%d::uuid[], $%d::timestamptz[]))
+		), deleted_tags AS (
+			DELETE FROM error_tags t USING archived x WHERE t.error_id = x.id AND t.timestamp = x.timestamp
+		)
+		DELETE FROM errors e USING archived x WHERE e.id = x.id AND e.timestamp = x.timestamp
+	`, where, len(args)-1, len(args))
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete archived errors: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// RestoreErrors inserts archived events and their tags in one transaction,
+// skipping any still stored, and returns how many it inserted.
+func (db *DB) RestoreErrors(errors []models.Error) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var restored int64
+	for i := range errors {
+		inserted, err := insertError(tx, &errors[i], true)
+		if err != nil {
+			return 0, fmt.Errorf("failed to restore error %s: %w", errors[i].ID, err)
+		}
+		if inserted {
+			restored++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return restored, nil
+}
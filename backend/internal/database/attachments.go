@@ -0,0 +1,87 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+const attachmentColumns = "id, project_id, event_id, name, content_type, size, storage_key, created_at"
+
+func scanAttachment(row scanner, a *models.Attachment) error {
+	return row.Scan(&a.ID, &a.ProjectID, &a.EventID, &a.Name, &a.ContentType, &a.Size, &a.StorageKey, &a.CreatedAt)
+}
+
+func (db *DB) queryAttachments(query string, args ...interface{}) ([]models.Attachment, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []models.Attachment{}
+	for rows.Next() {
+		var a models.Attachment
+		if err := scanAttachment(rows, &a); err != nil {
+			return nil, fmt.Errorf("failed to scan attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func (db *DB) CreateAttachment(a *models.Attachment) error {
+	_, err := db.Exec("INSERT INTO attachments ("+attachmentColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		a.ID, a.ProjectID, a.EventID, a.Name, a.ContentType, a.Size, a.StorageKey, a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create attachment: %w", err)
+	}
+	return nil
+}
+
+func (db *DB) GetAttachment(id uuid.UUID) (*models.Attachment, error) {
+	var a models.Attachment
+	err := scanAttachment(db.QueryRow("SELECT "+attachmentColumns+" FROM attachments WHERE id = $1", id), &a)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("attachment not found")
+		}
+		return nil, fmt.Errorf("failed to get attachment: %w", err)
+	}
+	return &a, nil
+}
+
+func (db *DB) ListEventAttachments(eventID uuid.UUID) ([]models.Attachment, error) {
+	return db.queryAttachments("SELECT "+attachmentColumns+" FROM attachments WHERE event_id = $1 ORDER BY created_at", eventID)
+}
+
+// ListExpiredAttachments returns up to limit attachments uploaded before the
+// given time, oldest first.
+func (db *DB) ListExpiredAttachments(before time.Time, limit int) ([]models.Attachment, error) {
+	return db.queryAttachments("SELECT "+attachmentColumns+" FROM attachments WHERE created_at < $1 ORDER BY created_at LIMIT $2", before, limit)
+}
+
+// AttachmentUsage returns the bytes stored for a project, or for events
+// without one when projectID is nil.
+func (db *DB) AttachmentUsage(projectID *uuid.UUID) (int64, error) {
+	var usage int64
+	err := db.QueryRow(
+		"SELECT COALESCE(SUM(size), 0) FROM attachments WHERE project_id IS NOT DISTINCT FROM $1",
+		projectID,
+	).Scan(&usage)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get attachment usage: %w", err)
+	}
+	return usage, nil
+}
+
+func (db *DB) DeleteAttachment(id uuid.UUID) error {
+	if _, err := db.Exec("DELETE FROM attachments WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	return nil
+}
@@ -4,18 +4,25 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 
 	"error-logs/internal/models"
+	"error-logs/internal/storage"
 )
 
 type DB struct {
 	*sql.DB
 }
 
+var (
+	_ storage.Store       = (*DB)(nil)
+	_ storage.Partitioner = (*DB)(nil)
+)
+
 func Connect(databaseURL string) (*DB, error) {
 	db, err := sql.Open("postgres", databaseURL)
 	if err != nil {
@@ -34,51 +41,131 @@ func Connect(databaseURL string) (*DB, error) {
 	return &DB{db}, nil
 }
 
+// errorColumns are the columns of the errors table in the order scanError
+// reads them and insertError writes them.
+const errorColumns = `id, timestamp, level, message, stack_trace, context, source,
+	environment, user_agent, ip_address, url, fingerprint, resolved,
+	count, first_seen, last_seen, created_at, updated_at, project_id,
+	geo_country, geo_region, geo_city, geo_asn, geo_as_org,
+	browser_name, browser_version, os_name, os_version, device_type, is_bot,
+	release, trace_id, span_id, request_id, user_info`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanError reads errorColumns into e, followed by any extra columns.
+func scanError(row scanner, e *models.Error, extra ...interface{}) error {
+	var contextJSON, userJSON []byte
+	dest := []interface{}{
+		&e.ID, &e.Timestamp, &e.Level, &e.Message, &e.StackTrace,
+		&contextJSON, &e.Source, &e.Environment, &e.UserAgent,
+		&e.IPAddress, &e.URL, &e.Fingerprint, &e.Resolved,
+		&e.Count, &e.FirstSeen, &e.LastSeen, &e.CreatedAt, &e.UpdatedAt,
+		&e.ProjectID,
+		&e.Country, &e.Region, &e.City, &e.ASN, &e.ASOrg,
+		&e.BrowserName, &e.BrowserVersion, &e.OSName, &e.OSVersion, &e.DeviceType, &e.IsBot,
+		&e.Release, &e.TraceID, &e.SpanID, &e.RequestID, &userJSON,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(contextJSON, &e.Context); err != nil {
+		e.Context = make(map[string]interface{})
+	}
+	if len(userJSON) > 0 {
+		if err := json.Unmarshal(userJSON, &e.User); err != nil {
+			return fmt.Errorf("failed to unmarshal user: %w", err)
+		}
+	}
+	return nil
+}
+
 func (db *DB) CreateError(error *models.Error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := insertError(tx, error, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertError writes an event and its tags. With skipExisting an event
+// already stored under the same ID and timestamp is left alone and false is
+// returned.
+func insertError(tx *sql.Tx, error *models.Error, skipExisting bool) (bool, error) {
 	query := `
-		INSERT INTO errors (
-			id, timestamp, level, message, stack_trace, context, source, 
-			environment, user_agent, ip_address, url, fingerprint, resolved, 
-			count, first_seen, last_seen, created_at, updated_at
-		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18
+		INSERT INTO errors (` + errorColumns + `, breadcrumbs, frames) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
+			$20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37
 		)`
+	if skipExisting {
+		query += " ON CONFLICT DO NOTHING"
+	}
 
 	contextJSON, err := json.Marshal(error.Context)
 	if err != nil {
-		return fmt.Errorf("failed to marshal context: %w", err)
+		return false, fmt.Errorf("failed to marshal context: %w", err)
+	}
+
+	var userJSON []byte
+	if error.User != nil {
+		if userJSON, err = json.Marshal(error.User); err != nil {
+			return false, fmt.Errorf("failed to marshal user: %w", err)
+		}
 	}
 
-	_, err = db.Exec(query,
+	var breadcrumbsJSON []byte
+	if len(error.Breadcrumbs) > 0 {
+		if breadcrumbsJSON, err = json.Marshal(error.Breadcrumbs); err != nil {
+			return false, fmt.Errorf("failed to marshal breadcrumbs: %w", err)
+		}
+	}
+
+	var framesJSON []byte
+	if len(error.Frames) > 0 {
+		if framesJSON, err = json.Marshal(error.Frames); err != nil {
+			return false, fmt.Errorf("failed to marshal frames: %w", err)
+		}
+	}
+
+	result, err := tx.Exec(query,
 		error.ID, error.Timestamp, error.Level, error.Message, error.StackTrace,
 		contextJSON, error.Source, error.Environment, error.UserAgent,
 		error.IPAddress, error.URL, error.Fingerprint, error.Resolved,
 		error.Count, error.FirstSeen, error.LastSeen, error.CreatedAt, error.UpdatedAt,
+		error.ProjectID,
+		error.Country, error.Region, error.City, error.ASN, error.ASOrg,
+		error.BrowserName, error.BrowserVersion, error.OSName, error.OSVersion, error.DeviceType, error.IsBot,
+		error.Release, error.TraceID, error.SpanID, error.RequestID, userJSON, breadcrumbsJSON, framesJSON,
 	)
+	if err != nil {
+		return false, err
+	}
+	if skipExisting {
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return false, err
+		}
+	}
 
-	return err
+	if err := insertTags(tx, error.ID, error.Timestamp, error.Tags); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-func (db *DB) GetErrors(limit, offset int, level, source string) ([]models.Error, int, error) {
+func (db *DB) GetErrors(limit, offset int, filter models.ErrorFilter) ([]models.Error, int, error) {
 	var errors []models.Error
 	var total int
 
 	// Build WHERE clause
-	whereClause := "WHERE 1=1"
-	args := []interface{}{}
-	argIndex := 1
-
-	if level != "" {
-		whereClause += fmt.Sprintf(" AND level = $%d", argIndex)
-		args = append(args, level)
-		argIndex++
-	}
-
-	if source != "" {
-		whereClause += fmt.Sprintf(" AND source = $%d", argIndex)
-		args = append(args, source)
-		argIndex++
-	}
+	whereClause, args := filterClause(filter)
+	argIndex := len(args) + 1
 
 	// Get total count
 	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM errors %s", whereClause)
@@ -89,13 +176,11 @@ func (db *DB) GetErrors(limit, offset int, level, source string) ([]models.Error
 
 	// Get errors
 	query := fmt.Sprintf(`
-		SELECT id, timestamp, level, message, stack_trace, context, source, 
-			   environment, user_agent, ip_address, url, fingerprint, resolved, 
-			   count, first_seen, last_seen, created_at, updated_at
+		SELECT %s
 		FROM errors %s
 		ORDER BY timestamp DESC
 		LIMIT $%d OFFSET $%d
-	`, whereClause, argIndex, argIndex+1)
+	`, errorColumns, whereClause, argIndex, argIndex+1)
 
 	args = append(args, limit, offset)
 
@@ -107,60 +192,187 @@ func (db *DB) GetErrors(limit, offset int, level, source string) ([]models.Error
 
 	for rows.Next() {
 		var e models.Error
-		var contextJSON []byte
-
-		err := rows.Scan(
-			&e.ID, &e.Timestamp, &e.Level, &e.Message, &e.StackTrace,
-			&contextJSON, &e.Source, &e.Environment, &e.UserAgent,
-			&e.IPAddress, &e.URL, &e.Fingerprint, &e.Resolved,
-			&e.Count, &e.FirstSeen, &e.LastSeen, &e.CreatedAt, &e.UpdatedAt,
-		)
-		if err != nil {
+		if err := scanError(rows, &e); err != nil {
 			return nil, 0, fmt.Errorf("failed to scan error: %w", err)
 		}
 
-		if err := json.Unmarshal(contextJSON, &e.Context); err != nil {
-			e.Context = make(map[string]interface{})
-		}
-
 		errors = append(errors, e)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read errors: %w", err)
+	}
+
+	if err := db.loadTags(errors); err != nil {
+		return nil, 0, err
+	}
 
 	return errors, total, nil
 }
 
-func (db *DB) GetErrorByID(id uuid.UUID) (*models.Error, error) {
-	query := `
-		SELECT id, timestamp, level, message, stack_trace, context, source, 
-			   environment, user_agent, ip_address, url, fingerprint, resolved, 
-			   count, first_seen, last_seen, created_at, updated_at
-		FROM errors WHERE id = $1
-	`
+// filterClause builds the WHERE clause and arguments for an error filter.
+func filterClause(filter models.ErrorFilter) (string, []interface{}) {
+	whereClause := "WHERE 1=1"
+	args := []interface{}{}
 
-	var e models.Error
-	var contextJSON []byte
+	add := func(column string, value interface{}) {
+		args = append(args, value)
+		whereClause += fmt.Sprintf(" AND %s = $%d", column, len(args))
+	}
 
-	err := db.QueryRow(query, id).Scan(
-		&e.ID, &e.Timestamp, &e.Level, &e.Message, &e.StackTrace,
-		&contextJSON, &e.Source, &e.Environment, &e.UserAgent,
-		&e.IPAddress, &e.URL, &e.Fingerprint, &e.Resolved,
-		&e.Count, &e.FirstSeen, &e.LastSeen, &e.CreatedAt, &e.UpdatedAt,
-	)
+	if filter.Level != "" {
+		add("level", filter.Level)
+	}
+	if filter.Source != "" {
+		add("source", filter.Source)
+	}
+	if filter.Country != "" {
+		add("geo_country", filter.Country)
+	}
+	if filter.Region != "" {
+		add("geo_region", filter.Region)
+	}
+	if filter.City != "" {
+		add("geo_city", filter.City)
+	}
+	if filter.ASN != 0 {
+		add("geo_asn", filter.ASN)
+	}
+	if filter.Browser != "" {
+		add("browser_name", filter.Browser)
+	}
+	if filter.BrowserVersion != "" {
+		// "17" matches 17, 17.4 and 17.4.1
+		args = append(args, filter.BrowserVersion)
+		whereClause += fmt.Sprintf(" AND (browser_version = $%[1]d OR browser_version LIKE $%[1]d || '.%%')", len(args))
+	}
+	if filter.OS != "" {
+		add("os_name", filter.OS)
+	}
+	if filter.Device != "" {
+		add("device_type", filter.Device)
+	}
+	if filter.Bot != nil {
+		add("is_bot", *filter.Bot)
+	}
+	if filter.Release != "" {
+		add("release", filter.Release)
+	}
+	if filter.TraceID != "" {
+		add("trace_id", filter.TraceID)
+	}
+	if filter.RequestID != "" {
+		add("request_id", filter.RequestID)
+	}
+	// Sorted so equal filters produce identical queries
+	keys := make([]string, 0, len(filter.Tags))
+	for key := range filter.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, key, filter.Tags[key])
+		whereClause += fmt.Sprintf(
+			" AND EXISTS (SELECT 1 FROM error_tags t WHERE t.error_id = errors.id AND t.key = $%d AND t.value = $%d)",
+			len(args)-1, len(args))
+	}
+
+	return whereClause, args
+}
+
+// breakdownColumns maps the fields errors can be grouped by to columns.
+var breakdownColumns = map[string]string{
+	"level":       "level",
+	"source":      "source",
+	"environment": "environment",
+	"country":     "geo_country",
+	"region":      "geo_region",
+	"city":        "geo_city",
+	"asn":         "geo_asn::text",
+	"as_org":      "geo_as_org",
+	"browser":     "browser_name",
+	// browser_version groups by name and major version, e.g. "Safari 17"
+	"browser_version": "browser_name || ' ' || split_part(browser_version, '.', 1)",
+	"os":              "os_name",
+	"os_version":      "os_name || ' ' || os_version",
+	"device":          "device_type",
+	"release":         "release",
+}
 
+// GetBreakdown counts errors since the given time grouped by one field,
+// most frequent first. Events without a value for the field are left out.
+func (db *DB) GetBreakdown(by string, filter models.ErrorFilter, since time.Time, limit int) ([]models.BreakdownItem, error) {
+	column, ok := breakdownColumns[by]
+	if !ok {
+		return nil, fmt.Errorf("unknown breakdown field %q", by)
+	}
+
+	whereClause, args := filterClause(filter)
+	args = append(args, since, limit)
+	query := fmt.Sprintf(`
+		SELECT %[1]s, COUNT(*) FROM errors
+		%[2]s AND timestamp >= $%[3]d AND %[1]s IS NOT NULL
+		GROUP BY %[1]s
+		ORDER BY COUNT(*) DESC
+		LIMIT $%[4]d
+	`, column, whereClause, len(args)-1, len(args))
+
+	rows, err := db.Query(query, args...)
 	if err != nil {
+		return nil, fmt.Errorf("failed to query breakdown: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.BreakdownItem{}
+	for rows.Next() {
+		var item models.BreakdownItem
+		if err := rows.Scan(&item.Value, &item.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan breakdown: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (db *DB) GetErrorByID(id uuid.UUID) (*models.Error, error) {
+	query := "SELECT " + errorColumns + ", breadcrumbs, frames FROM errors WHERE id = $1"
+
+	var e models.Error
+	if err := scanErrorDetail(db.QueryRow(query, id), &e); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("error not found")
 		}
 		return nil, fmt.Errorf("failed to get error: %w", err)
 	}
 
-	if err := json.Unmarshal(contextJSON, &e.Context); err != nil {
-		e.Context = make(map[string]interface{})
+	errors := []models.Error{e}
+	if err := db.loadTags(errors); err != nil {
+		return nil, err
 	}
+	e = errors[0]
 
 	return &e, nil
 }
 
+// scanErrorDetail reads errorColumns followed by breadcrumbs and frames.
+func scanErrorDetail(row scanner, e *models.Error) error {
+	var breadcrumbsJSON, framesJSON []byte
+	if err := scanError(row, e, &breadcrumbsJSON, &framesJSON); err != nil {
+		return err
+	}
+
+	if len(breadcrumbsJSON) > 0 {
+		if err := json.Unmarshal(breadcrumbsJSON, &e.Breadcrumbs); err != nil {
+			return fmt.Errorf("failed to unmarshal breadcrumbs: %w", err)
+		}
+	}
+	if len(framesJSON) > 0 {
+		if err := json.Unmarshal(framesJSON, &e.Frames); err != nil {
+			return fmt.Errorf("failed to unmarshal frames: %w", err)
+		}
+	}
+	return nil
+}
+
 func (db *DB) ResolveError(id uuid.UUID) error {
 	query := "UPDATE errors SET resolved = true, updated_at = NOW() WHERE id = $1"
 	_, err := db.Exec(query, id)
@@ -168,9 +380,19 @@ func (db *DB) ResolveError(id uuid.UUID) error {
 }
 
 func (db *DB) DeleteError(id uuid.UUID) error {
-	query := "DELETE FROM errors WHERE id = $1"
-	_, err := db.Exec(query, id)
-	return err
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM error_tags WHERE error_id = $1", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM errors WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (db *DB) GetStats() (*models.StatsResponse, error) {
@@ -211,14 +433,14 @@ func (db *DB) GetStats() (*models.StatsResponse, error) {
 
 func (db *DB) ValidateAPIKey(keyHash string) (*models.APIKey, error) {
 	query := `
-		SELECT id, key_hash, name, project_id, active, created_at, last_used
+		SELECT id, key_hash, name, project_id, scope, active, created_at, last_used
 		FROM api_keys WHERE key_hash = $1 AND active = true
 	`
 
 	var apiKey models.APIKey
 	err := db.QueryRow(query, keyHash).Scan(
 		&apiKey.ID, &apiKey.KeyHash, &apiKey.Name, &apiKey.ProjectID,
-		&apiKey.Active, &apiKey.CreatedAt, &apiKey.LastUsed,
+		&apiKey.Scope, &apiKey.Active, &apiKey.CreatedAt, &apiKey.LastUsed,
 	)
 
 	if err != nil {
@@ -234,3 +456,74 @@ func (db *DB) ValidateAPIKey(keyHash string) (*models.APIKey, error) {
 
 	return &apiKey, nil
 }
+
+func (db *DB) GetProjectSettings(projectID uuid.UUID) (*models.ProjectSettings, error) {
+	var settingsJSON []byte
+	err := db.QueryRow("SELECT settings FROM projects WHERE id = $1", projectID).Scan(&settingsJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("project not found")
+		}
+		return nil, fmt.Errorf("failed to get project settings: %w", err)
+	}
+
+	var settings models.ProjectSettings
+	if err := json.Unmarshal(settingsJSON, &settings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal project settings: %w", err)
+	}
+	return &settings, nil
+}
+
+func (db *DB) UpdateProjectSettings(projectID uuid.UUID, settings *models.ProjectSettings) error {
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project settings: %w", err)
+	}
+
+	result, err := db.Exec("UPDATE projects SET settings = $1 WHERE id = $2", settingsJSON, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to update project settings: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("project not found")
+	}
+	return nil
+}
+
+// ListProjectSettings returns the settings of every project.
+func (db *DB) ListProjectSettings() (map[uuid.UUID]*models.ProjectSettings, error) {
+	rows, err := db.Query("SELECT id, settings FROM projects")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query projects: %w", err)
+	}
+	defer rows.Close()
+
+	projects := make(map[uuid.UUID]*models.ProjectSettings)
+	for rows.Next() {
+		var id uuid.UUID
+		var settingsJSON []byte
+		if err := rows.Scan(&id, &settingsJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
+		var settings models.ProjectSettings
+		if err := json.Unmarshal(settingsJSON, &settings); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal settings of project %s: %w", id, err)
+		}
+		projects[id] = &settings
+	}
+	return projects, rows.Err()
+}
+
+// ClearIPAddresses removes stored client IPs from a project's errors that
+// occurred before the given time.
+func (db *DB) ClearIPAddresses(projectID uuid.UUID, before time.Time) (int64, error) {
+	result, err := db.Exec(`
+		UPDATE errors SET ip_address = NULL, user_info = user_info - 'ip_address'
+		WHERE project_id = $1 AND timestamp < $2
+			AND (ip_address IS NOT NULL OR user_info ? 'ip_address')
+	`, projectID, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear IP addresses: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"error-logs/internal/storage/storetest"
+)
+
+// TestStore runs against the Postgres database in TEST_DATABASE_URL, which
+// it migrates and leaves its test data in.
+func TestStore(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.MigrateUp(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	storetest.Run(t, db)
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+func (db *DB) GetProjectIDBySlug(slug string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := db.QueryRow("SELECT id FROM projects WHERE slug = $1", slug).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, fmt.Errorf("project not found")
+		}
+		return uuid.Nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	return id, nil
+}
+
+func (db *DB) CreateDeploy(deploy *models.Deploy) error {
+	_, err := db.Exec(`
+		INSERT INTO deploys (id, project_id, environment, release, timestamp, commit_sha, deployer, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, deploy.ID, deploy.ProjectID, deploy.Environment, deploy.Release, deploy.Timestamp,
+		deploy.Commit, deploy.Deployer, deploy.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create deploy: %w", err)
+	}
+	return nil
+}
+
+const deployColumns = "id, project_id, environment, release, timestamp, commit_sha, deployer, created_at"
+
+func scanDeploy(row scanner, d *models.Deploy) error {
+	return row.Scan(&d.ID, &d.ProjectID, &d.Environment, &d.Release, &d.Timestamp,
+		&d.Commit, &d.Deployer, &d.CreatedAt)
+}
+
+func (db *DB) GetDeploy(id uuid.UUID) (*models.Deploy, error) {
+	var d models.Deploy
+	err := scanDeploy(db.QueryRow("SELECT "+deployColumns+" FROM deploys WHERE id = $1", id), &d)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("deploy not found")
+		}
+		return nil, fmt.Errorf("failed to get deploy: %w", err)
+	}
+	return &d, nil
+}
+
+// ListDeploys returns deploys between since and until, newest first. An
+// empty environment matches all of them.
+func (db *DB) ListDeploys(environment string, since, until time.Time, limit int) ([]models.Deploy, error) {
+	query := "SELECT " + deployColumns + " FROM deploys WHERE timestamp >= $1 AND timestamp <= $2"
+	args := []interface{}{since, until}
+	if environment != "" {
+		args = append(args, environment)
+		query += fmt.Sprintf(" AND environment = $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY timestamp DESC LIMIT $%d", len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deploys: %w", err)
+	}
+	defer rows.Close()
+
+	deploys := []models.Deploy{}
+	for rows.Next() {
+		var d models.Deploy
+		if err := scanDeploy(rows, &d); err != nil {
+			return nil, fmt.Errorf("failed to scan deploy: %w", err)
+		}
+		deploys = append(deploys, d)
+	}
+	return deploys, rows.Err()
+}
+
+// GetDeployIssues returns issues whose first event falls between the deploy
+// and the next deploy to the same environment, i.e. issues the deploy
+// probably introduced.
+func (db *DB) GetDeployIssues(deploy *models.Deploy, limit int) ([]models.DeployIssue, error) {
+	projectClause := "project_id IS NULL"
+	args := []interface{}{deploy.Environment, deploy.Timestamp}
+	if deploy.ProjectID != nil {
+		args = append(args, *deploy.ProjectID)
+		projectClause = fmt.Sprintf("project_id = $%d", len(args))
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`
+		WITH next_deploy AS (
+			SELECT MIN(timestamp) AS ts FROM deploys
+			WHERE environment = $1 AND timestamp > $2 AND %[1]s
+		)
+		SELECT fingerprint,
+			(array_agg(id ORDER BY timestamp))[1],
+			(array_agg(level ORDER BY timestamp))[1],
+			(array_agg(message ORDER BY timestamp))[1],
+			MIN(timestamp), COUNT(*)
+		FROM errors
+		WHERE environment = $1 AND fingerprint IS NOT NULL AND %[1]s
+		GROUP BY fingerprint
+		HAVING MIN(timestamp) >= $2
+			AND MIN(timestamp) < COALESCE((SELECT ts FROM next_deploy), 'infinity'::timestamptz)
+		ORDER BY MIN(timestamp)
+		LIMIT $%[2]d
+	`, projectClause, len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deploy issues: %w", err)
+	}
+	defer rows.Close()
+
+	issues := []models.DeployIssue{}
+	for rows.Next() {
+		var i models.DeployIssue
+		if err := rows.Scan(&i.Fingerprint, &i.ErrorID, &i.Level, &i.Message, &i.FirstSeen, &i.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan deploy issue: %w", err)
+		}
+		issues = append(issues, i)
+	}
+	return issues, rows.Err()
+}
+
+// GetTrends counts errors per time bucket (hour, day, week or month) since
+// the given time.
+func (db *DB) GetTrends(bucket string, filter models.ErrorFilter, since time.Time) ([]models.TrendPoint, error) {
+	whereClause, args := filterClause(filter)
+	args = append(args, since, bucket)
+	query := fmt.Sprintf(`
+		SELECT date_trunc($%[3]d, timestamp) AS bucket, COUNT(*), COUNT(*) FILTER (WHERE resolved)
+		FROM errors %[1]s AND timestamp >= $%[2]d
+		GROUP BY bucket
+		ORDER BY bucket
+	`, whereClause, len(args)-1, len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trends: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.TrendPoint{}
+	for rows.Next() {
+		var p models.TrendPoint
+		if err := rows.Scan(&p.Timestamp, &p.ErrorCount, &p.ResolvedCount); err != nil {
+			return nil, fmt.Errorf("failed to scan trend: %w", err)
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
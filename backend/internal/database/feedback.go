@@ -0,0 +1,46 @@
+package database
+
+import (
+	"fmt"
+
+	"error-logs/internal/models"
+)
+
+func (db *DB) CreateFeedback(feedback *models.Feedback) error {
+	_, err := db.Exec(`
+		INSERT INTO user_feedback (id, project_id, event_id, name, email, comments, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, feedback.ID, feedback.ProjectID, feedback.EventID, feedback.Name, feedback.Email,
+		feedback.Comments, feedback.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create feedback: %w", err)
+	}
+	return nil
+}
+
+// GetIssueFeedback returns the newest feedback on any event of an issue.
+// Feedback is linked to events rather than issues because it can arrive
+// before its event has been processed.
+func (db *DB) GetIssueFeedback(fingerprint string, limit int) ([]models.Feedback, error) {
+	rows, err := db.Query(`
+		SELECT f.id, f.project_id, f.event_id, f.name, f.email, f.comments, f.created_at
+		FROM user_feedback f JOIN errors e ON e.id = f.event_id
+		WHERE e.fingerprint = $1
+		ORDER BY f.created_at DESC
+		LIMIT $2
+	`, fingerprint, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feedback: %w", err)
+	}
+	defer rows.Close()
+
+	feedback := []models.Feedback{}
+	for rows.Next() {
+		var f models.Feedback
+		if err := rows.Scan(&f.ID, &f.ProjectID, &f.EventID, &f.Name, &f.Email, &f.Comments, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feedback: %w", err)
+		}
+		feedback = append(feedback, f)
+	}
+	return feedback, rows.Err()
+}
@@ -0,0 +1,8 @@
+-- Drops everything the initial schema created, including all events
+
+DROP TRIGGER IF EXISTS update_errors_updated_at ON errors;
+DROP FUNCTION IF EXISTS update_updated_at_column();
+
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS projects;
+DROP TABLE IF EXISTS errors;
@@ -1,5 +1,5 @@
--- PostgreSQL Schema for Error Logging System
--- Create database: CREATE DATABASE error_logs;
+-- Initial schema for the Error Logging System, as the original
+-- database/schema.sql created it before migrations were introduced
 
 -- Extension for UUID generation
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
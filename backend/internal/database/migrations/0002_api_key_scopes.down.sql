@@ -0,0 +1,4 @@
+-- Ingest keys are removed rather than becoming full keys
+
+DELETE FROM api_keys WHERE scope = 'ingest';
+ALTER TABLE api_keys DROP COLUMN IF EXISTS scope;
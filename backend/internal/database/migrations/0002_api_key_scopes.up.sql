@@ -0,0 +1,10 @@
+-- API keys get a scope: full keys can use the whole API, ingest keys can
+-- only send events and are meant for browsers and report URLs
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scope VARCHAR(20) NOT NULL DEFAULT 'full';
+
+-- Development ingest key, alongside test-api-key
+INSERT INTO api_keys (key_hash, name, project_id, scope)
+SELECT encode(sha256('test-ingest-key'::bytea), 'hex'), 'Development Ingest Key', id, 'ingest'
+FROM projects WHERE slug = 'default'
+ON CONFLICT (key_hash) DO NOTHING;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS secrets;
+ALTER TABLE projects DROP COLUMN IF EXISTS settings;
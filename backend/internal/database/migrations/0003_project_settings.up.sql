@@ -0,0 +1,12 @@
+-- Per-project ingestion options (scrubbing, ...)
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}';
+
+-- Server-side secrets such as hashing salts, shared by every instance and
+-- never returned by the API; expired ones are deleted
+CREATE TABLE IF NOT EXISTS secrets (
+    name VARCHAR(100) PRIMARY KEY,
+    value TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
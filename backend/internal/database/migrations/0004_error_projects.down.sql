@@ -0,0 +1,7 @@
+-- Hashed addresses aren't addresses any more and are cleared
+
+DROP INDEX IF EXISTS idx_errors_project_timestamp;
+
+ALTER TABLE errors ALTER COLUMN ip_address TYPE INET
+    USING CASE WHEN ip_address LIKE 'hash:%' THEN NULL ELSE ip_address::inet END;
+ALTER TABLE errors DROP COLUMN IF EXISTS project_id;
@@ -0,0 +1,16 @@
+-- Events record the project of the API key that sent them, and the client
+-- IP becomes text so it can be stored truncated or hashed
+
+ALTER TABLE errors ADD COLUMN IF NOT EXISTS project_id UUID;
+
+-- Skipped when the column is already text, as host() only takes inet
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'errors'
+          AND column_name = 'ip_address') = 'inet' THEN
+        ALTER TABLE errors ALTER COLUMN ip_address TYPE TEXT USING host(ip_address);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_errors_project_timestamp ON errors(project_id, timestamp);
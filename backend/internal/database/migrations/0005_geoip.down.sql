@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_errors_geo_country;
+
+ALTER TABLE errors
+    DROP COLUMN IF EXISTS geo_country,
+    DROP COLUMN IF EXISTS geo_region,
+    DROP COLUMN IF EXISTS geo_city,
+    DROP COLUMN IF EXISTS geo_asn,
+    DROP COLUMN IF EXISTS geo_as_org;
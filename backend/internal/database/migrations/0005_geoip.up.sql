@@ -0,0 +1,10 @@
+-- Location and network looked up from the client IP in the GeoIP database
+
+ALTER TABLE errors
+    ADD COLUMN IF NOT EXISTS geo_country VARCHAR(2),
+    ADD COLUMN IF NOT EXISTS geo_region VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS geo_city VARCHAR(100),
+    ADD COLUMN IF NOT EXISTS geo_asn INTEGER,
+    ADD COLUMN IF NOT EXISTS geo_as_org TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_errors_geo_country ON errors(geo_country);
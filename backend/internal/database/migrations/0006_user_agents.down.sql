@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_errors_browser;
+DROP INDEX IF EXISTS idx_errors_os;
+
+ALTER TABLE errors
+    DROP COLUMN IF EXISTS browser_name,
+    DROP COLUMN IF EXISTS browser_version,
+    DROP COLUMN IF EXISTS os_name,
+    DROP COLUMN IF EXISTS os_version,
+    DROP COLUMN IF EXISTS device_type,
+    DROP COLUMN IF EXISTS is_bot;
@@ -0,0 +1,12 @@
+-- Browser, OS and device parsed from user_agent
+
+ALTER TABLE errors
+    ADD COLUMN IF NOT EXISTS browser_name VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS browser_version VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS os_name VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS os_version VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS device_type VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_errors_browser ON errors(browser_name, browser_version);
+CREATE INDEX IF NOT EXISTS idx_errors_os ON errors(os_name);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS issue_states;
+DROP TABLE IF EXISTS issue_releases;
+DROP TABLE IF EXISTS releases;
+
+DROP INDEX IF EXISTS idx_errors_release;
+ALTER TABLE errors DROP COLUMN IF EXISTS release;
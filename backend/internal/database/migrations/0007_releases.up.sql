@@ -0,0 +1,41 @@
+-- Releases of events, when each issue was seen in each release, and the
+-- resolution state of issues used to detect regressions. They belong to a
+-- project, since projects can use the same release names and their issues
+-- can share fingerprints; events without a project are keyed by the nil
+-- UUID, as key columns can't be NULL
+
+ALTER TABLE errors ADD COLUMN IF NOT EXISTS release VARCHAR(200);
+
+-- Releases, ordered by when they were first seen in the project
+CREATE TABLE IF NOT EXISTS releases (
+    project_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    version VARCHAR(200) NOT NULL,
+    first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (project_id, version)
+);
+
+-- When each issue (fingerprint) was seen in each release
+CREATE TABLE IF NOT EXISTS issue_releases (
+    project_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    fingerprint VARCHAR(64) NOT NULL,
+    release VARCHAR(200) NOT NULL,
+    first_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_seen TIMESTAMP WITH TIME ZONE NOT NULL,
+    count INTEGER NOT NULL DEFAULT 1,
+    PRIMARY KEY (project_id, fingerprint, release),
+    FOREIGN KEY (project_id, release) REFERENCES releases(project_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS issue_states (
+    project_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    fingerprint VARCHAR(64) NOT NULL,
+    status VARCHAR(30) NOT NULL, -- resolved, resolved_in_next_release, regressed
+    resolved_in VARCHAR(200), -- newest release when resolved
+    resolved_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    regressed_in VARCHAR(200),
+    regressed_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (project_id, fingerprint)
+);
+
+CREATE INDEX IF NOT EXISTS idx_errors_release ON errors(release);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_errors_environment_fingerprint;
+DROP TABLE IF EXISTS deploys;
@@ -0,0 +1,15 @@
+-- Deployments reported by CI, used to annotate trends
+
+CREATE TABLE IF NOT EXISTS deploys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    project_id UUID,
+    environment VARCHAR(50) NOT NULL,
+    release VARCHAR(200) NOT NULL,
+    timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+    commit_sha VARCHAR(100),
+    deployer VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_errors_environment_fingerprint ON errors(environment, fingerprint, timestamp);
+CREATE INDEX IF NOT EXISTS idx_deploys_environment_timestamp ON deploys(environment, timestamp);
@@ -0,0 +1 @@
+ALTER TABLE errors DROP COLUMN IF EXISTS breadcrumbs;
@@ -0,0 +1,3 @@
+-- What happened before each error, oldest first
+
+ALTER TABLE errors ADD COLUMN IF NOT EXISTS breadcrumbs JSONB;
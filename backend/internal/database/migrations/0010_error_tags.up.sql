@@ -0,0 +1,10 @@
+-- Indexed key/value tags of each event
+
+CREATE TABLE IF NOT EXISTS error_tags (
+    error_id UUID NOT NULL REFERENCES errors(id) ON DELETE CASCADE,
+    key VARCHAR(32) NOT NULL,
+    value VARCHAR(200) NOT NULL,
+    PRIMARY KEY (error_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_error_tags_key_value ON error_tags(key, value);
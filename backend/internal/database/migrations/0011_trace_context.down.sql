@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_errors_trace_id;
+DROP INDEX IF EXISTS idx_errors_request_id;
+
+ALTER TABLE errors
+    DROP COLUMN IF EXISTS trace_id,
+    DROP COLUMN IF EXISTS span_id,
+    DROP COLUMN IF EXISTS request_id;
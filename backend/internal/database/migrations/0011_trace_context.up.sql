@@ -0,0 +1,9 @@
+-- W3C trace context and request IDs of events
+
+ALTER TABLE errors
+    ADD COLUMN IF NOT EXISTS trace_id VARCHAR(32),
+    ADD COLUMN IF NOT EXISTS span_id VARCHAR(16),
+    ADD COLUMN IF NOT EXISTS request_id VARCHAR(200);
+
+CREATE INDEX IF NOT EXISTS idx_errors_trace_id ON errors(trace_id) WHERE trace_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_errors_request_id ON errors(request_id) WHERE request_id IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS issue_users;
+ALTER TABLE errors DROP COLUMN IF EXISTS user_info;
@@ -0,0 +1,15 @@
+-- The user who hit each event, and unique affected users per issue:
+-- HyperLogLogs rolled up from Redis, over all time (bucket_start is the
+-- epoch), per UTC day and per hour
+
+ALTER TABLE errors ADD COLUMN IF NOT EXISTS user_info JSONB;
+
+CREATE TABLE IF NOT EXISTS issue_users (
+    fingerprint VARCHAR(64) NOT NULL,
+    granularity VARCHAR(4) NOT NULL, -- all, day, hour
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    hll BYTEA NOT NULL,
+    users BIGINT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (fingerprint, granularity, bucket_start)
+);
@@ -0,0 +1,14 @@
+-- What end users said about errors they hit. Not a foreign key: feedback can
+-- arrive while its event is still queued
+
+CREATE TABLE IF NOT EXISTS user_feedback (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    project_id UUID,
+    event_id UUID NOT NULL,
+    name VARCHAR(100),
+    email VARCHAR(200),
+    comments TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_feedback_event ON user_feedback(event_id);
@@ -0,0 +1,17 @@
+-- Files uploaded for events; the content lives in blob storage under
+-- storage_key. Like feedback, they may arrive before their event
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    project_id UUID,
+    event_id UUID NOT NULL,
+    name VARCHAR(200) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(200) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_event ON attachments(event_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_project ON attachments(project_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_created_at ON attachments(created_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS source_maps;
+ALTER TABLE errors DROP COLUMN IF EXISTS frames;
@@ -0,0 +1,16 @@
+-- Parsed JavaScript stack frames of events, with source-mapped positions,
+-- and the source maps uploaded per project and release, keyed by the
+-- minified file they map; the content lives in blob storage under
+-- storage_key
+
+ALTER TABLE errors ADD COLUMN IF NOT EXISTS frames JSONB;
+
+CREATE TABLE IF NOT EXISTS source_maps (
+    project_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    release VARCHAR(200) NOT NULL,
+    name VARCHAR(200) NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (project_id, release, name)
+);
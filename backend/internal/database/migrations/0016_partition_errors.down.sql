@@ -0,0 +1,43 @@
+-- Copies the partitioned errors and error_tags back into plain tables
+
+DROP TRIGGER update_errors_updated_at ON errors;
+
+CREATE TABLE errors_unpartitioned (LIKE errors INCLUDING DEFAULTS);
+INSERT INTO errors_unpartitioned SELECT * FROM errors;
+CREATE TABLE error_tags_unpartitioned (
+    error_id UUID NOT NULL,
+    key VARCHAR(32) NOT NULL,
+    value VARCHAR(200) NOT NULL
+);
+INSERT INTO error_tags_unpartitioned (error_id, key, value)
+    SELECT t.error_id, t.key, t.value FROM error_tags t WHERE EXISTS (SELECT 1 FROM errors e WHERE e.id = t.error_id);
+
+DROP TABLE error_tags;
+DROP TABLE errors;
+
+ALTER TABLE errors_unpartitioned RENAME TO errors;
+ALTER TABLE errors ALTER COLUMN timestamp DROP NOT NULL;
+ALTER TABLE errors ADD PRIMARY KEY (id);
+ALTER TABLE error_tags_unpartitioned RENAME TO error_tags;
+ALTER TABLE error_tags ADD PRIMARY KEY (error_id, key);
+ALTER TABLE error_tags ADD CONSTRAINT error_tags_error_id_fkey
+    FOREIGN KEY (error_id) REFERENCES errors(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_errors_timestamp ON errors(timestamp DESC);
+CREATE INDEX idx_errors_level ON errors(level);
+CREATE INDEX idx_errors_source ON errors(source);
+CREATE INDEX idx_errors_fingerprint ON errors(fingerprint);
+CREATE INDEX idx_errors_resolved ON errors(resolved);
+CREATE INDEX idx_errors_environment ON errors(environment);
+CREATE INDEX idx_errors_project_timestamp ON errors(project_id, timestamp);
+CREATE INDEX idx_errors_geo_country ON errors(geo_country);
+CREATE INDEX idx_errors_browser ON errors(browser_name, browser_version);
+CREATE INDEX idx_errors_os ON errors(os_name);
+CREATE INDEX idx_errors_release ON errors(release);
+CREATE INDEX idx_errors_environment_fingerprint ON errors(environment, fingerprint, timestamp);
+CREATE INDEX idx_errors_trace_id ON errors(trace_id) WHERE trace_id IS NOT NULL;
+CREATE INDEX idx_errors_request_id ON errors(request_id) WHERE request_id IS NOT NULL;
+CREATE INDEX idx_error_tags_key_value ON error_tags(key, value);
+
+CREATE TRIGGER update_errors_updated_at BEFORE UPDATE
+    ON errors FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
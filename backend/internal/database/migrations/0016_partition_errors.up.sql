@@ -0,0 +1,92 @@
+-- Range-partitions errors by event timestamp so old events can be removed
+-- by dropping whole partitions. error_tags is partitioned the same way and
+-- carries its event's timestamp; the foreign key between them goes, since
+-- partitions of the two are dropped together.
+--
+-- The existing tables are attached as one legacy partition covering
+-- everything before tomorrow (UTC), without copying rows. The server
+-- creates later partitions ahead of time, and a default partition catches
+-- events outside every range.
+
+UPDATE errors SET timestamp = COALESCE(created_at, NOW()) WHERE timestamp IS NULL;
+ALTER TABLE errors ALTER COLUMN timestamp SET NOT NULL;
+
+ALTER TABLE error_tags DROP CONSTRAINT error_tags_error_id_fkey;
+ALTER TABLE error_tags ADD COLUMN timestamp TIMESTAMP WITH TIME ZONE;
+UPDATE error_tags t SET timestamp = e.timestamp FROM errors e WHERE e.id = t.error_id;
+DELETE FROM error_tags WHERE timestamp IS NULL;
+ALTER TABLE error_tags ALTER COLUMN timestamp SET NOT NULL;
+
+DROP TRIGGER update_errors_updated_at ON errors;
+
+ALTER TABLE errors RENAME TO errors_legacy;
+ALTER TABLE errors_legacy RENAME CONSTRAINT errors_pkey TO errors_legacy_pkey;
+ALTER TABLE error_tags RENAME TO error_tags_legacy;
+ALTER TABLE error_tags_legacy RENAME CONSTRAINT error_tags_pkey TO error_tags_legacy_pkey;
+ALTER INDEX idx_errors_timestamp RENAME TO errors_legacy_timestamp_idx;
+ALTER INDEX idx_errors_level RENAME TO errors_legacy_level_idx;
+ALTER INDEX idx_errors_source RENAME TO errors_legacy_source_idx;
+ALTER INDEX idx_errors_fingerprint RENAME TO errors_legacy_fingerprint_idx;
+ALTER INDEX idx_errors_resolved RENAME TO errors_legacy_resolved_idx;
+ALTER INDEX idx_errors_environment RENAME TO errors_legacy_environment_idx;
+ALTER INDEX idx_errors_project_timestamp RENAME TO errors_legacy_project_timestamp_idx;
+ALTER INDEX idx_errors_geo_country RENAME TO errors_legacy_geo_country_idx;
+ALTER INDEX idx_errors_browser RENAME TO errors_legacy_browser_idx;
+ALTER INDEX idx_errors_os RENAME TO errors_legacy_os_idx;
+ALTER INDEX idx_errors_release RENAME TO errors_legacy_release_idx;
+ALTER INDEX idx_errors_environment_fingerprint RENAME TO errors_legacy_environment_fingerprint_idx;
+ALTER INDEX idx_errors_trace_id RENAME TO errors_legacy_trace_id_idx;
+ALTER INDEX idx_errors_request_id RENAME TO errors_legacy_request_id_idx;
+ALTER INDEX idx_error_tags_key_value RENAME TO error_tags_legacy_key_value_idx;
+
+CREATE TABLE errors (LIKE errors_legacy INCLUDING DEFAULTS) PARTITION BY RANGE (timestamp);
+ALTER TABLE errors ADD PRIMARY KEY (id, timestamp);
+
+CREATE TABLE error_tags (LIKE error_tags_legacy INCLUDING DEFAULTS) PARTITION BY RANGE (timestamp);
+ALTER TABLE error_tags ADD PRIMARY KEY (error_id, key, timestamp);
+
+-- A partition's primary key must match its parent's, and a table can't have
+-- two, so the legacy keys are rebuilt with the timestamp
+ALTER TABLE errors_legacy DROP CONSTRAINT errors_legacy_pkey,
+    ADD CONSTRAINT errors_legacy_pkey PRIMARY KEY (id, timestamp);
+ALTER TABLE error_tags_legacy DROP CONSTRAINT error_tags_legacy_pkey,
+    ADD CONSTRAINT error_tags_legacy_pkey PRIMARY KEY (error_id, key, timestamp);
+
+-- The CHECK constraints prove the rows fit the partition bound, so attaching
+-- doesn't scan the tables again under its lock; they are redundant after
+DO $$
+DECLARE
+    bound TEXT := to_char(date_trunc('day', NOW() AT TIME ZONE 'UTC') + INTERVAL '1 day', 'YYYY-MM-DD') || ' 00:00:00+00';
+BEGIN
+    EXECUTE format('ALTER TABLE errors_legacy ADD CONSTRAINT errors_legacy_bound CHECK (timestamp < %L)', bound);
+    EXECUTE format('ALTER TABLE error_tags_legacy ADD CONSTRAINT error_tags_legacy_bound CHECK (timestamp < %L)', bound);
+    EXECUTE format('ALTER TABLE errors ATTACH PARTITION errors_legacy FOR VALUES FROM (MINVALUE) TO (%L)', bound);
+    EXECUTE format('ALTER TABLE error_tags ATTACH PARTITION error_tags_legacy FOR VALUES FROM (MINVALUE) TO (%L)', bound);
+END
+$$;
+
+ALTER TABLE errors_legacy DROP CONSTRAINT errors_legacy_bound;
+ALTER TABLE error_tags_legacy DROP CONSTRAINT error_tags_legacy_bound;
+
+CREATE TABLE errors_default PARTITION OF errors DEFAULT;
+CREATE TABLE error_tags_default PARTITION OF error_tags DEFAULT;
+
+-- Matching indexes on the legacy partitions are attached, not rebuilt
+CREATE INDEX idx_errors_timestamp ON errors(timestamp DESC);
+CREATE INDEX idx_errors_level ON errors(level);
+CREATE INDEX idx_errors_source ON errors(source);
+CREATE INDEX idx_errors_fingerprint ON errors(fingerprint);
+CREATE INDEX idx_errors_resolved ON errors(resolved);
+CREATE INDEX idx_errors_environment ON errors(environment);
+CREATE INDEX idx_errors_project_timestamp ON errors(project_id, timestamp);
+CREATE INDEX idx_errors_geo_country ON errors(geo_country);
+CREATE INDEX idx_errors_browser ON errors(browser_name, browser_version);
+CREATE INDEX idx_errors_os ON errors(os_name);
+CREATE INDEX idx_errors_release ON errors(release);
+CREATE INDEX idx_errors_environment_fingerprint ON errors(environment, fingerprint, timestamp);
+CREATE INDEX idx_errors_trace_id ON errors(trace_id) WHERE trace_id IS NOT NULL;
+CREATE INDEX idx_errors_request_id ON errors(request_id) WHERE request_id IS NOT NULL;
+CREATE INDEX idx_error_tags_key_value ON error_tags(key, value);
+
+CREATE TRIGGER update_errors_updated_at BEFORE UPDATE
+    ON errors FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
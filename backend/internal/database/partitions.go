@@ -0,0 +1,253 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Partition intervals of the errors table.
+const (
+	PartitionDay  = "day"
+	PartitionWeek = "week"
+)
+
+// partitionedTables are range-partitioned by event timestamp with identical
+// bounds; a partition of errors named errors_pYYYYMMDD has a twin
+// error_tags_pYYYYMMDD.
+var partitionedTables = []string{"errors", "error_tags"}
+
+// partitionLockTimeout keeps partition DDL from queueing behind long queries
+// while holding up everything behind it; maintenance retries on its next run.
+const partitionLockTimeout = "5s"
+
+type partition struct {
+	suffix string    // after "errors", e.g. "_p20250106" or "_legacy"
+	from   time.Time // zero for a partition from MINVALUE
+	to     time.Time
+}
+
+// rangePartitions lists the range partitions of errors, oldest first. The
+// default partition is not included.
+func (db *DB) rangePartitions() ([]partition, error) {
+	rows, err := db.Query(`
+		SELECT c.relname,
+			(regexp_match(pg_get_expr(c.relpartbound, c.oid), 'FROM \(''([^'']+)''\)'))[1]::timestamptz,
+			(regexp_match(pg_get_expr(c.relpartbound, c.oid), 'TO \(''([^'']+)''\)'))[1]::timestamptz
+		FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'errors'::regclass
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []partition
+	for rows.Next() {
+		var name string
+		var from, to sql.NullTime
+		if err := rows.Scan(&name, &from, &to); err != nil {
+			return nil, fmt.Errorf("failed to scan partition: %w", err)
+		}
+		if !to.Valid {
+			continue // the default partition
+		}
+		partitions = append(partitions, partition{suffix: name[len("errors"):], from: from.Time, to: to.Time})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].to.Before(partitions[j].to) })
+	return partitions, nil
+}
+
+// IsPartitioned reports whether the errors table is partitioned, i.e.
+// whether migration 16 has been applied.
+func (db *DB) IsPartitioned() (bool, error) {
+	var partitioned bool
+	err := db.QueryRow("SELECT COALESCE((SELECT relkind = 'p' FROM pg_class WHERE oid = to_regclass('errors')), false)").Scan(&partitioned)
+	if err != nil {
+		return false, fmt.Errorf("failed to check partitioning: %w", err)
+	}
+	return partitioned, nil
+}
+
+// EnsurePartitions creates partitions of the given interval so that events
+// up to until have one, continuing from the newest existing partition. It
+// returns the names of the partitions of errors it created.
+func (db *DB) EnsurePartitions(interval string, until time.Time) ([]string, error) {
+	partitions, err := db.rangePartitions()
+	if err != nil {
+		return nil, err
+	}
+
+	start := partitionStart(interval, time.Now().UTC())
+	if len(partitions) > 0 {
+		start = partitions[len(partitions)-1].to.UTC()
+	}
+
+	var created []string
+	for start.Before(until) {
+		end := partitionStart(interval, start)
+		if interval == PartitionWeek {
+			end = end.AddDate(0, 0, 7)
+		} else {
+			end = end.AddDate(0, 0, 1)
+		}
+
+		suffix := "_p" + start.Format("20060102")
+		if err := db.createPartition(suffix, start, end); err != nil {
+			return created, err
+		}
+		created = append(created, "errors"+suffix)
+		start = end
+	}
+	return created, nil
+}
+
+func (db *DB) createPartition(suffix string, from, to time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SET LOCAL lock_timeout = '" + partitionLockTimeout + "'"); err != nil {
+		return err
+	}
+	for _, table := range partitionedTables {
+		// A partition can't be created over rows already in the default
+		// partition, as after the server was down for a while: set them
+		// aside and route them again once it exists.
+		var stray bool
+		err := tx.QueryRow(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s_default WHERE timestamp >= $1 AND timestamp < $2)", table),
+			from, to).Scan(&stray)
+		if err != nil {
+			return fmt.Errorf("failed to check %s_default: %w", table, err)
+		}
+		if stray {
+			if err := moveOutOfDefault(tx, table, from, to); err != nil {
+				return err
+			}
+		}
+
+		// Bounds are formatted here, never user input; DDL takes no parameters
+		_, err = tx.Exec(fmt.Sprintf("CREATE TABLE %s%s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+			table, suffix, table, from.Format(time.RFC3339), to.Format(time.RFC3339)))
+		if err != nil {
+			return fmt.Errorf("failed to create partition %s%s: %w", table, suffix, err)
+		}
+
+		if stray {
+			if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %[1]s SELECT * FROM %[1]s_stray", table)); err != nil {
+				return fmt.Errorf("failed to move rows into %s%s: %w", table, suffix, err)
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func moveOutOfDefault(tx *sql.Tx, table string, from, to time.Time) error {
+	_, err := tx.Exec(fmt.Sprintf(`CREATE TEMP TABLE %[1]s_stray ON COMMIT DROP AS
+		SELECT * FROM %[1]s_default WHERE timestamp >= '%[2]s' AND timestamp < '%[3]s'`,
+		table, from.Format(time.RFC3339), to.Format(time.RFC3339)))
+	if err != nil {
+		return fmt.Errorf("failed to copy rows out of %s_default: %w", table, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s_default WHERE timestamp >= $1 AND timestamp < $2", table), from, to); err != nil {
+		return fmt.Errorf("failed to delete rows from %s_default: %w", table, err)
+	}
+	return nil
+}
+
+// DropPartitionsBefore drops the partitions, of errors and error_tags
+// alike, holding only events older than before, and returns the names of
+// those of errors. beforeDrop, when not nil, is called with the name of
+// each partition of errors first; if it fails the partition is kept.
+func (db *DB) DropPartitionsBefore(before time.Time, beforeDrop func(partition string) error) ([]string, error) {
+	partitions, err := db.rangePartitions()
+	if err != nil {
+		return nil, err
+	}
+	moved, err := db.partitionOldStrays(partitions)
+	if err != nil {
+		return nil, err
+	}
+	if moved {
+		if partitions, err = db.rangePartitions(); err != nil {
+			return nil, err
+		}
+	}
+
+	var dropped []string
+	for _, p := range partitions {
+		if p.to.After(before) {
+			break
+		}
+		if beforeDrop != nil {
+			if err := beforeDrop("errors" + p.suffix); err != nil {
+				return dropped, err
+			}
+		}
+		if err := db.dropPartition(p.suffix); err != nil {
+			return dropped, err
+		}
+		dropped = append(dropped, "errors"+p.suffix)
+	}
+	return dropped, nil
+}
+
+// partitionOldStrays gives events older than the oldest partition, which
+// arrive late or after their partition was dropped, a partition reaching up
+// to it. In the default partition retention would never remove them. It
+// reports whether it created one.
+func (db *DB) partitionOldStrays(partitions []partition) (bool, error) {
+	if len(partitions) == 0 || partitions[0].from.IsZero() {
+		return false, nil // nothing is older than a partition from MINVALUE
+	}
+	var oldest sql.NullTime
+	err := db.QueryRow("SELECT MIN(timestamp) FROM errors_default WHERE timestamp < $1", partitions[0].from).Scan(&oldest)
+	if err != nil {
+		return false, fmt.Errorf("failed to check errors_default: %w", err)
+	}
+	if !oldest.Valid {
+		return false, nil
+	}
+
+	from := partitionStart(PartitionDay, oldest.Time)
+	if err := db.createPartition("_p"+from.Format("20060102"), from, partitions[0].from.UTC()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (db *DB) dropPartition(suffix string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SET LOCAL lock_timeout = '" + partitionLockTimeout + "'"); err != nil {
+		return err
+	}
+	for _, table := range partitionedTables {
+		if _, err := tx.Exec(fmt.Sprintf("DROP TABLE %s%s", table, suffix)); err != nil {
+			return fmt.Errorf("failed to drop partition %s%s: %w", table, suffix, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// partitionStart returns the UTC midnight starting the day or ISO week (from
+// Monday) that t falls in.
+func partitionStart(interval string, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if interval == PartitionWeek {
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return day
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+// projectKey is the project_id of a project's releases and issue states.
+// Events without a project use the nil UUID, as key columns can't be NULL.
+func projectKey(projectID *uuid.UUID) uuid.UUID {
+	if projectID == nil {
+		return uuid.Nil
+	}
+	return *projectID
+}
+
+// RecordRelease notes that an issue was seen in a release at the given time,
+// keeping first/last seen both for the release and for the issue within it.
+func (db *DB) RecordRelease(projectID *uuid.UUID, fingerprint, release string, seenAt time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO releases (project_id, version, first_seen, last_seen) VALUES ($1, $2, $3, $3)
+		ON CONFLICT (project_id, version) DO UPDATE SET
+			first_seen = LEAST(releases.first_seen, EXCLUDED.first_seen),
+			last_seen = GREATEST(releases.last_seen, EXCLUDED.last_seen)
+	`, projectKey(projectID), release, seenAt)
+	if err != nil {
+		return fmt.Errorf("failed to record release: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO issue_releases (project_id, fingerprint, release, first_seen, last_seen, count) VALUES ($1, $2, $3, $4, $4, 1)
+		ON CONFLICT (project_id, fingerprint, release) DO UPDATE SET
+			first_seen = LEAST(issue_releases.first_seen, EXCLUDED.first_seen),
+			last_seen = GREATEST(issue_releases.last_seen, EXCLUDED.last_seen),
+			count = issue_releases.count + 1
+	`, projectKey(projectID), fingerprint, release, seenAt)
+	if err != nil {
+		return fmt.Errorf("failed to record issue release: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// IsNewerRelease reports whether release a first appeared in a project after
+// release b. Releases are ordered by when they were first seen rather than by
+// parsing version strings, which follow no common scheme. A release that
+// hasn't been recorded yet is newer than any known one.
+func (db *DB) IsNewerRelease(projectID *uuid.UUID, a, b string) (bool, error) {
+	var newer bool
+	err := db.QueryRow(`
+		SELECT COALESCE((SELECT first_seen FROM releases WHERE project_id = $1 AND version = $2), 'infinity'::timestamptz)
+			> COALESCE((SELECT first_seen FROM releases WHERE project_id = $1 AND version = $3), '-infinity'::timestamptz)
+	`, projectKey(projectID), a, b).Scan(&newer)
+	if err != nil {
+		return false, fmt.Errorf("failed to compare releases: %w", err)
+	}
+	return newer, nil
+}
+
+// LatestRelease returns a project's most recently first-seen release,
+// optionally limited to those an issue was seen in. It returns "" if there is
+// none.
+func (db *DB) LatestRelease(projectID *uuid.UUID, fingerprint string) (string, error) {
+	var release string
+	var err error
+	if fingerprint == "" {
+		err = db.QueryRow(`
+			SELECT version FROM releases WHERE project_id = $1 ORDER BY first_seen DESC LIMIT 1
+		`, projectKey(projectID)).Scan(&release)
+	} else {
+		err = db.QueryRow(`
+			SELECT r.version FROM issue_releases ir
+			JOIN releases r ON r.project_id = ir.project_id AND r.version = ir.release
+			WHERE ir.project_id = $1 AND ir.fingerprint = $2 ORDER BY r.first_seen DESC LIMIT 1
+		`, projectKey(projectID), fingerprint).Scan(&release)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get latest release: %w", err)
+	}
+	return release, nil
+}
+
+// GetIssueState returns the resolution state of an issue, or nil if it was
+// never resolved.
+func (db *DB) GetIssueState(projectID *uuid.UUID, fingerprint string) (*models.IssueState, error) {
+	var state models.IssueState
+	err := db.QueryRow(`
+		SELECT fingerprint, status, resolved_in, resolved_at, regressed_in, regressed_at
+		FROM issue_states WHERE project_id = $1 AND fingerprint = $2
+	`, projectKey(projectID), fingerprint).Scan(
+		&state.Fingerprint, &state.Status, &state.ResolvedIn, &state.ResolvedAt,
+		&state.RegressedIn, &state.RegressedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get issue state: %w", err)
+	}
+	return &state, nil
+}
+
+// ResolveIssue marks every event of an issue resolved and records how, so
+// later events can be checked for regressions.
+func (db *DB) ResolveIssue(projectID *uuid.UUID, fingerprint, status string, resolvedIn *string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO issue_states (project_id, fingerprint, status, resolved_in, resolved_at) VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (project_id, fingerprint) DO UPDATE SET
+			status = EXCLUDED.status, resolved_in = EXCLUDED.resolved_in, resolved_at = EXCLUDED.resolved_at
+	`, projectKey(projectID), fingerprint, status, resolvedIn)
+	if err != nil {
+		return fmt.Errorf("failed to record resolution: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE errors SET resolved = true WHERE fingerprint = $1 AND project_id IS NOT DISTINCT FROM $2
+	`, fingerprint, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve errors: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RegressIssue reopens a resolved issue that reappeared.
+func (db *DB) RegressIssue(projectID *uuid.UUID, fingerprint string, release *string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE issue_states SET status = $3, regressed_in = $4, regressed_at = NOW()
+		WHERE project_id = $1 AND fingerprint = $2
+	`, projectKey(projectID), fingerprint, models.IssueStatusRegressed, release)
+	if err != nil {
+		return fmt.Errorf("failed to record regression: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE errors SET resolved = false WHERE fingerprint = $1 AND project_id IS NOT DISTINCT FROM $2
+	`, fingerprint, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to reopen errors: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetIssueReleases lists the releases an issue was seen in, newest first.
+func (db *DB) GetIssueReleases(projectID *uuid.UUID, fingerprint string) ([]models.IssueRelease, error) {
+	rows, err := db.Query(`
+		SELECT ir.release, ir.first_seen, ir.last_seen, ir.count
+		FROM issue_releases ir JOIN releases r ON r.project_id = ir.project_id AND r.version = ir.release
+		WHERE ir.project_id = $1 AND ir.fingerprint = $2
+		ORDER BY r.first_seen DESC
+	`, projectKey(projectID), fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query issue releases: %w", err)
+	}
+	defer rows.Close()
+
+	releases := []models.IssueRelease{}
+	for rows.Next() {
+		var r models.IssueRelease
+		if err := rows.Scan(&r.Release, &r.FirstSeen, &r.LastSeen, &r.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan issue release: %w", err)
+		}
+		releases = append(releases, r)
+	}
+	return releases, rows.Err()
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"error-logs/internal/models"
+)
+
+// retentionClause builds the WHERE conditions selecting the events a rule
+// has expired.
+func retentionClause(rule models.RetentionRule) (string, []interface{}) {
+	args := []interface{}{rule.Before}
+	clause := "timestamp < $1"
+	if rule.ProjectID != nil {
+		args = append(args, *rule.ProjectID)
+		clause += fmt.Sprintf(" AND project_id = $%d", len(args))
+	} else {
+		clause += " AND project_id IS NULL"
+	}
+	if len(rule.Levels) > 0 {
+		args = append(args, pq.Array(rule.Levels))
+		clause += fmt.Sprintf(" AND level = ANY($%d)", len(args))
+	} else if len(rule.ExceptLevels) > 0 {
+		args = append(args, pq.Array(rule.ExceptLevels))
+		clause += fmt.Sprintf(" AND level <> ALL($%d)", len(args))
+	}
+	if rule.ResolvedOnly {
+		clause += " AND resolved"
+	}
+	return clause, args
+}
+
+// DeleteExpiredErrors deletes up to limit events, with their tags, that a
+// retention rule has expired, and returns how many it deleted. Callers
+// repeat it until fewer than limit are deleted, so no statement holds
+// locks on more than a batch of rows.
+func (db *DB) DeleteExpiredErrors(rule models.RetentionRule, limit int) (int64, error) {
+	where, args := retentionClause(rule)
+	args = append(args, limit)
+	query := fmt.Sprintf(`
+		WITH expired AS (
+			SELECT id, timestamp FROM errors WHERE %s LIMIT $%d
+		), deleted_tags AS (
+			DELETE FROM error_tags t USING expired x WHERE t.error_id = x.id AND t.timestamp = x.timestamp
+		)
+		DELETE FROM errors e USING expired x WHERE e.id = x.id AND e.timestamp = x.timestamp
+	`, where, len(args))
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired errors: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+// SaveSourceMap records an uploaded source map, replacing an earlier upload
+// of the same file for the project's release.
+func (db *DB) SaveSourceMap(sm *models.SourceMap) error {
+	_, err := db.Exec(`
+		INSERT INTO source_maps (project_id, release, name, size, storage_key, created_at) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (project_id, release, name) DO UPDATE SET
+			size = EXCLUDED.size, storage_key = EXCLUDED.storage_key, created_at = EXCLUDED.created_at
+	`, projectKey(sm.ProjectID), sm.Release, sm.Name, sm.Size, sm.StorageKey, sm.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save source map: %w", err)
+	}
+	return nil
+}
+
+// GetSourceMap returns the map uploaded for a minified file in a project's
+// release, or nil if there is none.
+func (db *DB) GetSourceMap(projectID *uuid.UUID, release, name string) (*models.SourceMap, error) {
+	sm := models.SourceMap{ProjectID: projectID}
+	err := db.QueryRow(`
+		SELECT release, name, size, storage_key, created_at FROM source_maps
+		WHERE project_id = $1 AND release = $2 AND name = $3
+	`, projectKey(projectID), release, name).Scan(&sm.Release, &sm.Name, &sm.Size, &sm.StorageKey, &sm.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get source map: %w", err)
+	}
+	return &sm, nil
+}
+
+func (db *DB) ListSourceMaps(projectID *uuid.UUID, release string) ([]models.SourceMap, error) {
+	rows, err := db.Query(`
+		SELECT release, name, size, storage_key, created_at FROM source_maps
+		WHERE project_id = $1 AND release = $2 ORDER BY name
+	`, projectKey(projectID), release)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query source maps: %w", err)
+	}
+	defer rows.Close()
+
+	maps := []models.SourceMap{}
+	for rows.Next() {
+		sm := models.SourceMap{ProjectID: projectID}
+		if err := rows.Scan(&sm.Release, &sm.Name, &sm.Size, &sm.StorageKey, &sm.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan source map: %w", err)
+		}
+		maps = append(maps, sm)
+	}
+	return maps, rows.Err()
+}
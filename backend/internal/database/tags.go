@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"error-logs/internal/models"
+)
+
+// insertTags stores an event's tags under its timestamp, which error_tags is
+// partitioned by along with errors.
+func insertTags(tx *sql.Tx, errorID uuid.UUID, timestamp time.Time, tags map[string]string) error {
+	for key, value := range tags {
+		_, err := tx.Exec("INSERT INTO error_tags (error_id, key, value, timestamp) VALUES ($1, $2, $3, $4)", errorID, key, value, timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to insert tag %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// loadTags fills in the tags of a page of errors with a single query.
+func (db *DB) loadTags(errors []models.Error) error {
+	if len(errors) == 0 {
+		return nil
+	}
+
+	index := make(map[uuid.UUID]int, len(errors))
+	ids := make([]string, len(errors))
+	for i, e := range errors {
+		index[e.ID] = i
+		ids[i] = e.ID.String()
+	}
+
+	rows, err := db.Query("SELECT error_id, key, value FROM error_tags WHERE error_id = ANY($1::uuid[])", pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var key, value string
+		if err := rows.Scan(&id, &key, &value); err != nil {
+			return fmt.Errorf("failed to scan tag: %w", err)
+		}
+		e := &errors[index[id]]
+		if e.Tags == nil {
+			e.Tags = make(map[string]string)
+		}
+		e.Tags[key] = value
+	}
+	return rows.Err()
+}
+
+// GetTagDistribution counts the values of every tag across an issue's
+// events, keeping the most common values of each key.
+func (db *DB) GetTagDistribution(fingerprint string, valuesPerKey int) ([]models.TagDistribution, error) {
+	rows, err := db.Query(`
+		SELECT key, value, count, total FROM (
+			SELECT t.key, t.value, COUNT(*) AS count,
+				SUM(COUNT(*)) OVER (PARTITION BY t.key) AS total,
+				ROW_NUMBER() OVER (PARTITION BY t.key ORDER BY COUNT(*) DESC, t.value) AS rank
+			FROM error_tags t JOIN errors e ON e.id = t.error_id
+			WHERE e.fingerprint = $1
+			GROUP BY t.key, t.value
+		) ranked
+		WHERE rank <= $2
+		ORDER BY total DESC, key, count DESC
+	`, fingerprint, valuesPerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag distribution: %w", err)
+	}
+	defer rows.Close()
+
+	distribution := []models.TagDistribution{}
+	for rows.Next() {
+		var key string
+		var value models.TagValueCount
+		var total int
+		if err := rows.Scan(&key, &value.Value, &value.Count, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan tag distribution: %w", err)
+		}
+		if n := len(distribution); n == 0 || distribution[n-1].Key != key {
+			distribution = append(distribution, models.TagDistribution{Key: key, Total: total})
+		}
+		last := &distribution[len(distribution)-1]
+		last.Values = append(last.Values, value)
+	}
+	return distribution, rows.Err()
+}
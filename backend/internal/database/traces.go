@@ -0,0 +1,33 @@
+package database
+
+import (
+	"fmt"
+
+	"error-logs/internal/models"
+)
+
+// GetTraceErrors returns the errors reported for a trace, oldest first.
+func (db *DB) GetTraceErrors(traceID string, limit int) ([]models.Error, error) {
+	rows, err := db.Query("SELECT "+errorColumns+" FROM errors WHERE trace_id = $1 ORDER BY timestamp LIMIT $2", traceID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trace errors: %w", err)
+	}
+	defer rows.Close()
+
+	errors := []models.Error{}
+	for rows.Next() {
+		var e models.Error
+		if err := scanError(rows, &e); err != nil {
+			return nil, fmt.Errorf("failed to scan error: %w", err)
+		}
+		errors = append(errors, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read trace errors: %w", err)
+	}
+
+	if err := db.loadTags(errors); err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
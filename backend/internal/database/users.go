@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"error-logs/internal/models"
+)
+
+// GetUserRollups returns the stored HyperLogLogs of the given buckets.
+// Buckets that were never rolled up are left out.
+func (db *DB) GetUserRollups(buckets []models.UserBucket) ([]models.UserRollup, error) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+
+	fingerprints := make([]string, len(buckets))
+	granularities := make([]string, len(buckets))
+	starts := make([]int64, len(buckets))
+	for i, b := range buckets {
+		fingerprints[i], granularities[i], starts[i] = b.Fingerprint, b.Granularity, b.Start.Unix()
+	}
+
+	rows, err := db.Query(`
+		SELECT u.fingerprint, u.granularity, u.bucket_start, u.hll, u.users
+		FROM issue_users u
+		JOIN unnest($1::text[], $2::text[], $3::bigint[]) AS b(fingerprint, granularity, start)
+			ON u.fingerprint = b.fingerprint AND u.granularity = b.granularity
+			AND u.bucket_start = to_timestamp(b.start)
+	`, pq.Array(fingerprints), pq.Array(granularities), pq.Array(starts))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user rollups: %w", err)
+	}
+	defer rows.Close()
+
+	var rollups []models.UserRollup
+	for rows.Next() {
+		var r models.UserRollup
+		if err := rows.Scan(&r.Bucket.Fingerprint, &r.Bucket.Granularity, &r.Bucket.Start, &r.HLL, &r.Users); err != nil {
+			return nil, fmt.Errorf("failed to scan user rollup: %w", err)
+		}
+		r.Bucket.Start = r.Bucket.Start.UTC()
+		rollups = append(rollups, r)
+	}
+	return rollups, rows.Err()
+}
+
+// SaveUserRollups stores HyperLogLogs copied from Redis, replacing older
+// copies. Callers merge the old copy into Redis first, so nothing is lost.
+func (db *DB) SaveUserRollups(rollups []models.UserRollup) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, r := range rollups {
+		_, err := tx.Exec(`
+			INSERT INTO issue_users (fingerprint, granularity, bucket_start, hll, users, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (fingerprint, granularity, bucket_start) DO UPDATE SET
+				hll = EXCLUDED.hll, users = EXCLUDED.users, updated_at = EXCLUDED.updated_at
+		`, r.Bucket.Fingerprint, r.Bucket.Granularity, r.Bucket.Start, r.HLL, r.Users, now)
+		if err != nil {
+			return fmt.Errorf("failed to save user rollup: %w", err)
+		}
+	}
+	return tx.Commit()
+}
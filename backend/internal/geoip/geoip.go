@@ -0,0 +1,171 @@
+// Package geoip looks up the location and network of client IPs in locally
+// mounted MaxMind databases (GeoIP2/GeoLite2 City or Country, and ASN).
+package geoip
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type Location struct {
+	Country string // ISO 3166-1 alpha-2
+	Region  string
+	City    string
+	ASN     uint
+	ASOrg   string
+}
+
+type cityRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// DB holds the location and ASN databases. Either may be absent. A nil *DB
+// is valid and finds nothing.
+type DB struct {
+	city *file
+	asn  *file
+}
+
+// file is one database that is swapped out when it changes on disk.
+type file struct {
+	path    string
+	reader  atomic.Pointer[maxminddb.Reader]
+	modTime time.Time
+	size    int64
+}
+
+// Open loads the databases at the given paths; an empty path skips that
+// database.
+func Open(cityPath, asnPath string) (*DB, error) {
+	db := &DB{}
+	var err error
+	if cityPath != "" {
+		if db.city, err = openFile(cityPath); err != nil {
+			return nil, err
+		}
+	}
+	if asnPath != "" {
+		if db.asn, err = openFile(asnPath); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func openFile(path string) (*file, error) {
+	f := &file{path: path}
+	if _, err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// reload swaps in the file if it changed since the last load. The whole file
+// is read into memory rather than mapped, so lookups still holding the old
+// reader are unaffected by the swap.
+func (f *file) reload() (bool, error) {
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat GeoIP database: %w", err)
+	}
+	if fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to open GeoIP database %s: %w", f.path, err)
+	}
+
+	f.reader.Store(reader)
+	f.modTime, f.size = fi.ModTime(), fi.Size()
+	log.Printf("Loaded GeoIP database %s (%s, built %s)", f.path, reader.Metadata.DatabaseType,
+		time.Unix(int64(reader.Metadata.BuildEpoch), 0).UTC().Format("2006-01-02"))
+	return true, nil
+}
+
+// Lookup returns what the databases know about ip, or nil.
+func (db *DB) Lookup(ip net.IP) *Location {
+	if db == nil || ip == nil {
+		return nil
+	}
+
+	var loc Location
+	found := false
+
+	if db.city != nil {
+		var rec cityRecord
+		if err := db.city.reader.Load().Lookup(ip, &rec); err == nil {
+			loc.Country = rec.Country.ISOCode
+			if len(rec.Subdivisions) > 0 {
+				loc.Region = rec.Subdivisions[0].Names["en"]
+			}
+			loc.City = rec.City.Names["en"]
+			found = loc.Country != "" || loc.Region != "" || loc.City != ""
+		}
+	}
+	if db.asn != nil {
+		var rec asnRecord
+		if err := db.asn.reader.Load().Lookup(ip, &rec); err == nil && rec.Number != 0 {
+			loc.ASN = rec.Number
+			loc.ASOrg = rec.Organization
+			found = true
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return &loc
+}
+
+// Watch reloads databases that were replaced on disk until ctx is cancelled.
+// A file that fails to load keeps the previous version in use.
+func (db *DB) Watch(ctx context.Context, interval time.Duration) {
+	if db == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, f := range []*file{db.city, db.asn} {
+			if f == nil {
+				continue
+			}
+			if _, err := f.reload(); err != nil {
+				log.Printf("Failed to reload GeoIP database: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+// maxAttachmentsPerUpload bounds the files in one multipart request.
+const maxAttachmentsPerUpload = 10
+
+// CreateAttachments stores the files of a multipart/form-data upload against
+// an event. Parts are streamed to storage one at a time rather than buffered.
+func (h *ErrorHandler) CreateAttachments(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid error ID", http.StatusBadRequest)
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected multipart/form-data", http.StatusBadRequest)
+		return
+	}
+
+	attachments := []models.Attachment{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Invalid multipart body", http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue // form fields other than files
+		}
+		if len(attachments) == maxAttachmentsPerUpload {
+			part.Close()
+			http.Error(w, "Too many files in one upload", http.StatusBadRequest)
+			return
+		}
+
+		attachment, err := h.errorService.CreateAttachment(r.Context(), eventID, part.FileName(), part.Header.Get("Content-Type"), part)
+		part.Close()
+		if err != nil {
+			switch {
+			case strings.HasPrefix(err.Error(), "invalid attachment"):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case strings.HasPrefix(err.Error(), "attachment too large"):
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			default:
+				log.Printf("Failed to store attachment: %v", err)
+				http.Error(w, "Failed to store attachment", http.StatusInternalServerError)
+			}
+			return
+		}
+		attachments = append(attachments, *attachment)
+	}
+
+	if len(attachments) == 0 {
+		http.Error(w, "No files uploaded", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"attachments": attachments})
+}
+
+func (h *ErrorHandler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid error ID", http.StatusBadRequest)
+		return
+	}
+
+	attachments, err := h.errorService.ListAttachments(r.Context(), eventID)
+	if err != nil {
+		log.Printf("Failed to list attachments: %v", err)
+		http.Error(w, "Failed to list attachments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"attachments": attachments})
+}
+
+// DownloadAttachment always serves the file as a download, so an uploaded
+// HTML or SVG file can't run script in the dashboard's origin.
+func (h *ErrorHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	attachment, content, err := h.errorService.OpenAttachment(r.Context(), id)
+	if err != nil {
+		if err.Error() == "attachment not found" {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to open attachment: %v", err)
+			http.Error(w, "Failed to get attachment", http.StatusInternalServerError)
+		}
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Failed to send attachment %s: %v", id, err)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"error-logs/internal/models"
+)
+
+type fakeKeys map[string]string // plaintext key -> scope
+
+func (k fakeKeys) ValidateAPIKey(keyHash string) (*models.APIKey, error) {
+	for key, scope := range k {
+		if fmt.Sprintf("%x", sha256.Sum256([]byte(key))) == keyHash {
+			return &models.APIKey{Name: key, Scope: scope, Active: true}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid API key")
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	keys := fakeKeys{"full-key": models.APIKeyScopeFull, "ingest-key": models.APIKeyScopeIngest}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name       string
+		middleware func(KeyValidator) func(http.Handler) http.Handler
+		header     string
+		query      string
+		want       int
+	}{
+		{"full key on full endpoint", APIKeyMiddleware, "full-key", "", http.StatusOK},
+		{"ingest key on full endpoint", APIKeyMiddleware, "ingest-key", "", http.StatusForbidden},
+		{"full key on ingest endpoint", IngestAPIKeyMiddleware, "full-key", "", http.StatusOK},
+		{"ingest key on ingest endpoint", IngestAPIKeyMiddleware, "ingest-key", "", http.StatusOK},
+		{"ingest key in query", QueryAPIKeyMiddleware, "", "ingest-key", http.StatusOK},
+		{"full key in query", QueryAPIKeyMiddleware, "", "full-key", http.StatusForbidden},
+		{"header ignored by query auth", QueryAPIKeyMiddleware, "ingest-key", "", http.StatusUnauthorized},
+		{"missing key", APIKeyMiddleware, "", "", http.StatusUnauthorized},
+		{"unknown key", IngestAPIKeyMiddleware, "nope", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/reports?key="+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set("X-API-Key", tt.header)
+			}
+			w := httptest.NewRecorder()
+			tt.middleware(keys)(ok).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactQueryKey(t *testing.T) {
+	var gotURI, gotKey string
+	h := RedactQueryKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURI = r.RequestURI
+		gotKey = r.URL.Query().Get("key")
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/reports?key=secret&x=1", nil))
+	if strings.Contains(gotURI, "secret") {
+		t.Errorf("RequestURI = %q still holds the key", gotURI)
+	}
+	if gotKey != "secret" {
+		t.Errorf("key = %q, want the handler to still see it", gotKey)
+	}
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/errors?limit=5", nil))
+	if gotURI != "/api/errors?limit=5" {
+		t.Errorf("RequestURI = %q, want it unchanged without a key", gotURI)
+	}
+}
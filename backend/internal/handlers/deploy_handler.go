@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+// CreateDeploy records a deployment, typically called from CI after a rollout.
+func (h *ErrorHandler) CreateDeploy(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateDeployRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	deploy, err := h.errorService.CreateDeploy(r.Context(), &req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid deploy") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Printf("Failed to create deploy: %v", err)
+			http.Error(w, "Failed to create deploy", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(deploy)
+}
+
+func (h *ErrorHandler) ListDeploys(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	deploys, err := h.errorService.ListDeploys(r.Context(), r.URL.Query().Get("environment"), limit)
+	if err != nil {
+		log.Printf("Failed to list deploys: %v", err)
+		http.Error(w, "Failed to list deploys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"deploys": deploys})
+}
+
+// GetDeployIssues lists issues first seen after the deploy and before the
+// next one to the same environment.
+func (h *ErrorHandler) GetDeployIssues(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid deploy ID", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.errorService.GetDeployIssues(r.Context(), id)
+	if err != nil {
+		if err.Error() == "deploy not found" {
+			http.Error(w, "Deploy not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to get deploy issues: %v", err)
+			http.Error(w, "Failed to get deploy issues", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -5,15 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
-	"error-logs/internal/database"
 	"error-logs/internal/models"
 	"error-logs/internal/services"
 )
@@ -28,11 +27,52 @@ func NewErrorHandler(errorService *services.ErrorService) *ErrorHandler {
 	}
 }
 
-// APIKeyMiddleware validates API keys
-func APIKeyMiddleware(db *database.DB) func(next http.Handler) http.Handler {
+// KeyValidator looks up an active API key by its SHA-256 hash.
+type KeyValidator interface {
+	ValidateAPIKey(keyHash string) (*models.APIKey, error)
+}
+
+// APIKeyMiddleware validates API keys, which must have the full scope
+func APIKeyMiddleware(keys KeyValidator) func(next http.Handler) http.Handler {
+	return apiKeyMiddleware(keys, headerAPIKey, models.APIKeyScopeFull)
+}
+
+// IngestAPIKeyMiddleware validates API keys of either scope, for endpoints
+// that only accept events.
+func IngestAPIKeyMiddleware(keys KeyValidator) func(next http.Handler) http.Handler {
+	return apiKeyMiddleware(keys, headerAPIKey, models.APIKeyScopeFull, models.APIKeyScopeIngest)
+}
+
+// QueryAPIKeyMiddleware validates ingest keys passed as the "key" query
+// parameter, for clients such as browsers that can't set custom headers.
+// URLs end up in logs and browser tooling, so full keys are refused.
+func QueryAPIKeyMiddleware(keys KeyValidator) func(next http.Handler) http.Handler {
+	return apiKeyMiddleware(keys, func(r *http.Request) string {
+		return r.URL.Query().Get("key")
+	}, models.APIKeyScopeIngest)
+}
+
+// RedactQueryKey hides the "key" query parameter from the request URI that
+// request logging records. Handlers read r.URL, which keeps it.
+func RedactQueryKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q := r.URL.Query(); q.Has("key") {
+			q.Set("key", "REDACTED")
+			r = r.WithContext(r.Context())
+			r.RequestURI = r.URL.EscapedPath() + "?" + q.Encode()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func headerAPIKey(r *http.Request) string {
+	return r.Header.Get("X-API-Key")
+}
+
+func apiKeyMiddleware(keys KeyValidator, extractKey func(r *http.Request) string, scopes ...string) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			apiKey := r.Header.Get("X-API-Key")
+			apiKey := extractKey(r)
 			if apiKey == "" {
 				http.Error(w, "API key required", http.StatusUnauthorized)
 				return
@@ -42,57 +82,25 @@ func APIKeyMiddleware(db *database.DB) func(next http.Handler) http.Handler {
 			hash := sha256.Sum256([]byte(apiKey))
 			keyHash := fmt.Sprintf("%x", hash)
 
-			_, err := db.ValidateAPIKey(keyHash)
+			key, err := keys.ValidateAPIKey(keyHash)
 			if err != nil {
 				http.Error(w, "Invalid API key", http.StatusUnauthorized)
 				return
 			}
+			if !slices.Contains(scopes, key.Scope) {
+				http.Error(w, fmt.Sprintf("API key scope %q not allowed here", key.Scope), http.StatusForbidden)
+				return
+			}
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(services.WithAPIKey(r.Context(), key)))
 		})
 	}
 }
 
-func (h *ErrorHandler) CreateError(w http.ResponseWriter, r *http.Request) {
-	var req models.CreateErrorRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
-	}
-
-	// Validate required fields
-	if req.Message == "" {
-		http.Error(w, "Message is required", http.StatusBadRequest)
-		return
-	}
-	if req.Level == "" {
-		req.Level = "error"
-	}
-	if req.Source == "" {
-		req.Source = "unknown"
-	}
-
-	// Extract client info
-	userAgent := r.Header.Get("User-Agent")
-	ipAddress := getClientIP(r)
-
-	error, err := h.errorService.CreateError(r.Context(), &req, userAgent, ipAddress)
-	if err != nil {
-		http.Error(w, "Failed to create error", http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(error)
-}
-
 func (h *ErrorHandler) GetErrors(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters
 	limitStr := r.URL.Query().Get("limit")
 	offsetStr := r.URL.Query().Get("offset")
-	level := r.URL.Query().Get("level")
-	source := r.URL.Query().Get("source")
 
 	limit := 50 // default
 	offset := 0 // default
@@ -109,7 +117,7 @@ func (h *ErrorHandler) GetErrors(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	response, err := h.errorService.GetErrors(r.Context(), limit, offset, level, source)
+	response, err := h.errorService.GetErrors(r.Context(), limit, offset, parseErrorFilter(r))
 	if err != nil {
 		http.Error(w, "Failed to get errors", http.StatusInternalServerError)
 		return
@@ -119,6 +127,44 @@ func (h *ErrorHandler) GetErrors(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// parseErrorFilter reads the filter query parameters shared by the list and
+// analytics endpoints.
+func parseErrorFilter(r *http.Request) models.ErrorFilter {
+	q := r.URL.Query()
+	filter := models.ErrorFilter{
+		Level:   q.Get("level"),
+		Source:  q.Get("source"),
+		Country: strings.ToUpper(q.Get("country")),
+		Region:  q.Get("region"),
+		City:    q.Get("city"),
+	}
+	if asn, err := strconv.Atoi(q.Get("asn")); err == nil && asn > 0 {
+		filter.ASN = asn
+	}
+	filter.Browser = q.Get("browser")
+	filter.BrowserVersion = q.Get("browser_version")
+	filter.OS = q.Get("os")
+	filter.Device = q.Get("device")
+	if bot, err := strconv.ParseBool(q.Get("bot")); err == nil {
+		filter.Bot = &bot
+	}
+	filter.Release = q.Get("release")
+	filter.TraceID = strings.ToLower(q.Get("trace_id"))
+	filter.RequestID = q.Get("request_id")
+	// Repeatable: tag=key:value
+	for _, tag := range q["tag"] {
+		key, value, ok := strings.Cut(tag, ":")
+		if !ok || !services.ValidTagKey(key) {
+			continue
+		}
+		if filter.Tags == nil {
+			filter.Tags = make(map[string]string)
+		}
+		filter.Tags[key] = value
+	}
+	return filter
+}
+
 func (h *ErrorHandler) GetError(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
@@ -149,9 +195,15 @@ func (h *ErrorHandler) ResolveError(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.errorService.ResolveError(r.Context(), id)
+	inNextRelease, _ := strconv.ParseBool(r.URL.Query().Get("in_next_release"))
+	err = h.errorService.ResolveError(r.Context(), id, inNextRelease)
 	if err != nil {
-		http.Error(w, "Failed to resolve error", http.StatusInternalServerError)
+		if err.Error() == "error not found" {
+			http.Error(w, "Error not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to resolve error: %v", err)
+			http.Error(w, "Failed to resolve error", http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -159,6 +211,102 @@ func (h *ErrorHandler) ResolveError(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "resolved"})
 }
 
+// GetIssueReleases returns the releases the error's issue was seen in and
+// its resolution state.
+func (h *ErrorHandler) GetIssueReleases(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid error ID", http.StatusBadRequest)
+		return
+	}
+
+	releases, err := h.errorService.GetIssueReleases(r.Context(), id)
+	if err != nil {
+		if err.Error() == "error not found" {
+			http.Error(w, "Error not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to get issue releases: %v", err)
+			http.Error(w, "Failed to get issue releases", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(releases)
+}
+
+func (h *ErrorHandler) GetTagDistribution(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid error ID", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := h.errorService.GetTagDistribution(r.Context(), id)
+	if err != nil {
+		if err.Error() == "error not found" {
+			http.Error(w, "Error not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to get tag distribution: %v", err)
+			http.Error(w, "Failed to get tag distribution", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+func (h *ErrorHandler) GetIssueUsers(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid error ID", http.StatusBadRequest)
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "week"
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "day"
+	}
+
+	users, err := h.errorService.GetIssueUsers(r.Context(), id, period, groupBy)
+	if err != nil {
+		switch {
+		case err.Error() == "error not found":
+			http.Error(w, "Error not found", http.StatusNotFound)
+		case strings.HasPrefix(err.Error(), "invalid users"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("Failed to get affected users: %v", err)
+			http.Error(w, "Failed to get affected users", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+func (h *ErrorHandler) GetTraceErrors(w http.ResponseWriter, r *http.Request) {
+	errors, err := h.errorService.GetTraceErrors(r.Context(), chi.URLParam(r, "traceID"))
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid trace") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Printf("Failed to get trace errors: %v", err)
+			http.Error(w, "Failed to get trace errors", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(errors)
+}
+
 func (h *ErrorHandler) DeleteError(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := uuid.Parse(idStr)
@@ -200,27 +348,61 @@ func (h *ErrorHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		// X-Forwarded-For can contain multiple IPs, take the first one
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+// GetBreakdown groups recent errors by one field, e.g.
+// /api/analytics/breakdown?by=country&period=day&level=error
+func (h *ErrorHandler) GetBreakdown(w http.ResponseWriter, r *http.Request) {
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		http.Error(w, "by is required", http.StatusBadRequest)
+		return
+	}
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "week"
+	}
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	breakdown, err := h.errorService.GetBreakdown(r.Context(), by, period, parseErrorFilter(r), limit)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid breakdown") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Printf("Failed to get breakdown: %v", err)
+			http.Error(w, "Failed to get breakdown", http.StatusInternalServerError)
 		}
+		return
 	}
 
-	// Check X-Real-IP header
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(breakdown)
+}
+
+// GetTrends returns error counts over time, annotated with deploys, e.g.
+// /api/analytics/trends?period=week&group_by=day
+func (h *ErrorHandler) GetTrends(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "week"
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "day"
 	}
 
-	// Fall back to RemoteAddr, but strip the port
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	trends, err := h.errorService.GetTrends(r.Context(), period, groupBy, parseErrorFilter(r))
 	if err != nil {
-		// If we can't split host:port, return as-is (might be just an IP)
-		return r.RemoteAddr
+		if strings.HasPrefix(err.Error(), "invalid trends") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Printf("Failed to get trends: %v", err)
+			http.Error(w, "Failed to get trends", http.StatusInternalServerError)
+		}
+		return
 	}
-	return host
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trends)
 }
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"error-logs/internal/models"
+)
+
+// maxFeedbackBody bounds feedback requests, which come from end users.
+const maxFeedbackBody = 64 << 10
+
+// CreateFeedback stores what an end user said happened before an error,
+// typically submitted from a crash dialog right after the event was sent.
+func (h *ErrorHandler) CreateFeedback(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateFeedbackRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFeedbackBody)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	feedback, err := h.errorService.CreateFeedback(r.Context(), &req)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid feedback") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			log.Printf("Failed to create feedback: %v", err)
+			http.Error(w, "Failed to create feedback", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(feedback)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"error-logs/internal/clientip"
+	"error-logs/internal/models"
+	"error-logs/internal/tracecontext"
+)
+
+const maxBatchSize = 100
+
+// Ingester stores or forwards a new event. It is implemented by
+// services.ErrorService and by the relay.
+type Ingester interface {
+	CreateError(ctx context.Context, req *models.CreateErrorRequest, userAgent, ipAddress string) (*models.Error, error)
+}
+
+// ipHeaderPolicy is implemented by ingesters that let each project choose
+// which proxy headers carry the client IP.
+type ipHeaderPolicy interface {
+	TrustedIPHeaders(ctx context.Context) []string
+}
+
+// IngestHandler serves the write-only ingestion endpoints, which are shared
+// between the full server and relay mode.
+type IngestHandler struct {
+	ingester Ingester
+	ips      *clientip.Resolver
+}
+
+func NewIngestHandler(ingester Ingester, ips *clientip.Resolver) *IngestHandler {
+	return &IngestHandler{
+		ingester: ingester,
+		ips:      ips,
+	}
+}
+
+func (h *IngestHandler) CreateError(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateErrorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Validate required fields
+	if !validateCreateRequest(&req) {
+		http.Error(w, "Message is required", http.StatusBadRequest)
+		return
+	}
+
+	// Extract client info
+	userAgent := r.Header.Get("User-Agent")
+	ipAddress := h.eventIP(&req, h.clientIP(r))
+	applyRequestTrace(r, &req)
+
+	error, err := h.ingester.CreateError(r.Context(), &req, userAgent, ipAddress)
+	if err != nil {
+		http.Error(w, "Failed to create error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(error)
+}
+
+func (h *IngestHandler) clientIP(r *http.Request) string {
+	headers := clientip.DefaultHeaders
+	if policy, ok := h.ingester.(ipHeaderPolicy); ok {
+		if trusted := policy.TrustedIPHeaders(r.Context()); trusted != nil {
+			headers = trusted
+		}
+	}
+	return h.ips.ClientIP(r, headers)
+}
+
+// eventIP returns the address a trusted relay forwarded for the event, or
+// ipAddress, that of the request, if there is none.
+func (h *IngestHandler) eventIP(req *models.CreateErrorRequest, ipAddress string) string {
+	if req.ClientIP != nil {
+		if ip := h.ips.Forwarded(ipAddress, *req.ClientIP); ip != "" {
+			return ip
+		}
+	}
+	return ipAddress
+}
+
+// applyRequestTrace fills in trace and request IDs the event doesn't carry
+// from the request delivering it: the W3C traceparent header, and the
+// request ID set by chi's RequestID middleware (the client's X-Request-Id if
+// it sent one).
+func applyRequestTrace(r *http.Request, req *models.CreateErrorRequest) {
+	if req.TraceID == nil {
+		if traceID, spanID, ok := tracecontext.Parse(r.Header.Get(tracecontext.Header)); ok {
+			req.TraceID, req.SpanID = &traceID, &spanID
+		}
+	}
+	if req.RequestID == nil {
+		if reqID := middleware.GetReqID(r.Context()); reqID != "" {
+			req.RequestID = &reqID
+		}
+	}
+}
+
+// CreateErrors accepts up to maxBatchSize events in one request so clients
+// can ship buffered events without one round trip each.
+func (h *IngestHandler) CreateErrors(w http.ResponseWriter, r *http.Request) {
+	var reqs []models.CreateErrorRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(reqs) == 0 || len(reqs) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("Batch must contain 1-%d errors", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+	for i := range reqs {
+		if !validateCreateRequest(&reqs[i]) {
+			http.Error(w, fmt.Sprintf("Message is required (item %d)", i), http.StatusBadRequest)
+			return
+		}
+	}
+
+	userAgent := r.Header.Get("User-Agent")
+	ipAddress := h.clientIP(r)
+	for i := range reqs {
+		applyRequestTrace(r, &reqs[i])
+	}
+
+	// Every item is tried, and those that fail are listed, so a client
+	// retries only them instead of duplicating the ones already stored
+	ids := make([]string, 0, len(reqs))
+	var failed []map[string]interface{}
+	for i := range reqs {
+		error, err := h.ingester.CreateError(r.Context(), &reqs[i], userAgent, h.eventIP(&reqs[i], ipAddress))
+		if err != nil {
+			log.Printf("Failed to create error %d of batch: %v", i, err)
+			failed = append(failed, map[string]interface{}{"index": i, "error": "Failed to create error"})
+			continue
+		}
+		ids = append(ids, error.ID.String())
+	}
+	if len(ids) == 0 {
+		http.Error(w, "Failed to create errors", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"accepted": len(ids),
+		"ids":      ids,
+	}
+	status := http.StatusCreated
+	if len(failed) > 0 {
+		response["failed"] = failed
+		status = http.StatusMultiStatus
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// validateCreateRequest checks required fields and fills in defaults.
+func validateCreateRequest(req *models.CreateErrorRequest) bool {
+	if req.Message == "" {
+		return false
+	}
+	if req.Level == "" {
+		req.Level = "error"
+	}
+	if req.Source == "" {
+		req.Source = "unknown"
+	}
+	return true
+}
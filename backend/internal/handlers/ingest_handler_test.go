@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/clientip"
+	"error-logs/internal/models"
+)
+
+// fakeIngester fails the messages in fail and records the others, with the
+// client IP they were ingested for.
+type fakeIngester struct {
+	fail   map[string]bool
+	stored []string
+	ips    []string
+}
+
+func (f *fakeIngester) CreateError(ctx context.Context, req *models.CreateErrorRequest, userAgent, ipAddress string) (*models.Error, error) {
+	if f.fail[req.Message] {
+		return nil, fmt.Errorf("queue unavailable")
+	}
+	f.stored = append(f.stored, req.Message)
+	f.ips = append(f.ips, ipAddress)
+	return &models.Error{ID: uuid.New(), Message: req.Message}, nil
+}
+
+func TestCreateErrorsBatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       map[string]bool
+		wantStatus int
+		wantStored []string
+		wantFailed []int
+	}{
+		{"all stored", nil, http.StatusCreated, []string{"a", "b", "c"}, nil},
+		{"some failed", map[string]bool{"b": true}, http.StatusMultiStatus, []string{"a", "c"}, []int{1}},
+		{"all failed", map[string]bool{"a": true, "b": true, "c": true}, http.StatusInternalServerError, nil, nil},
+	}
+
+	ips, err := clientip.New(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingester := &fakeIngester{fail: tt.fail}
+			h := NewIngestHandler(ingester, ips)
+
+			body := `[{"message":"a"},{"message":"b"},{"message":"c"}]`
+			w := httptest.NewRecorder()
+			h.CreateErrors(w, httptest.NewRequest("POST", "/api/errors/batch", strings.NewReader(body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			// Failures must not stop the rest of the batch
+			if !reflect.DeepEqual(ingester.stored, tt.wantStored) {
+				t.Errorf("stored = %v, want %v", ingester.stored, tt.wantStored)
+			}
+			if w.Code == http.StatusInternalServerError {
+				return
+			}
+
+			var resp struct {
+				Accepted int      `json:"accepted"`
+				IDs      []string `json:"ids"`
+				Failed   []struct {
+					Index int `json:"index"`
+				} `json:"failed"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			var failed []int
+			for _, f := range resp.Failed {
+				failed = append(failed, f.Index)
+			}
+			if resp.Accepted != len(tt.wantStored) || len(resp.IDs) != len(tt.wantStored) || !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
+
+// client_ip is only believed from a trusted relay, which is recognized after
+// the forwarding headers of trusted proxies are resolved.
+func TestForwardedClientIP(t *testing.T) {
+	body := `[{"message":"a","client_ip":"203.0.113.7"},{"message":"b","client_ip":"not an ip"},{"message":"c"}]`
+	lb, relay := []string{"10.0.0.1"}, []string{"198.51.100.5"}
+	tests := []struct {
+		name    string
+		proxies []string
+		relays  []string
+		xff     string
+		want    []string
+	}{
+		{"trusted relay", nil, []string{"192.0.2.0/24"}, "", []string{"203.0.113.7", "192.0.2.1", "192.0.2.1"}},
+		{"untrusted peer", nil, nil, "", []string{"192.0.2.1", "192.0.2.1", "192.0.2.1"}},
+		{"trusted proxy is not a relay", []string{"192.0.2.0/24"}, nil, "", []string{"192.0.2.1", "192.0.2.1", "192.0.2.1"}},
+		{"relay behind load balancer", lb, relay, "198.51.100.5", []string{"203.0.113.7", "198.51.100.5", "198.51.100.5"}},
+		{"spoofed behind load balancer", lb, relay, "198.51.100.66", []string{"198.51.100.66", "198.51.100.66", "198.51.100.66"}},
+		{"spoofed forwarding header", lb, relay, "198.51.100.5, 198.51.100.66", []string{"198.51.100.66", "198.51.100.66", "198.51.100.66"}},
+		{"load balancer is not a relay", lb, lb, "198.51.100.66", []string{"198.51.100.66", "198.51.100.66", "198.51.100.66"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ips, err := clientip.New(tt.proxies, tt.relays)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ingester := &fakeIngester{}
+			r := httptest.NewRequest("POST", "/api/errors/batch", strings.NewReader(body))
+			r.RemoteAddr = "192.0.2.1:1234"
+			if tt.xff != "" {
+				r.RemoteAddr = "10.0.0.1:1234"
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			NewIngestHandler(ingester, ips).CreateErrors(httptest.NewRecorder(), r)
+
+			if !reflect.DeepEqual(ingester.ips, tt.want) {
+				t.Errorf("ips = %v, want %v", ingester.ips, tt.want)
+			}
+		})
+	}
+}
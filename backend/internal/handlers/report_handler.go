@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"error-logs/internal/models"
+)
+
+const maxReportBodySize = 256 * 1024
+
+// BrowserReportSource is the source recorded for CSP and Reporting API events.
+const BrowserReportSource = "browser-report"
+
+// CreateReports accepts CSP violation reports (application/csp-report) and
+// Reporting API batches (application/reports+json) sent directly by browsers.
+func (h *IngestHandler) CreateReports(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		http.Error(w, "Invalid Content-Type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxReportBodySize)
+	userAgent := r.Header.Get("User-Agent")
+	ipAddress := h.clientIP(r)
+
+	var reports []models.BrowserReport
+	switch mediaType {
+	case "application/csp-report", "application/json":
+		var req models.CSPReportRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		reports = append(reports, cspToBrowserReport(&req.Report))
+
+	case "application/reports+json":
+		if err := json.NewDecoder(r.Body).Decode(&reports); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+	default:
+		http.Error(w, "Unsupported Content-Type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	for i := range reports {
+		report := &reports[i]
+		if report.Type == "" {
+			continue
+		}
+
+		ua := userAgent
+		if report.UserAgent != "" {
+			ua = report.UserAgent
+		}
+
+		if _, err := h.ingester.CreateError(r.Context(), browserReportToRequest(report), ua, ipAddress); err != nil {
+			log.Printf("Failed to store %s report: %v", report.Type, err)
+			http.Error(w, "Failed to store reports", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// cspToBrowserReport converts a legacy application/csp-report payload to the
+// equivalent Reporting API csp-violation report.
+func cspToBrowserReport(violation *models.CSPViolation) models.BrowserReport {
+	return models.BrowserReport{
+		Type: "csp-violation",
+		URL:  violation.DocumentURI,
+		Body: map[string]interface{}{
+			"documentURL":        violation.DocumentURI,
+			"referrer":           violation.Referrer,
+			"blockedURL":         violation.BlockedURI,
+			"effectiveDirective": firstNonEmpty(violation.EffectiveDirective, violation.ViolatedDirective),
+			"originalPolicy":     violation.OriginalPolicy,
+			"disposition":        violation.Disposition,
+			"sourceFile":         violation.SourceFile,
+			"lineNumber":         violation.LineNumber,
+			"columnNumber":       violation.ColumnNumber,
+			"statusCode":         violation.StatusCode,
+			"sample":             violation.ScriptSample,
+		},
+	}
+}
+
+func browserReportToRequest(report *models.BrowserReport) *models.CreateErrorRequest {
+	body := report.Body
+	if body == nil {
+		body = make(map[string]interface{})
+	}
+
+	req := &models.CreateErrorRequest{
+		Level:  "warning",
+		Source: BrowserReportSource,
+		Context: map[string]interface{}{
+			"report_type": report.Type,
+			"report_age":  report.Age,
+			"report":      body,
+		},
+	}
+
+	switch report.Type {
+	case "csp-violation":
+		req.Message = fmt.Sprintf("CSP violation: %s blocked %s",
+			stringField(body, "effectiveDirective"), firstNonEmpty(stringField(body, "blockedURL"), "inline"))
+		if stringField(body, "disposition") != "report" {
+			req.Level = "error"
+		}
+	case "deprecation":
+		req.Level = "info"
+		req.Message = "Deprecation: " + firstNonEmpty(stringField(body, "message"), stringField(body, "id"))
+	case "intervention":
+		req.Message = "Intervention: " + firstNonEmpty(stringField(body, "message"), stringField(body, "id"))
+	case "network-error":
+		req.Level = "error"
+		req.Message = strings.TrimSpace(fmt.Sprintf("Network error: %s %s %s",
+			stringField(body, "type"), stringField(body, "method"), report.URL))
+	default:
+		req.Message = fmt.Sprintf("Browser report: %s", report.Type)
+	}
+
+	if report.URL != "" {
+		url := report.URL
+		req.URL = &url
+	}
+
+	if file := stringField(body, "sourceFile"); file != "" {
+		frame := "    at " + file
+		if line := intField(body, "lineNumber"); line > 0 {
+			frame += fmt.Sprintf(":%d:%d", line, intField(body, "columnNumber"))
+		}
+		req.StackTrace = &frame
+	}
+
+	return req
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	if v, ok := m[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func intField(m map[string]interface{}, key string) int {
+	switch v := m[key].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
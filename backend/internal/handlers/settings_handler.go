@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"error-logs/internal/models"
+	"error-logs/internal/services"
+)
+
+// GetProjectSettings returns the settings of the project the API key belongs to.
+func (h *ErrorHandler) GetProjectSettings(w http.ResponseWriter, r *http.Request) {
+	projectID := services.ProjectIDFromContext(r.Context())
+	if projectID == nil {
+		http.Error(w, "API key is not associated with a project", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.errorService.GetProjectSettings(r.Context(), *projectID)
+	if err != nil {
+		if err.Error() == "project not found" {
+			http.Error(w, "Project not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to get project settings: %v", err)
+			http.Error(w, "Failed to get project settings", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// GetRetention returns the API key's project's effective retention policy
+// and what the last retention run deleted from it.
+func (h *ErrorHandler) GetRetention(w http.ResponseWriter, r *http.Request) {
+	projectID := services.ProjectIDFromContext(r.Context())
+	if projectID == nil {
+		http.Error(w, "API key is not associated with a project", http.StatusBadRequest)
+		return
+	}
+
+	retention, err := h.errorService.GetRetention(r.Context(), *projectID)
+	if err != nil {
+		if err.Error() == "project not found" {
+			http.Error(w, "Project not found", http.StatusNotFound)
+		} else {
+			log.Printf("Failed to get retention: %v", err)
+			http.Error(w, "Failed to get retention", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(retention)
+}
+
+// UpdateProjectSettings replaces the settings of the API key's project.
+func (h *ErrorHandler) UpdateProjectSettings(w http.ResponseWriter, r *http.Request) {
+	projectID := services.ProjectIDFromContext(r.Context())
+	if projectID == nil {
+		http.Error(w, "API key is not associated with a project", http.StatusBadRequest)
+		return
+	}
+
+	var settings models.ProjectSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.errorService.UpdateProjectSettings(r.Context(), *projectID, &settings); err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid settings"):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case err.Error() == "project not found":
+			http.Error(w, "Project not found", http.StatusNotFound)
+		default:
+			log.Printf("Failed to update project settings: %v", err)
+			http.Error(w, "Failed to update project settings", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"error-logs/internal/models"
+)
+
+// maxSourceMapsPerUpload bounds the files in one multipart request.
+const maxSourceMapsPerUpload = 50
+
+// releaseParam returns the release from the URL; versions may contain
+// characters such as "+" or "/" that clients have to escape.
+func releaseParam(r *http.Request) (string, bool) {
+	release, err := url.PathUnescape(chi.URLParam(r, "release"))
+	if err != nil || strings.TrimSpace(release) == "" {
+		return "", false
+	}
+	return release, true
+}
+
+// UploadSourceMaps stores the .map files of a multipart/form-data upload for
+// a release, typically from a build step after bundling.
+func (h *ErrorHandler) UploadSourceMaps(w http.ResponseWriter, r *http.Request) {
+	release, ok := releaseParam(r)
+	if !ok {
+		http.Error(w, "Invalid release", http.StatusBadRequest)
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected multipart/form-data", http.StatusBadRequest)
+		return
+	}
+
+	sourceMaps := []models.SourceMap{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Invalid multipart body", http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			part.Close()
+			continue
+		}
+		if len(sourceMaps) == maxSourceMapsPerUpload {
+			part.Close()
+			http.Error(w, "Too many files in one upload", http.StatusBadRequest)
+			return
+		}
+
+		sourceMap, err := h.errorService.UploadSourceMap(r.Context(), release, part.FileName(), part)
+		part.Close()
+		if err != nil {
+			switch {
+			case strings.HasPrefix(err.Error(), "invalid source map"):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case strings.HasPrefix(err.Error(), "source map too large"):
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			default:
+				log.Printf("Failed to store source map: %v", err)
+				http.Error(w, "Failed to store source map", http.StatusInternalServerError)
+			}
+			return
+		}
+		sourceMaps = append(sourceMaps, *sourceMap)
+	}
+
+	if len(sourceMaps) == 0 {
+		http.Error(w, "No files uploaded", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"sourcemaps": sourceMaps})
+}
+
+func (h *ErrorHandler) ListSourceMaps(w http.ResponseWriter, r *http.Request) {
+	release, ok := releaseParam(r)
+	if !ok {
+		http.Error(w, "Invalid release", http.StatusBadRequest)
+		return
+	}
+
+	sourceMaps, err := h.errorService.ListSourceMaps(r.Context(), release)
+	if err != nil {
+		log.Printf("Failed to list source maps: %v", err)
+		http.Error(w, "Failed to list source maps", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"sourcemaps": sourceMaps})
+}
@@ -8,6 +8,7 @@ import (
 
 type Error struct {
 	ID          uuid.UUID              `json:"id" db:"id"`
+	ProjectID   *uuid.UUID             `json:"project_id" db:"project_id"`
 	Timestamp   time.Time              `json:"timestamp" db:"timestamp"`
 	Level       string                 `json:"level" db:"level"`
 	Message     string                 `json:"message" db:"message"`
@@ -18,6 +19,7 @@ type Error struct {
 	UserAgent   *string                `json:"user_agent" db:"user_agent"`
 	IPAddress   *string                `json:"ip_address" db:"ip_address"`
 	URL         *string                `json:"url" db:"url"`
+	Release     *string                `json:"release" db:"release"`
 	Fingerprint *string                `json:"fingerprint" db:"fingerprint"`
 	Resolved    bool                   `json:"resolved" db:"resolved"`
 	Count       int                    `json:"count" db:"count"`
@@ -25,6 +27,119 @@ type Error struct {
 	LastSeen    time.Time              `json:"last_seen" db:"last_seen"`
 	CreatedAt   time.Time              `json:"created_at" db:"created_at"`
 	UpdatedAt   time.Time              `json:"updated_at" db:"updated_at"`
+
+	// Looked up from the client IP at ingestion
+	Country *string `json:"country" db:"geo_country"`
+	Region  *string `json:"region" db:"geo_region"`
+	City    *string `json:"city" db:"geo_city"`
+	ASN     *int    `json:"asn" db:"geo_asn"`
+	ASOrg   *string `json:"as_org" db:"geo_as_org"`
+
+	// Parsed from UserAgent at ingestion
+	BrowserName    *string `json:"browser_name" db:"browser_name"`
+	BrowserVersion *string `json:"browser_version" db:"browser_version"`
+	OSName         *string `json:"os_name" db:"os_name"`
+	OSVersion      *string `json:"os_version" db:"os_version"`
+	DeviceType     *string `json:"device_type" db:"device_type"`
+	IsBot          bool    `json:"is_bot" db:"is_bot"`
+
+	// Correlation with distributed traces and server logs
+	TraceID   *string `json:"trace_id" db:"trace_id"`
+	SpanID    *string `json:"span_id" db:"span_id"`
+	RequestID *string `json:"request_id" db:"request_id"`
+
+	User *EventUser `json:"user,omitempty" db:"user_info"`
+	// Unique users affected by the error's issue; only filled in on lists
+	AffectedUsers *int64 `json:"affected_users,omitempty"`
+
+	// Stored in error_tags so they can be filtered on
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Only loaded by the detail endpoint
+	Breadcrumbs []Breadcrumb `json:"breadcrumbs,omitempty" db:"breadcrumbs"`
+	Feedback    []Feedback   `json:"feedback,omitempty"` // on any event of the issue
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// Parsed from StackTrace when processed, with source map positions
+	Frames []StackFrame `json:"frames,omitempty" db:"frames"`
+}
+
+// StackFrame is a stack trace frame as reported, plus where it points in the
+// original source when a source map resolved it. Lines and columns are
+// 1-based.
+type StackFrame struct {
+	Function string         `json:"function,omitempty"`
+	File     string         `json:"file"`
+	Line     int            `json:"line"`
+	Column   int            `json:"column"`
+	Resolved *ResolvedFrame `json:"resolved,omitempty"`
+}
+
+type ResolvedFrame struct {
+	Function    string `json:"function,omitempty"`
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`
+	ContextLine string `json:"context_line,omitempty"`
+}
+
+// SourceMap is an uploaded source map for one minified file of a project's
+// release. Name is the minified file's base name, e.g. "index-abc123.js".
+type SourceMap struct {
+	ProjectID  *uuid.UUID `json:"project_id"`
+	Release    string     `json:"release"`
+	Name       string     `json:"name"`
+	Size       int64      `json:"size"`
+	StorageKey string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Attachment is a file uploaded for an event, such as a screenshot or log.
+type Attachment struct {
+	ID          uuid.UUID  `json:"id"`
+	ProjectID   *uuid.UUID `json:"project_id"`
+	EventID     uuid.UUID  `json:"event_id"`
+	Name        string     `json:"name"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"`
+	StorageKey  string     `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Breadcrumb is something that happened before an error, such as a click,
+// navigation, HTTP request or log line, oldest first.
+type Breadcrumb struct {
+	Timestamp *time.Time             `json:"timestamp,omitempty"`
+	Category  string                 `json:"category,omitempty"`
+	Level     string                 `json:"level,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventUser identifies the user who hit an error. Any one field is enough to
+// count them; they are tried in the order id, email, username, ip_address.
+type EventUser struct {
+	ID        string `json:"id,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Username  string `json:"username,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+}
+
+// Feedback is what an end user said about an error they ran into.
+type Feedback struct {
+	ID        uuid.UUID  `json:"id"`
+	ProjectID *uuid.UUID `json:"project_id"`
+	EventID   uuid.UUID  `json:"event_id"`
+	Name      string     `json:"name,omitempty"`
+	Email     string     `json:"email,omitempty"`
+	Comments  string     `json:"comments"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type CreateFeedbackRequest struct {
+	EventID  string `json:"event_id"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Comments string `json:"comments"`
 }
 
 type CreateErrorRequest struct {
@@ -35,6 +150,45 @@ type CreateErrorRequest struct {
 	Source      string                 `json:"source"`
 	Environment *string                `json:"environment"`
 	URL         *string                `json:"url"`
+	Release     *string                `json:"release"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Breadcrumbs []Breadcrumb           `json:"breadcrumbs,omitempty"`
+	// TraceID and SpanID default to the traceparent header of the request
+	// carrying the event, RequestID to its X-Request-Id.
+	TraceID   *string `json:"trace_id,omitempty"`
+	SpanID    *string `json:"span_id,omitempty"`
+	RequestID *string `json:"request_id,omitempty"`
+	// User who hit the error, counted towards the issue's affected users
+	User *EventUser `json:"user,omitempty"`
+	// Timestamp is when the event happened, for clients that deliver late
+	// (relays, agents). Defaults to the time the server received it.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// ClientIP is set by relays to the address the event came from. It is
+	// only honored from TRUSTED_RELAYS and then replaces the request's
+	// address, going through GeoIP and the project's IP settings.
+	ClientIP *string `json:"client_ip,omitempty"`
+}
+
+// ErrorFilter narrows error lists and breakdowns; zero values match anything.
+type ErrorFilter struct {
+	Level   string
+	Source  string
+	Country string
+	Region  string
+	City    string
+	ASN     int
+
+	Browser        string
+	BrowserVersion string // major version or a longer prefix, e.g. "17" or "17.4"
+	OS             string
+	Device         string
+	Bot            *bool
+
+	Release   string
+	TraceID   string
+	RequestID string
+
+	Tags map[string]string // every tag must match
 }
 
 type ErrorListResponse struct {
@@ -52,12 +206,297 @@ type StatsResponse struct {
 	ErrorsThisMonth int `json:"errors_this_month"`
 }
 
+type BreakdownResponse struct {
+	By     string          `json:"by"`
+	Period string          `json:"period"`
+	Values []BreakdownItem `json:"values"`
+}
+
+type BreakdownItem struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+const (
+	UserBucketAll  = "all"
+	UserBucketDay  = "day"
+	UserBucketHour = "hour"
+)
+
+// UserBucket identifies the set of users affected by an issue over all time
+// (Start is the Unix epoch), one UTC day or one hour.
+type UserBucket struct {
+	Fingerprint string
+	Granularity string
+	Start       time.Time
+}
+
+// UserRollup is the durable copy of a bucket's HyperLogLog.
+type UserRollup struct {
+	Bucket UserBucket
+	HLL    []byte
+	Users  int64
+}
+
+type UserCountPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Users     int64     `json:"users"`
+}
+
+// IssueUsersResponse counts the unique users affected by an issue, overall
+// and per time bucket. Counts are estimates with about 1% error.
+type IssueUsersResponse struct {
+	Fingerprint string           `json:"fingerprint"`
+	Users       int64            `json:"users"`
+	Period      string           `json:"period"`
+	GroupBy     string           `json:"group_by"`
+	DataPoints  []UserCountPoint `json:"data_points"`
+}
+
+// TraceErrorsResponse lists every error reported for one trace, oldest first.
+type TraceErrorsResponse struct {
+	TraceID string  `json:"trace_id"`
+	Errors  []Error `json:"errors"`
+}
+
+// TagDistribution is how often each value of a tag occurs in an issue.
+type TagDistribution struct {
+	Key    string          `json:"key"`
+	Total  int             `json:"total"`
+	Values []TagValueCount `json:"values"`
+}
+
+type TagValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type TagDistributionResponse struct {
+	Fingerprint string            `json:"fingerprint"`
+	Tags        []TagDistribution `json:"tags"`
+}
+
+const (
+	IssueStatusResolved              = "resolved"
+	IssueStatusResolvedInNextRelease = "resolved_in_next_release"
+	IssueStatusRegressed             = "regressed"
+)
+
+// IssueState is the resolution state of all events sharing a fingerprint.
+type IssueState struct {
+	Fingerprint string     `json:"fingerprint"`
+	Status      string     `json:"status"`
+	ResolvedIn  *string    `json:"resolved_in"`
+	ResolvedAt  time.Time  `json:"resolved_at"`
+	RegressedIn *string    `json:"regressed_in"`
+	RegressedAt *time.Time `json:"regressed_at"`
+}
+
+// IssueRelease is when an issue was seen in one release.
+type IssueRelease struct {
+	Release   string    `json:"release"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Count     int       `json:"count"`
+}
+
+type IssueReleasesResponse struct {
+	Fingerprint string         `json:"fingerprint"`
+	State       *IssueState    `json:"state"`
+	Releases    []IssueRelease `json:"releases"`
+}
+
+type Deploy struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	ProjectID   *uuid.UUID `json:"project_id" db:"project_id"`
+	Environment string     `json:"environment" db:"environment"`
+	Release     string     `json:"release" db:"release"`
+	Timestamp   time.Time  `json:"timestamp" db:"timestamp"`
+	Commit      *string    `json:"commit" db:"commit_sha"`
+	Deployer    *string    `json:"deployer" db:"deployer"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+type CreateDeployRequest struct {
+	// Project is a project slug; defaults to the API key's project.
+	Project     string     `json:"project"`
+	Environment string     `json:"environment"`
+	Release     string     `json:"release"`
+	Timestamp   *time.Time `json:"timestamp"`
+	Commit      *string    `json:"commit"`
+	Deployer    *string    `json:"deployer"`
+}
+
+// DeployIssue is an issue first seen between a deploy and the next one in
+// the same environment.
+type DeployIssue struct {
+	Fingerprint string    `json:"fingerprint"`
+	ErrorID     uuid.UUID `json:"error_id"`
+	Level       string    `json:"level"`
+	Message     string    `json:"message"`
+	FirstSeen   time.Time `json:"first_seen"`
+	Count       int       `json:"count"`
+}
+
+type DeployIssuesResponse struct {
+	Deploy *Deploy       `json:"deploy"`
+	Issues []DeployIssue `json:"issues"`
+}
+
+type TrendPoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	ErrorCount    int       `json:"error_count"`
+	ResolvedCount int       `json:"resolved_count"`
+}
+
+type TrendsResponse struct {
+	Period     string       `json:"period"`
+	GroupBy    string       `json:"group_by"`
+	DataPoints []TrendPoint `json:"data_points"`
+	// Deploys in the period, for annotating the chart
+	Deploys []Deploy `json:"deploys"`
+}
+
 type APIKey struct {
 	ID        uuid.UUID  `json:"id" db:"id"`
 	KeyHash   string     `json:"-" db:"key_hash"`
 	Name      string     `json:"name" db:"name"`
 	ProjectID *uuid.UUID `json:"project_id" db:"project_id"`
+	Scope     string     `json:"scope" db:"scope"`
 	Active    bool       `json:"active" db:"active"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	LastUsed  *time.Time `json:"last_used" db:"last_used"`
 }
+
+// API key scopes. Ingest keys can only send events, so they are safe to
+// embed in browsers and report URLs.
+const (
+	APIKeyScopeFull   = "full"
+	APIKeyScopeIngest = "ingest"
+)
+
+// CSPReportRequest is the legacy report-uri payload sent as application/csp-report.
+type CSPReportRequest struct {
+	Report CSPViolation `json:"csp-report"`
+}
+
+type CSPViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	Referrer           string `json:"referrer"`
+	BlockedURI         string `json:"blocked-uri"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effective-directive"`
+	OriginalPolicy     string `json:"original-policy"`
+	Disposition        string `json:"disposition"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	ColumnNumber       int    `json:"column-number"`
+	StatusCode         int    `json:"status-code"`
+	ScriptSample       string `json:"script-sample"`
+}
+
+// BrowserReport is a single entry of a Reporting API (application/reports+json) payload.
+type BrowserReport struct {
+	Type      string                 `json:"type"`
+	Age       int                    `json:"age"`
+	URL       string                 `json:"url"`
+	UserAgent string                 `json:"user_agent"`
+	Body      map[string]interface{} `json:"body"`
+}
+
+// ProjectSettings holds per-project ingestion options, stored as JSON in
+// projects.settings.
+type ProjectSettings struct {
+	Scrubbing   ScrubbingSettings  `json:"scrubbing"`
+	IP          IPSettings         `json:"ip"`
+	Breadcrumbs BreadcrumbSettings `json:"breadcrumbs"`
+	Retention   RetentionSettings  `json:"retention"`
+}
+
+// RetentionSettings decide how many days events are kept. In project
+// settings, zero values and missing levels fall back to the server's
+// defaults; in the effective policy, zero keeps events forever.
+type RetentionSettings struct {
+	// Days applies to levels not listed in Levels.
+	Days int `json:"days,omitempty"`
+	// Levels sets the days per level, e.g. {"debug": 3, "error": 90}.
+	Levels map[string]int `json:"levels,omitempty"`
+	// ResolvedDays removes resolved events sooner than their level would.
+	ResolvedDays int `json:"resolved_days,omitempty"`
+}
+
+// RetentionRule selects events that have expired under one part of a
+// policy. Levels empty means every level except ExceptLevels.
+type RetentionRule struct {
+	ProjectID    *uuid.UUID
+	Levels       []string
+	ExceptLevels []string
+	ResolvedOnly bool
+	Before       time.Time
+}
+
+// RetentionReport is what a retention run deleted.
+type RetentionReport struct {
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt time.Time              `json:"finished_at"`
+	Deleted    int64                  `json:"deleted"`
+	Rules      []RetentionRuleOutcome `json:"rules"`
+}
+
+type RetentionRuleOutcome struct {
+	ProjectID *uuid.UUID `json:"project_id,omitempty"`
+	Rule      string     `json:"rule"` // e.g. "level debug older than 3 days"
+	Before    time.Time  `json:"before"`
+	Deleted   int64      `json:"deleted"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type RetentionResponse struct {
+	Policy  RetentionSettings `json:"policy"`
+	LastRun *RetentionReport  `json:"last_run"`
+}
+
+// BreadcrumbSettings caps what is kept of each event's breadcrumbs; zero
+// values use the defaults.
+type BreadcrumbSettings struct {
+	// MaxCount keeps the most recent breadcrumbs, default 100.
+	MaxCount int `json:"max_count,omitempty"`
+	// MaxMessageLength truncates messages, default 1024 bytes.
+	MaxMessageLength int `json:"max_message_length,omitempty"`
+	// MaxDataBytes drops data larger than this once encoded, default 4096.
+	MaxDataBytes int `json:"max_data_bytes,omitempty"`
+}
+
+type ScrubbingSettings struct {
+	Disabled bool `json:"disabled"`
+	// Detectors lists the built-in detectors to run; null means all of them.
+	Detectors []string       `json:"detectors"`
+	Patterns  []ScrubPattern `json:"patterns"`
+	// DenyKeys are extra context keys whose values are always scrubbed,
+	// matched case-insensitively as substrings.
+	DenyKeys []string `json:"deny_keys"`
+	// Action is mask (default), hash or remove.
+	Action string `json:"action"`
+}
+
+type ScrubPattern struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+}
+
+type IPSettings struct {
+	// Mode is store (default), truncate, hash or drop.
+	Mode string `json:"mode"`
+	// IPv4Prefix and IPv6Prefix are the prefix lengths kept by truncate,
+	// defaulting to /24 and /48.
+	IPv4Prefix int `json:"ipv4_prefix,omitempty"`
+	IPv6Prefix int `json:"ipv6_prefix,omitempty"`
+	// TrustedHeaders are the proxy headers the client IP is read from, in
+	// order; null means Forwarded, X-Forwarded-For then X-Real-IP, an empty
+	// list means only the connection's peer address. Headers are only
+	// believed when sent by a trusted proxy (TRUSTED_PROXIES).
+	TrustedHeaders []string `json:"trusted_headers"`
+	// RetentionDays clears stored IPs once events are this old; 0 keeps them.
+	RetentionDays int `json:"retention_days,omitempty"`
+}
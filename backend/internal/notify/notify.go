@@ -0,0 +1,87 @@
+// Package notify tells operators about things that need a person to look at
+// them, such as user feedback and regressions, by posting them to a webhook.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TypeFeedback   = "feedback"
+	TypeRegression = "regression"
+
+	sendTimeout = 10 * time.Second
+)
+
+// Notification is the JSON body posted to the webhook.
+type Notification struct {
+	Type      string      `json:"type"`
+	ProjectID *uuid.UUID  `json:"project_id,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Webhook posts notifications to a URL. A nil Webhook is valid and sends
+// nothing, for installs without one configured.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// New returns a webhook posting to url, or nil if url is empty.
+func New(url string) *Webhook {
+	if url == "" {
+		return nil
+	}
+	return &Webhook{url: url, client: &http.Client{Timeout: sendTimeout}}
+}
+
+// Send posts a notification in the background so the request that caused
+// it isn't held up. Failures are logged without the notification's data,
+// which may hold personal details.
+func (w *Webhook) Send(n Notification) {
+	if w == nil {
+		return
+	}
+	if n.Timestamp.IsZero() {
+		n.Timestamp = time.Now().UTC()
+	}
+	go func() {
+		if err := w.post(n); err != nil {
+			log.Printf("Failed to send %s notification: %v", n.Type, err)
+		}
+	}()
+}
+
+func (w *Webhook) post(n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPost(t *testing.T) {
+	var got Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	if err := New(server.URL).post(Notification{Type: TypeFeedback, Data: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != TypeFeedback || got.Data != "hi" {
+		t.Errorf("received %+v", got)
+	}
+}
+
+func TestNilWebhook(t *testing.T) {
+	w := New("")
+	if w != nil {
+		t.Fatal("New(\"\") returned a webhook")
+	}
+	w.Send(Notification{Type: TypeFeedback})
+}
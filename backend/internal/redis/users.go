@@ -0,0 +1,182 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"error-logs/internal/models"
+)
+
+const (
+	UserBucketPrefix    = "issue_users:"
+	DirtyUserBucketsKey = "issue_users_dirty"
+)
+
+// userBucketTTL is how long Redis keeps each kind of bucket after its last
+// change. Older buckets are restored from their Postgres rollup when read.
+var userBucketTTL = map[string]time.Duration{
+	models.UserBucketHour: 8 * 24 * time.Hour,
+	models.UserBucketDay:  35 * 24 * time.Hour,
+}
+
+func userBucketKey(b models.UserBucket) string {
+	return fmt.Sprintf("%s%s:%s:%d", UserBucketPrefix, b.Fingerprint, b.Granularity, b.Start.Unix())
+}
+
+func parseUserBucketKey(key string) (models.UserBucket, bool) {
+	parts := strings.Split(strings.TrimPrefix(key, UserBucketPrefix), ":")
+	if len(parts) != 3 {
+		return models.UserBucket{}, false
+	}
+	start, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return models.UserBucket{}, false
+	}
+	return models.UserBucket{Fingerprint: parts[0], Granularity: parts[1], Start: time.Unix(start, 0).UTC()}, true
+}
+
+// AddUser counts a user in each bucket's HyperLogLog and marks the buckets
+// for rollup.
+func (c *Client) AddUser(ctx context.Context, user string, buckets []models.UserBucket) error {
+	pipe := c.Pipeline()
+	for _, b := range buckets {
+		key := userBucketKey(b)
+		pipe.PFAdd(ctx, key, user)
+		if ttl, ok := userBucketTTL[b.Granularity]; ok {
+			pipe.Expire(ctx, key, ttl)
+		}
+		pipe.SAdd(ctx, DirtyUserBucketsKey, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// PopDirtyUserBuckets returns up to n buckets changed since their last rollup.
+func (c *Client) PopDirtyUserBuckets(ctx context.Context, n int64) ([]models.UserBucket, error) {
+	keys, err := c.SPopN(ctx, DirtyUserBucketsKey, n).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pop dirty user buckets: %w", err)
+	}
+	buckets := make([]models.UserBucket, 0, len(keys))
+	for _, key := range keys {
+		if b, ok := parseUserBucketKey(key); ok {
+			buckets = append(buckets, b)
+		}
+	}
+	return buckets, nil
+}
+
+// MarkUserBucketsDirty queues buckets for the next rollup again, after one
+// failed.
+func (c *Client) MarkUserBucketsDirty(ctx context.Context, buckets []models.UserBucket) error {
+	if len(buckets) == 0 {
+		return nil
+	}
+	keys := make([]interface{}, len(buckets))
+	for i, b := range buckets {
+		keys[i] = userBucketKey(b)
+	}
+	return c.SAdd(ctx, DirtyUserBucketsKey, keys...).Err()
+}
+
+// MergeUserRollup folds a stored HyperLogLog into the live one, so users
+// counted before Redis lost the key aren't dropped, and returns the merged
+// copy for storing. It returns nil if the bucket has expired from Redis.
+func (c *Client) MergeUserRollup(ctx context.Context, b models.UserBucket, stored []byte) (*models.UserRollup, error) {
+	key := userBucketKey(b)
+	if stored != nil {
+		tmp := key + ":merge"
+		pipe := c.TxPipeline()
+		pipe.Set(ctx, tmp, stored, time.Minute)
+		pipe.PFMerge(ctx, key, key, tmp)
+		pipe.Del(ctx, tmp)
+		if ttl, ok := userBucketTTL[b.Granularity]; ok {
+			pipe.Expire(ctx, key, ttl)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to merge user rollup: %w", err)
+		}
+	}
+
+	hll, err := c.Get(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user bucket: %w", err)
+	}
+	users, err := c.PFCount(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count user bucket: %w", err)
+	}
+	return &models.UserRollup{Bucket: b, HLL: hll, Users: users}, nil
+}
+
+// MissingUserBuckets returns the buckets Redis doesn't hold.
+func (c *Client) MissingUserBuckets(ctx context.Context, buckets []models.UserBucket) ([]models.UserBucket, error) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+	pipe := c.Pipeline()
+	cmds := make([]*redis.IntCmd, len(buckets))
+	for i, b := range buckets {
+		cmds[i] = pipe.Exists(ctx, userBucketKey(b))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to check user buckets: %w", err)
+	}
+
+	var missing []models.UserBucket
+	for i, cmd := range cmds {
+		if cmd.Val() == 0 {
+			missing = append(missing, buckets[i])
+		}
+	}
+	return missing, nil
+}
+
+// RestoreUserRollups loads stored HyperLogLogs into Redis. Keys that exist
+// are left alone; the next rollup merges the stored copy into them.
+func (c *Client) RestoreUserRollups(ctx context.Context, rollups []models.UserRollup) error {
+	if len(rollups) == 0 {
+		return nil
+	}
+	pipe := c.Pipeline()
+	for _, r := range rollups {
+		pipe.SetNX(ctx, userBucketKey(r.Bucket), r.HLL, userBucketTTL[r.Bucket.Granularity])
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// CountUsers estimates the unique users across each group of buckets.
+func (c *Client) CountUsers(ctx context.Context, groups [][]models.UserBucket) ([]int64, error) {
+	counts := make([]int64, len(groups))
+	pipe := c.Pipeline()
+	cmds := make([]*redis.IntCmd, len(groups))
+	for i, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		keys := make([]string, len(group))
+		for j, b := range group {
+			keys[j] = userBucketKey(b)
+		}
+		cmds[i] = pipe.PFCount(ctx, keys...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	for i, cmd := range cmds {
+		if cmd != nil {
+			counts[i] = cmd.Val()
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,145 @@
+// Package relay implements relay mode: the ingestion API is served locally,
+// events are persisted to an on-disk spool and forwarded to a central server
+// whenever it is reachable.
+package relay
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+	"error-logs/internal/scrub"
+	"error-logs/internal/spool"
+	"error-logs/pkg/client"
+)
+
+type Relay struct {
+	spool      *spool.Spool
+	keys       map[string]*models.APIKey
+	sampleRate float64
+	scrubber   *scrub.Scrubber
+	hostname   string
+}
+
+// New creates a relay accepting the given plaintext API keys. Events are
+// kept with probability sampleRate and scrubbed with the default detectors
+// plus scrubKeys as extra denied context keys before they touch the disk.
+func New(sp *spool.Spool, apiKeys []string, sampleRate float64, scrubKeys []string) (*Relay, error) {
+	scrubber, err := scrub.New(models.ScrubbingSettings{DenyKeys: scrubKeys}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Relay{
+		spool:      sp,
+		keys:       make(map[string]*models.APIKey),
+		sampleRate: sampleRate,
+		scrubber:   scrubber,
+		hostname:   hostname(),
+	}
+	for i, key := range apiKeys {
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+		r.keys[hash] = &models.APIKey{
+			ID:      uuid.NewSHA1(uuid.NameSpaceOID, []byte(hash)),
+			KeyHash: hash,
+			Name:    fmt.Sprintf("relay key %d", i+1),
+			Scope:   models.APIKeyScopeIngest,
+			Active:  true,
+		}
+	}
+	return r, nil
+}
+
+// ValidateAPIKey checks keys locally, since the central database is not
+// reachable from a relay.
+func (r *Relay) ValidateAPIKey(keyHash string) (*models.APIKey, error) {
+	apiKey, ok := r.keys[keyHash]
+	if !ok {
+		return nil, fmt.Errorf("invalid API key")
+	}
+	return apiKey, nil
+}
+
+func (r *Relay) CreateError(ctx context.Context, req *models.CreateErrorRequest, userAgent, ipAddress string) (*models.Error, error) {
+	now := time.Now().UTC()
+	if req.Timestamp == nil {
+		req.Timestamp = &now
+	}
+	if req.Context == nil {
+		req.Context = make(map[string]interface{})
+	}
+	r.scrubber.Request(req)
+
+	// Upstream only sees the relay's address. It takes the client's from
+	// client_ip instead if the relay is one of its TRUSTED_RELAYS, and then
+	// applies GeoIP and the project's IP settings to it as usual.
+	req.ClientIP = &ipAddress
+	req.Context["relay"] = map[string]interface{}{
+		"host":       r.hostname,
+		"user_agent": userAgent,
+	}
+
+	event := &models.Error{
+		ID:          uuid.New(),
+		Timestamp:   *req.Timestamp,
+		Level:       req.Level,
+		Message:     req.Message,
+		StackTrace:  req.StackTrace,
+		Context:     req.Context,
+		Source:      req.Source,
+		Environment: "production",
+		UserAgent:   &userAgent,
+		IPAddress:   &ipAddress,
+		URL:         req.URL,
+		Count:       1,
+		FirstSeen:   *req.Timestamp,
+		LastSeen:    *req.Timestamp,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if req.Environment != nil {
+		event.Environment = *req.Environment
+	}
+
+	// Sampled-out events are acknowledged like any other so clients don't retry them
+	if r.sampleRate < 1 && rand.Float64() >= r.sampleRate {
+		return event, nil
+	}
+
+	if err := r.spool.Write([]*models.CreateErrorRequest{req}); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Run forwards spooled events upstream until ctx is cancelled. Retries are
+// patient because the central instance is expected to be unreachable for
+// long stretches; the spool holds events meanwhile.
+func (r *Relay) Run(ctx context.Context, upstreamURL, upstreamAPIKey string) error {
+	c, err := client.New(client.Options{
+		Endpoint:       upstreamURL,
+		APIKey:         upstreamAPIKey,
+		Passthrough:    true,
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close(context.Background())
+
+	r.spool.Run(ctx, c)
+	return nil
+}
+
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
@@ -0,0 +1,306 @@
+// Package scrub removes personal data and secrets from events before they
+// are queued or stored.
+package scrub
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"error-logs/internal/models"
+)
+
+const (
+	ActionMask   = "mask"
+	ActionHash   = "hash"
+	ActionRemove = "remove"
+
+	maskValue = "[Filtered]"
+)
+
+type detector struct {
+	re *regexp.Regexp
+	// group is the submatch that holds the sensitive part, 0 for the whole match
+	group    int
+	validate func(string) bool
+}
+
+// detectorOrder fixes the order detectors run in, so overlapping matches are
+// scrubbed the same way every time.
+var detectorOrder = []string{"url_credentials", "jwt", "bearer_token", "password", "email", "iban", "credit_card"}
+
+var detectors = map[string]detector{
+	"email": {
+		re: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	},
+	"credit_card": {
+		re:       regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		validate: luhn,
+	},
+	"iban": {
+		re:       regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,4})?\b`),
+		validate: ibanChecksum,
+	},
+	"bearer_token": {
+		re:    regexp.MustCompile(`(?i)\b(?:bearer|token)\s+([A-Za-z0-9\-._~+/]{8,}=*)`),
+		group: 1,
+	},
+	"jwt": {
+		re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	},
+	"password": {
+		re:    regexp.MustCompile(`(?i)\b[\w-]*(?:password|passwd|pwd|secret|token|api[_-]?key)["']?\s*[=:]\s*("[^"]*"|'[^']*'|[^\s&,;"']+)`),
+		group: 1,
+	},
+	"url_credentials": {
+		re:    regexp.MustCompile(`://[^/\s:@]+:([^/\s@]+)@`),
+		group: 1,
+	},
+}
+
+// defaultDenyKeys are context keys that are scrubbed for every project.
+var defaultDenyKeys = []string{
+	"password", "passwd", "secret", "token", "authorization", "api_key", "apikey",
+	"cookie", "credit_card", "card_number", "cvv", "ssn",
+}
+
+type rule struct {
+	detector
+	action string
+}
+
+type Scrubber struct {
+	rules    []rule
+	denyKeys []string
+	action   string
+	salt     string
+}
+
+// New builds a scrubber from project settings. The salt makes hashed values
+// stable within a project but not comparable across projects; it must be
+// secret, or hashes of guessable values like email addresses can be
+// reversed. A nil Scrubber is valid and scrubs nothing.
+func New(settings models.ScrubbingSettings, salt string) (*Scrubber, error) {
+	if settings.Disabled {
+		return nil, nil
+	}
+
+	action := settings.Action
+	if action == "" {
+		action = ActionMask
+	}
+	if !validAction(action) {
+		return nil, fmt.Errorf("unknown scrubbing action %q", action)
+	}
+
+	s := &Scrubber{action: action, salt: salt}
+
+	names := settings.Detectors
+	if names == nil {
+		names = detectorOrder
+	}
+	for _, name := range names {
+		d, ok := detectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		s.rules = append(s.rules, rule{detector: d, action: action})
+	}
+
+	for _, p := range settings.Patterns {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p.Name, err)
+		}
+		patternAction := p.Action
+		if patternAction == "" {
+			patternAction = action
+		}
+		if !validAction(patternAction) {
+			return nil, fmt.Errorf("unknown scrubbing action %q for pattern %q", patternAction, p.Name)
+		}
+		s.rules = append(s.rules, rule{detector: detector{re: re}, action: patternAction})
+	}
+
+	for _, key := range append(defaultDenyKeys, settings.DenyKeys...) {
+		s.denyKeys = append(s.denyKeys, strings.ToLower(key))
+	}
+	return s, nil
+}
+
+func validAction(action string) bool {
+	return action == ActionMask || action == ActionHash || action == ActionRemove
+}
+
+// Request scrubs every free-text field of an incoming event in place.
+func (s *Scrubber) Request(req *models.CreateErrorRequest) {
+	if s == nil {
+		return
+	}
+	req.Message = s.String(req.Message)
+	if req.StackTrace != nil {
+		scrubbed := s.String(*req.StackTrace)
+		req.StackTrace = &scrubbed
+	}
+	if req.URL != nil {
+		scrubbed := s.String(*req.URL)
+		req.URL = &scrubbed
+	}
+	s.Map(req.Context)
+	for key, value := range req.Tags {
+		req.Tags[key] = s.String(value)
+	}
+	for i := range req.Breadcrumbs {
+		req.Breadcrumbs[i].Message = s.String(req.Breadcrumbs[i].Message)
+		s.Map(req.Breadcrumbs[i].Data)
+	}
+	if req.User != nil {
+		req.User.Email = s.String(req.User.Email)
+		req.User.Username = s.String(req.User.Username)
+	}
+}
+
+// String applies all detectors and custom patterns to v.
+func (s *Scrubber) String(v string) string {
+	if s == nil || v == "" {
+		return v
+	}
+	for _, r := range s.rules {
+		v = s.replace(v, r)
+	}
+	return v
+}
+
+// Map scrubs a JSON-like value in place: denied keys are scrubbed whole and
+// all other strings go through String.
+func (s *Scrubber) Map(m map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	for key, value := range m {
+		if s.isDenied(key) {
+			if s.action == ActionRemove {
+				delete(m, key)
+			} else {
+				m[key] = s.apply(s.action, fmt.Sprint(value))
+			}
+			continue
+		}
+		m[key] = s.value(value)
+	}
+}
+
+func (s *Scrubber) value(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return s.String(val)
+	case map[string]interface{}:
+		s.Map(val)
+		return val
+	case map[string]string:
+		for k, item := range val {
+			if s.isDenied(k) {
+				val[k] = s.apply(s.action, item)
+			} else {
+				val[k] = s.String(item)
+			}
+		}
+		return val
+	case []interface{}:
+		for i, item := range val {
+			val[i] = s.value(item)
+		}
+		return val
+	}
+	return v
+}
+
+func (s *Scrubber) isDenied(key string) bool {
+	key = strings.ToLower(key)
+	for _, denied := range s.denyKeys {
+		if strings.Contains(key, denied) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scrubber) replace(v string, r rule) string {
+	matches := r.re.FindAllStringSubmatchIndex(v, -1)
+	if matches == nil {
+		return v
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*r.group], m[2*r.group+1]
+		if start < 0 {
+			continue
+		}
+		secret := v[start:end]
+		if r.validate != nil && !r.validate(secret) {
+			continue
+		}
+		b.WriteString(v[last:start])
+		b.WriteString(s.apply(r.action, secret))
+		last = end
+	}
+	b.WriteString(v[last:])
+	return b.String()
+}
+
+func (s *Scrubber) apply(action, secret string) string {
+	switch action {
+	case ActionHash:
+		sum := sha256.Sum256([]byte(s.salt + secret))
+		return fmt.Sprintf("[hash:%x]", sum[:6])
+	case ActionRemove:
+		return ""
+	default:
+		return maskValue
+	}
+}
+
+// ibanChecksum checks the ISO 13616 mod-97 check digits, so codes that only
+// look like an IBAN are left alone.
+func ibanChecksum(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	rem := 0
+	for _, c := range s[4:] + s[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			rem = (rem*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			rem = (rem*100 + int(c-'A'+10)) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
+
+// luhn filters out long digit runs (IDs, timestamps) that aren't card numbers.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
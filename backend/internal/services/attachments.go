@@ -0,0 +1,255 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/blob"
+	"error-logs/internal/models"
+)
+
+const (
+	maxAttachmentName        = 200
+	attachmentRetentionBatch = 100
+	attachmentRetentionEvery = time.Hour
+)
+
+// AttachmentConfig limits what attachments are accepted and how long they
+// are kept.
+type AttachmentConfig struct {
+	MaxSize      int64 // per file, in bytes
+	ProjectQuota int64 // total bytes per project
+	Retention    time.Duration
+	ContentTypes []string // media types, or "type/*" wildcards
+}
+
+// CreateAttachment streams an uploaded file to blob storage and records it
+// against an event, which may still be queued. Errors are prefixed
+// "invalid attachment" for rejected files and "attachment too large" when a
+// size limit is hit.
+func (s *ErrorService) CreateAttachment(ctx context.Context, eventID uuid.UUID, name, contentType string, r io.Reader) (*models.Attachment, error) {
+	projectID := ProjectIDFromContext(ctx)
+	usage, err := s.db.AttachmentUsage(projectID)
+	if err != nil {
+		return nil, err
+	}
+	limit := s.attachments.MaxSize
+	if remaining := s.attachments.ProjectQuota - usage; remaining < limit {
+		limit = remaining
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("attachment too large: project quota of %d MB is used up", s.attachments.ProjectQuota>>20)
+	}
+
+	br := bufio.NewReaderSize(r, 512)
+	head, err := br.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if len(head) == 0 {
+		return nil, fmt.Errorf("invalid attachment: file is empty")
+	}
+	contentType, err = checkContentType(contentType, head, s.attachments.ContentTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{
+		ID:          uuid.New(),
+		ProjectID:   projectID,
+		EventID:     eventID,
+		Name:        attachmentName(name),
+		ContentType: contentType,
+		CreatedAt:   time.Now().UTC(),
+	}
+	project := "default"
+	if projectID != nil {
+		project = projectID.String()
+	}
+	attachment.StorageKey = fmt.Sprintf("attachments/%s/%s/%s", project, eventID, attachment.ID)
+
+	body := &limitedReader{r: br, remaining: limit}
+	if err := s.blobs.Put(ctx, attachment.StorageKey, body, -1, contentType); err != nil {
+		s.blobs.Delete(context.Background(), attachment.StorageKey)
+		if body.exceeded {
+			if limit < s.attachments.MaxSize {
+				return nil, fmt.Errorf("attachment too large: project quota of %d MB would be exceeded", s.attachments.ProjectQuota>>20)
+			}
+			return nil, fmt.Errorf("attachment too large: files are limited to %d MB", s.attachments.MaxSize>>20)
+		}
+		return nil, err
+	}
+	attachment.Size = limit - body.remaining
+
+	if err := s.db.CreateAttachment(attachment); err != nil {
+		s.blobs.Delete(context.Background(), attachment.StorageKey)
+		return nil, err
+	}
+	log.Printf("Stored attachment %s (%s, %d bytes) for error %s", attachment.Name, contentType, attachment.Size, eventID)
+	return attachment, nil
+}
+
+// limitedReader fails once more than remaining bytes are read, so an upload
+// is rejected rather than silently truncated.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		l.exceeded = true
+		l.remaining = 0
+		return 0, fmt.Errorf("attachment too large")
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// checkContentType returns the media type to store a file as. The declared
+// type must be allowed, and images must really be what they claim to be so
+// they can be previewed safely. Files declared without a type are sniffed.
+func checkContentType(declared string, head []byte, allowed []string) (string, error) {
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	mediaType := sniffed
+	if declared != "" {
+		parsed, _, err := mime.ParseMediaType(declared)
+		if err != nil {
+			return "", fmt.Errorf("invalid attachment: malformed content type %q", declared)
+		}
+		mediaType = parsed
+	}
+
+	if !contentTypeAllowed(mediaType, allowed) {
+		return "", fmt.Errorf("invalid attachment: content type %s is not allowed", mediaType)
+	}
+	if strings.HasPrefix(mediaType, "image/") && sniffed != mediaType {
+		return "", fmt.Errorf("invalid attachment: content is %s, not %s", sniffed, mediaType)
+	}
+	return mediaType, nil
+}
+
+func contentTypeAllowed(mediaType string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == mediaType || (strings.HasSuffix(a, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(a, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func attachmentName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == "" {
+		return "attachment"
+	}
+	return truncate(name, maxAttachmentName)
+}
+
+func (s *ErrorService) ListAttachments(ctx context.Context, eventID uuid.UUID) ([]models.Attachment, error) {
+	attachments, err := s.db.ListEventAttachments(eventID)
+	if err != nil {
+		return nil, err
+	}
+	visible := attachments[:0]
+	for _, a := range attachments {
+		if sameProject(ctx, a.ProjectID) {
+			visible = append(visible, a)
+		}
+	}
+	return visible, nil
+}
+
+// OpenAttachment returns an attachment of the caller's project with its
+// content, which the caller must close. Other projects' attachments are
+// reported as not found.
+func (s *ErrorService) OpenAttachment(ctx context.Context, id uuid.UUID) (*models.Attachment, io.ReadCloser, error) {
+	attachment, err := s.db.GetAttachment(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !sameProject(ctx, attachment.ProjectID) {
+		return nil, nil, fmt.Errorf("attachment not found")
+	}
+	content, err := s.blobs.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		if err == blob.ErrNotFound {
+			return nil, nil, fmt.Errorf("attachment not found")
+		}
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+// deleteAttachments removes attachments from storage and then the database,
+// so a failure leaves a record to retry rather than an orphaned blob.
+func (s *ErrorService) deleteAttachments(ctx context.Context, attachments []models.Attachment) (int, error) {
+	for i, a := range attachments {
+		if err := s.blobs.Delete(ctx, a.StorageKey); err != nil {
+			return i, err
+		}
+		if err := s.db.DeleteAttachment(a.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(attachments), nil
+}
+
+// StartAttachmentRetention periodically deletes attachments older than the
+// retention period.
+func (s *ErrorService) StartAttachmentRetention(ctx context.Context) {
+	if s.attachments.Retention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(attachmentRetentionEvery)
+	defer ticker.Stop()
+
+	for {
+		s.deleteExpiredAttachments(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ErrorService) deleteExpiredAttachments(ctx context.Context) {
+	before := time.Now().Add(-s.attachments.Retention)
+	total := 0
+	for {
+		expired, err := s.db.ListExpiredAttachments(before, attachmentRetentionBatch)
+		if err != nil {
+			log.Printf("Failed to list expired attachments: %v", err)
+			break
+		}
+		n, err := s.deleteAttachments(ctx, expired)
+		total += n
+		if err != nil {
+			log.Printf("Failed to delete expired attachments: %v", err)
+			break
+		}
+		if len(expired) < attachmentRetentionBatch {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Deleted %d expired attachments", total)
+	}
+}
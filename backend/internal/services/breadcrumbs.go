@@ -0,0 +1,80 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"error-logs/internal/models"
+)
+
+const (
+	defaultMaxBreadcrumbs       = 100
+	defaultMaxBreadcrumbMessage = 1024
+	defaultMaxBreadcrumbData    = 4096
+
+	// Upper bounds for per-project settings, to keep rows a sane size.
+	maxBreadcrumbs          = 1000
+	maxBreadcrumbMessage    = 16 * 1024
+	maxBreadcrumbData       = 64 * 1024
+	maxBreadcrumbCategory   = 100
+	maxBreadcrumbLevelChars = 20
+)
+
+func validateBreadcrumbSettings(settings *models.BreadcrumbSettings) error {
+	if settings.MaxCount < 0 || settings.MaxCount > maxBreadcrumbs {
+		return fmt.Errorf("breadcrumbs.max_count must be between 0 and %d", maxBreadcrumbs)
+	}
+	if settings.MaxMessageLength < 0 || settings.MaxMessageLength > maxBreadcrumbMessage {
+		return fmt.Errorf("breadcrumbs.max_message_length must be between 0 and %d", maxBreadcrumbMessage)
+	}
+	if settings.MaxDataBytes < 0 || settings.MaxDataBytes > maxBreadcrumbData {
+		return fmt.Errorf("breadcrumbs.max_data_bytes must be between 0 and %d", maxBreadcrumbData)
+	}
+	return nil
+}
+
+// capBreadcrumbs keeps the most recent breadcrumbs within the project's
+// limits, truncating long messages and dropping oversized data.
+func capBreadcrumbs(settings *models.BreadcrumbSettings, crumbs []models.Breadcrumb) []models.Breadcrumb {
+	if len(crumbs) == 0 {
+		return nil
+	}
+
+	maxCount := orDefault(settings.MaxCount, defaultMaxBreadcrumbs)
+	maxMessage := orDefault(settings.MaxMessageLength, defaultMaxBreadcrumbMessage)
+	maxData := orDefault(settings.MaxDataBytes, defaultMaxBreadcrumbData)
+
+	if len(crumbs) > maxCount {
+		crumbs = crumbs[len(crumbs)-maxCount:]
+	}
+
+	capped := make([]models.Breadcrumb, 0, len(crumbs))
+	for _, b := range crumbs {
+		b.Category = truncate(b.Category, maxBreadcrumbCategory)
+		b.Level = truncate(strings.ToLower(b.Level), maxBreadcrumbLevelChars)
+		b.Message = truncate(b.Message, maxMessage)
+		if len(b.Data) > 0 {
+			if data, err := json.Marshal(b.Data); err != nil || len(data) > maxData {
+				b.Data = map[string]interface{}{"_truncated": true}
+			}
+		}
+		capped = append(capped, b)
+	}
+	return capped
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+func orDefault(v, def int) int {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
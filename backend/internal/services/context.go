@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+type contextKey struct{}
+
+// WithAPIKey returns a context carrying the API key that authenticated the
+// request, so ingestion can apply that key's project settings.
+func WithAPIKey(ctx context.Context, apiKey *models.APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, apiKey)
+}
+
+func APIKeyFromContext(ctx context.Context) *models.APIKey {
+	apiKey, _ := ctx.Value(contextKey{}).(*models.APIKey)
+	return apiKey
+}
+
+// ProjectIDFromContext returns the project of the authenticating API key, or
+// nil for keys without a project and for non-HTTP ingestion.
+func ProjectIDFromContext(ctx context.Context) *uuid.UUID {
+	if apiKey := APIKeyFromContext(ctx); apiKey != nil {
+		return apiKey.ProjectID
+	}
+	return nil
+}
+
+// sameProject reports whether a record belongs to the caller's project, with
+// records and keys without a project only matching each other.
+func sameProject(ctx context.Context, projectID *uuid.UUID) bool {
+	caller := ProjectIDFromContext(ctx)
+	if caller == nil || projectID == nil {
+		return caller == nil && projectID == nil
+	}
+	return *caller == *projectID
+}
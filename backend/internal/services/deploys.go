@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+// trendBuckets are the group_by values trends accept.
+var trendBuckets = map[string]bool{"hour": true, "day": true, "week": true, "month": true}
+
+// CreateDeploy records a deployment. The project defaults to the API key's;
+// invalid requests are rejected with an error prefixed "invalid deploy".
+func (s *ErrorService) CreateDeploy(ctx context.Context, req *models.CreateDeployRequest) (*models.Deploy, error) {
+	req.Release = strings.TrimSpace(req.Release)
+	if req.Release == "" {
+		return nil, fmt.Errorf("invalid deploy: release is required")
+	}
+	if req.Environment == "" {
+		req.Environment = "production"
+	}
+
+	now := time.Now().UTC()
+	deploy := &models.Deploy{
+		ID:          uuid.New(),
+		ProjectID:   ProjectIDFromContext(ctx),
+		Environment: req.Environment,
+		Release:     req.Release,
+		Timestamp:   now,
+		Commit:      req.Commit,
+		Deployer:    req.Deployer,
+		CreatedAt:   now,
+	}
+	if req.Timestamp != nil && !req.Timestamp.IsZero() {
+		if req.Timestamp.After(now.Add(maxClockSkew)) {
+			return nil, fmt.Errorf("invalid deploy: timestamp is in the future")
+		}
+		deploy.Timestamp = req.Timestamp.UTC()
+	}
+	if req.Project != "" {
+		projectID, err := s.db.GetProjectIDBySlug(req.Project)
+		if err != nil {
+			if err.Error() == "project not found" {
+				return nil, fmt.Errorf("invalid deploy: unknown project %q", req.Project)
+			}
+			return nil, err
+		}
+		deploy.ProjectID = &projectID
+	}
+
+	if err := s.db.CreateDeploy(deploy); err != nil {
+		return nil, err
+	}
+	log.Printf("Recorded deploy of %s to %s", deploy.Release, deploy.Environment)
+	return deploy, nil
+}
+
+func (s *ErrorService) ListDeploys(ctx context.Context, environment string, limit int) ([]models.Deploy, error) {
+	return s.db.ListDeploys(environment, time.Time{}, time.Now().Add(maxClockSkew), limit)
+}
+
+// GetDeployIssues returns the deploy with the issues first seen after it.
+func (s *ErrorService) GetDeployIssues(ctx context.Context, id uuid.UUID) (*models.DeployIssuesResponse, error) {
+	deploy, err := s.db.GetDeploy(id)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	issues, err := s.db.GetDeployIssues(deploy, 100)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("DATABASE QUERY: GetDeployIssues completed in %v", time.Since(start))
+
+	return &models.DeployIssuesResponse{
+		Deploy: deploy,
+		Issues: issues,
+	}, nil
+}
+
+// GetTrends counts errors per bucket over a period, along with the deploys
+// made in that period. Unknown periods or buckets are rejected with an error
+// prefixed "invalid trends".
+func (s *ErrorService) GetTrends(ctx context.Context, period, groupBy string, filter models.ErrorFilter) (*models.TrendsResponse, error) {
+	window, ok := analyticsPeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("invalid trends: unknown period %q", period)
+	}
+	if !trendBuckets[groupBy] {
+		return nil, fmt.Errorf("invalid trends: unknown group_by %q", groupBy)
+	}
+
+	start := time.Now()
+	since := start.Add(-window)
+	points, err := s.db.GetTrends(groupBy, filter, since)
+	if err != nil {
+		return nil, err
+	}
+	deploys, err := s.db.ListDeploys("", since, start.Add(maxClockSkew), 100)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("DATABASE QUERY: GetTrends completed in %v", time.Since(start))
+
+	return &models.TrendsResponse{
+		Period:     period,
+		GroupBy:    groupBy,
+		DataPoints: points,
+		Deploys:    deploys,
+	}, nil
+}
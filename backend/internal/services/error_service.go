@@ -5,34 +5,97 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"log"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 
-	"error-logs/internal/database"
+	"error-logs/internal/blob"
+	"error-logs/internal/geoip"
 	"error-logs/internal/models"
+	"error-logs/internal/notify"
 	"error-logs/internal/redis"
+	"error-logs/internal/storage"
+	"error-logs/internal/useragent"
 )
 
+const maxClockSkew = 5 * time.Minute
+
 type ErrorService struct {
-	db    *database.DB
+	db    storage.Store
 	redis *redis.Client
+	geo   *geoip.DB
+	notif *notify.Webhook
+
+	defaultConfig *projectConfig
+	strictConfig  *projectConfig
+	projectsMu    sync.Mutex
+	projects      map[uuid.UUID]*projectConfig
+
+	ipSaltMu     sync.Mutex
+	ipSaltPeriod string
+	ipSaltValue  string
+
+	scrubSaltMu sync.Mutex
+	scrubSalt   string
+
+	blobs       blob.Store
+	attachments AttachmentConfig
+	sourceMaps  sourceMapCache
+
+	retention     RetentionConfig
+	retentionMu   sync.Mutex
+	lastRetention *models.RetentionReport
 }
 
-func NewErrorService(db *database.DB, redis *redis.Client) *ErrorService {
+// NewErrorService creates the service; geo and notif may be nil when no
+// GeoIP database or notification webhook is configured.
+func NewErrorService(db storage.Store, redis *redis.Client, geo *geoip.DB, notif *notify.Webhook, blobs blob.Store, attachments AttachmentConfig, retention RetentionConfig) *ErrorService {
+	defaultConfig, err := newProjectConfig(&models.ProjectSettings{}, "")
+	if err != nil {
+		panic(err)
+	}
+	strictConfig, err := newProjectConfig(&models.ProjectSettings{IP: models.IPSettings{Mode: IPModeDrop}}, "")
+	if err != nil {
+		panic(err)
+	}
+
 	return &ErrorService{
-		db:    db,
-		redis: redis,
+		db:            db,
+		redis:         redis,
+		geo:           geo,
+		notif:         notif,
+		blobs:         blobs,
+		attachments:   attachments,
+		retention:     retention,
+		defaultConfig: defaultConfig,
+		strictConfig:  strictConfig,
+		projects:      make(map[uuid.UUID]*projectConfig),
 	}
 }
 
 func (s *ErrorService) CreateError(ctx context.Context, req *models.CreateErrorRequest, userAgent, ipAddress string) (*models.Error, error) {
+	// Scrub personal data before the event is queued or written anywhere
+	projectID := ProjectIDFromContext(ctx)
+	cfg := s.projectConfig(projectID)
+	cfg.scrubber.Request(req)
+	normalizeTraceContext(req)
+
 	now := time.Now().UTC()
 	fingerprint := generateFingerprint(req.Message, req.StackTrace)
 
+	// Trust a client-supplied timestamp unless it is in the future
+	occurredAt := now
+	if req.Timestamp != nil && !req.Timestamp.IsZero() && !req.Timestamp.After(now.Add(maxClockSkew)) {
+		occurredAt = req.Timestamp.UTC()
+	}
+
 	error := &models.Error{
 		ID:          uuid.New(),
-		Timestamp:   now,
+		ProjectID:   projectID,
+		Timestamp:   occurredAt,
 		Level:       req.Level,
 		Message:     req.Message,
 		StackTrace:  req.StackTrace,
@@ -40,13 +103,19 @@ func (s *ErrorService) CreateError(ctx context.Context, req *models.CreateErrorR
 		Source:      req.Source,
 		Environment: "production",
 		UserAgent:   &userAgent,
-		IPAddress:   &ipAddress,
 		URL:         req.URL,
+		Release:     req.Release,
+		Tags:        normalizeTags(req.Tags),
+		User:        normalizeUser(req.User),
+		TraceID:     req.TraceID,
+		SpanID:      req.SpanID,
+		RequestID:   req.RequestID,
+		Breadcrumbs: capBreadcrumbs(&cfg.settings.Breadcrumbs, req.Breadcrumbs),
 		Fingerprint: &fingerprint,
 		Resolved:    false,
 		Count:       1,
-		FirstSeen:   now,
-		LastSeen:    now,
+		FirstSeen:   occurredAt,
+		LastSeen:    occurredAt,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}
@@ -55,13 +124,49 @@ func (s *ErrorService) CreateError(ctx context.Context, req *models.CreateErrorR
 		error.Environment = *req.Environment
 	}
 
+	if userAgent != "" {
+		ua := useragent.Parse(userAgent)
+		error.BrowserName = &ua.BrowserName
+		error.BrowserVersion = optionalString(ua.BrowserVersion)
+		error.OSName = &ua.OSName
+		error.OSVersion = optionalString(ua.OSVersion)
+		error.DeviceType = &ua.DeviceType
+		error.IsBot = ua.Bot
+	}
+
+	// Geo lookup uses the full address, before it is anonymized. Projects that
+	// drop addresses only keep the country, since a city or network can
+	// narrow a visitor down almost as well as the address.
+	if loc := s.geo.Lookup(net.ParseIP(ipAddress)); loc != nil {
+		error.Country = optionalString(loc.Country)
+		if cfg.settings.IP.Mode != IPModeDrop {
+			error.Region = optionalString(loc.Region)
+			error.City = optionalString(loc.City)
+			if loc.ASN != 0 {
+				asn := int(loc.ASN)
+				error.ASN = &asn
+				error.ASOrg = optionalString(loc.ASOrg)
+			}
+		}
+	}
+
+	if ip := s.anonymizeIP(ctx, projectID, &cfg.settings.IP, ipAddress); ip != "" {
+		error.IPAddress = &ip
+	}
+	if error.User != nil && error.User.IPAddress != "" {
+		error.User.IPAddress = s.anonymizeIP(ctx, projectID, &cfg.settings.IP, error.User.IPAddress)
+		if userKey(error.User) == "" {
+			error.User = nil
+		}
+	}
+
 	if error.Context == nil {
 		error.Context = make(map[string]interface{})
 	}
 
 	if err := s.redis.QueueError(ctx, error); err != nil {
 		log.Printf("Failed to queue error to Redis: %v", err)
-		if err := s.db.CreateError(error); err != nil {
+		if err := s.persist(error); err != nil {
 			return nil, err
 		}
 		log.Printf("CACHE INVALIDATION: CreateError (fallback) - invalidating all caches")
@@ -74,8 +179,8 @@ func (s *ErrorService) CreateError(ctx context.Context, req *models.CreateErrorR
 	return error, nil
 }
 
-func (s *ErrorService) GetErrors(ctx context.Context, limit, offset int, level, source string) (*models.ErrorListResponse, error) {
-	cacheKey := fmt.Sprintf("list_%d_%d_%s_%s", limit, offset, level, source)
+func (s *ErrorService) GetErrors(ctx context.Context, limit, offset int, filter models.ErrorFilter) (*models.ErrorListResponse, error) {
+	cacheKey := fmt.Sprintf("list_%d_%d_%s", limit, offset, filterCacheKey(filter))
 	start := time.Now()
 
 	if cachedErrors, err := s.redis.GetCachedErrorList(ctx, cacheKey); err == nil && cachedErrors != nil {
@@ -89,7 +194,7 @@ func (s *ErrorService) GetErrors(ctx context.Context, limit, offset int, level,
 	}
 
 	log.Printf("CACHE MISS: GetErrors - key: %s, fetching from database", cacheKey)
-	errors, total, err := s.db.GetErrors(limit, offset, level, source)
+	errors, total, err := s.db.GetErrors(limit, offset, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -97,6 +202,8 @@ func (s *ErrorService) GetErrors(ctx context.Context, limit, offset int, level,
 	dbDuration := time.Since(start)
 	log.Printf("DATABASE QUERY: GetErrors completed in %v", dbDuration)
 
+	s.fillAffectedUsers(ctx, errors)
+
 	if len(errors) > 0 {
 		go func() {
 			cacheStart := time.Now()
@@ -118,12 +225,69 @@ func (s *ErrorService) GetErrors(ctx context.Context, limit, offset int, level,
 	}, nil
 }
 
+// analyticsPeriods are the time windows breakdowns and trends can cover.
+var analyticsPeriods = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+}
+
+// GetBreakdown counts recent errors grouped by one field. Unknown fields and
+// periods are rejected with an error prefixed "invalid breakdown".
+func (s *ErrorService) GetBreakdown(ctx context.Context, by, period string, filter models.ErrorFilter, limit int) (*models.BreakdownResponse, error) {
+	window, ok := analyticsPeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("invalid breakdown: unknown period %q", period)
+	}
+
+	start := time.Now()
+	items, err := s.db.GetBreakdown(by, filter, start.Add(-window), limit)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "unknown breakdown field") {
+			return nil, fmt.Errorf("invalid breakdown: %w", err)
+		}
+		return nil, err
+	}
+	log.Printf("DATABASE QUERY: GetBreakdown by %s completed in %v", by, time.Since(start))
+
+	return &models.BreakdownResponse{
+		By:     by,
+		Period: period,
+		Values: items,
+	}, nil
+}
+
 func (s *ErrorService) GetErrorByID(ctx context.Context, id uuid.UUID) (*models.Error, error) {
-	return s.db.GetErrorByID(id)
+	error, err := s.db.GetErrorByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if error.Fingerprint != nil {
+		if error.Feedback, err = s.db.GetIssueFeedback(*error.Fingerprint, issueFeedbackLimit); err != nil {
+			return nil, err
+		}
+	}
+	if error.Attachments, err = s.ListAttachments(ctx, id); err != nil {
+		return nil, err
+	}
+	return error, nil
 }
 
-func (s *ErrorService) ResolveError(ctx context.Context, id uuid.UUID) error {
-	if err := s.db.ResolveError(id); err != nil {
+// ResolveError resolves the issue the error belongs to. With inNextRelease
+// it stays resolved until the issue shows up in a release newer than the
+// current one.
+func (s *ErrorService) ResolveError(ctx context.Context, id uuid.UUID, inNextRelease bool) error {
+	error, err := s.db.GetErrorByID(id)
+	if err != nil {
+		return err
+	}
+	if error.Fingerprint == nil {
+		err = s.db.ResolveError(id)
+	} else {
+		err = s.resolveIssue(error.ProjectID, *error.Fingerprint, inNextRelease)
+	}
+	if err != nil {
 		return err
 	}
 	log.Printf("CACHE INVALIDATION: ResolveError - invalidating all caches for error ID: %s", id)
@@ -135,6 +299,11 @@ func (s *ErrorService) DeleteError(ctx context.Context, id uuid.UUID) error {
 	if err := s.db.DeleteError(id); err != nil {
 		return err
 	}
+	if attachments, err := s.db.ListEventAttachments(id); err != nil {
+		log.Printf("Failed to list attachments of deleted error %s: %v", id, err)
+	} else if _, err := s.deleteAttachments(ctx, attachments); err != nil {
+		log.Printf("Failed to delete attachments of error %s: %v", id, err)
+	}
 	log.Printf("CACHE INVALIDATION: DeleteError - invalidating all caches for error ID: %s", id)
 	go s.redis.InvalidateAllCache(context.Background())
 	return nil
@@ -199,7 +368,7 @@ func (s *ErrorService) StartQueueProcessor(ctx context.Context) {
 }
 
 func (s *ErrorService) processError(ctx context.Context, error *models.Error) error {
-	if err := s.db.CreateError(error); err != nil {
+	if err := s.persist(error); err != nil {
 		return err
 	}
 	log.Printf("CACHE INVALIDATION: processError - invalidating all caches for processed error")
@@ -207,6 +376,24 @@ func (s *ErrorService) processError(ctx context.Context, error *models.Error) er
 	return nil
 }
 
+func filterCacheKey(filter models.ErrorFilter) string {
+	bot := ""
+	if filter.Bot != nil {
+		bot = fmt.Sprint(*filter.Bot)
+	}
+	return fmt.Sprintf("%s_%s_%s_%s_%s_%d_%s_%s_%s_%s_%s", filter.Level, filter.Source,
+		filter.Country, filter.Region, filter.City, filter.ASN,
+		filter.Browser, filter.BrowserVersion, filter.OS, filter.Device, bot) + "_" + filter.Release +
+		"_" + filter.TraceID + "_" + filter.RequestID + tagsCacheKey(filter.Tags)
+}
+
+func optionalString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
 func generateFingerprint(message string, stackTrace *string) string {
 	data := message
 	if stackTrace != nil {
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+	"error-logs/internal/notify"
+)
+
+const (
+	maxFeedbackName     = 100
+	maxFeedbackEmail    = 200
+	maxFeedbackComments = 5000
+	issueFeedbackLimit  = 50
+)
+
+// CreateFeedback stores end-user feedback on an event. The event doesn't
+// have to exist yet, since it may still be queued. Invalid requests are
+// rejected with an error prefixed "invalid feedback".
+func (s *ErrorService) CreateFeedback(ctx context.Context, req *models.CreateFeedbackRequest) (*models.Feedback, error) {
+	eventID, err := uuid.Parse(req.EventID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feedback: event_id must be an error ID")
+	}
+	comments := strings.TrimSpace(req.Comments)
+	if comments == "" {
+		return nil, fmt.Errorf("invalid feedback: comments are required")
+	}
+	if len(comments) > maxFeedbackComments {
+		return nil, fmt.Errorf("invalid feedback: comments are limited to %d bytes", maxFeedbackComments)
+	}
+	email := strings.TrimSpace(req.Email)
+	if email != "" {
+		addr, err := mail.ParseAddress(email)
+		if err != nil || len(addr.Address) > maxFeedbackEmail {
+			return nil, fmt.Errorf("invalid feedback: %q is not an email address", email)
+		}
+		email = addr.Address
+	}
+
+	projectID := ProjectIDFromContext(ctx)
+	scrubber := s.projectConfig(projectID).scrubber
+	feedback := &models.Feedback{
+		ID:        uuid.New(),
+		ProjectID: projectID,
+		EventID:   eventID,
+		Name:      truncate(scrubber.String(strings.TrimSpace(req.Name)), maxFeedbackName),
+		Email:     scrubber.String(email),
+		Comments:  scrubber.String(comments),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.db.CreateFeedback(feedback); err != nil {
+		return nil, err
+	}
+
+	s.notif.Send(notify.Notification{
+		Type:      notify.TypeFeedback,
+		ProjectID: projectID,
+		Timestamp: feedback.CreatedAt,
+		Data:      feedback,
+	})
+	return feedback, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+const (
+	IPModeStore    = "store"
+	IPModeTruncate = "truncate"
+	IPModeHash     = "hash"
+	IPModeDrop     = "drop"
+
+	// ipSaltRotation is how long one hashing salt is used. Salts are deleted
+	// after two periods, after which old hashes can no longer be linked to
+	// new traffic from the same address.
+	ipSaltRotation = 24 * time.Hour
+
+	ipRetentionInterval = time.Hour
+)
+
+func validateIPSettings(settings *models.IPSettings) error {
+	switch settings.Mode {
+	case "", IPModeStore, IPModeTruncate, IPModeHash, IPModeDrop:
+	default:
+		return fmt.Errorf("unknown IP mode %q", settings.Mode)
+	}
+	if settings.IPv4Prefix < 0 || settings.IPv4Prefix > 32 {
+		return fmt.Errorf("ipv4_prefix must be between 0 and 32")
+	}
+	if settings.IPv6Prefix < 0 || settings.IPv6Prefix > 128 {
+		return fmt.Errorf("ipv6_prefix must be between 0 and 128")
+	}
+	if settings.RetentionDays < 0 {
+		return fmt.Errorf("retention_days must not be negative")
+	}
+	for i, header := range settings.TrustedHeaders {
+		if header == "" {
+			return fmt.Errorf("trusted header %d is empty", i)
+		}
+		settings.TrustedHeaders[i] = http.CanonicalHeaderKey(header)
+	}
+	return nil
+}
+
+// TrustedIPHeaders returns the proxy headers the caller's project trusts for
+// the client IP, or nil for the default.
+func (s *ErrorService) TrustedIPHeaders(ctx context.Context) []string {
+	return s.projectConfig(ProjectIDFromContext(ctx)).settings.IP.TrustedHeaders
+}
+
+// anonymizeIP applies a project's IP mode. An empty result means the address
+// must not be stored.
+func (s *ErrorService) anonymizeIP(ctx context.Context, projectID *uuid.UUID, settings *models.IPSettings, ipAddress string) string {
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return ""
+	}
+
+	switch settings.Mode {
+	case IPModeTruncate:
+		return truncateIP(ip, settings.IPv4Prefix, settings.IPv6Prefix)
+	case IPModeHash:
+		salt, err := s.ipSalt(ctx)
+		if err != nil {
+			// Never fall back to the raw address.
+			log.Printf("Failed to get IP salt, dropping address: %v", err)
+			return ""
+		}
+		scope := ""
+		if projectID != nil {
+			scope = projectID.String()
+		}
+		mac := hmac.New(sha256.New, []byte(salt))
+		mac.Write([]byte(scope + "|" + ip.String()))
+		return fmt.Sprintf("hash:%x", mac.Sum(nil)[:8])
+	case IPModeDrop:
+		return ""
+	default:
+		return ip.String()
+	}
+}
+
+func truncateIP(ip net.IP, ipv4Prefix, ipv6Prefix int) string {
+	if ipv4Prefix == 0 {
+		ipv4Prefix = 24
+	}
+	if ipv6Prefix == 0 {
+		ipv6Prefix = 48
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(ipv4Prefix, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(ipv6Prefix, 128)).String()
+}
+
+// ipSalt returns the salt for the current rotation period. It is stored in
+// the database so every instance hashes the same address to the same value,
+// and it survives restarts until it expires.
+func (s *ErrorService) ipSalt(ctx context.Context) (string, error) {
+	start := time.Now().UTC().Truncate(ipSaltRotation)
+	period := start.Format("2006-01-02")
+
+	s.ipSaltMu.Lock()
+	defer s.ipSaltMu.Unlock()
+	if s.ipSaltPeriod == period {
+		return s.ipSaltValue, nil
+	}
+
+	salt, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+	expiresAt := start.Add(2 * ipSaltRotation)
+	if salt, err = s.db.GetOrCreateSecret("ip_salt:"+period, salt, &expiresAt); err != nil {
+		return "", err
+	}
+	s.ipSaltPeriod, s.ipSaltValue = period, salt
+	return salt, nil
+}
+
+// StartIPRetention periodically clears stored IPs of projects that set
+// retention_days, and deletes expired IP salts.
+func (s *ErrorService) StartIPRetention(ctx context.Context) {
+	ticker := time.NewTicker(ipRetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		s.clearExpiredIPs()
+		if _, err := s.db.DeleteExpiredSecrets(time.Now()); err != nil {
+			log.Printf("Failed to delete expired IP salts: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ErrorService) clearExpiredIPs() {
+	projects, err := s.db.ListProjectSettings()
+	if err != nil {
+		log.Printf("Failed to list project settings: %v", err)
+		return
+	}
+
+	var cleared int64
+	for projectID, settings := range projects {
+		if settings.IP.RetentionDays <= 0 {
+			continue
+		}
+		before := time.Now().AddDate(0, 0, -settings.IP.RetentionDays)
+		n, err := s.db.ClearIPAddresses(projectID, before)
+		if err != nil {
+			log.Printf("Failed to clear IP addresses for project %s: %v", projectID, err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Cleared IP addresses from %d errors of project %s", n, projectID)
+		}
+		cleared += n
+	}
+
+	if cleared > 0 {
+		log.Printf("CACHE INVALIDATION: clearExpiredIPs - invalidating all caches")
+		s.redis.InvalidateAllCache(context.Background())
+	}
+}
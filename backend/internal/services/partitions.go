@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/archive"
+	"error-logs/internal/database"
+	"error-logs/internal/models"
+	"error-logs/internal/storage"
+)
+
+const partitionMaintenanceEvery = time.Hour
+
+// PartitionConfig controls the time partitions of the errors table.
+type PartitionConfig struct {
+	Interval  string // database.PartitionDay or database.PartitionWeek
+	Ahead     int    // partitions kept ready beyond the current one
+	Retention time.Duration
+}
+
+// StartPartitionMaintenance keeps partitions created ahead of incoming
+// events and, when a retention is set, drops those that have expired under
+// it and every retention policy, archiving their events first when
+// retention archives.
+func (s *ErrorService) StartPartitionMaintenance(ctx context.Context, cfg PartitionConfig) {
+	partitions, ok := s.db.(storage.Partitioner)
+	if !ok {
+		return // the backend doesn't partition events
+	}
+	partitioned, err := partitions.IsPartitioned()
+	if err != nil {
+		log.Printf("Failed to check errors table partitioning: %v", err)
+		return
+	}
+	if !partitioned {
+		log.Printf("The errors table isn't partitioned; run migrations to enable partition maintenance")
+		return
+	}
+
+	ticker := time.NewTicker(partitionMaintenanceEvery)
+	defer ticker.Stop()
+
+	for {
+		s.maintainPartitions(ctx, partitions, cfg)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ErrorService) maintainPartitions(ctx context.Context, partitions storage.Partitioner, cfg PartitionConfig) {
+	now := time.Now()
+	span := 24 * time.Hour
+	if cfg.Interval == database.PartitionWeek {
+		span *= 7
+	}
+
+	created, err := partitions.EnsurePartitions(cfg.Interval, now.Add(time.Duration(cfg.Ahead+1)*span))
+	for _, name := range created {
+		log.Printf("Created partition %s", name)
+	}
+	if err != nil {
+		log.Printf("Failed to create partitions: %v", err)
+	}
+
+	if cfg.Retention <= 0 {
+		return
+	}
+	cutoff, ok := s.partitionCutoff(now, cfg.Retention)
+	if !ok {
+		return
+	}
+	var beforeDrop func(string) error
+	if s.retention.Archive != nil {
+		w := archive.NewWriter(s.retention.Archive)
+		beforeDrop = func(partition string) error {
+			return archivePartition(ctx, partitions, partition, w)
+		}
+	}
+	dropped, err := partitions.DropPartitionsBefore(cutoff, beforeDrop)
+	for _, name := range dropped {
+		log.Printf("Dropped expired partition %s", name)
+	}
+	if err != nil {
+		log.Printf("Failed to drop expired partitions: %v", err)
+	}
+	if len(dropped) > 0 {
+		log.Printf("CACHE INVALIDATION: maintainPartitions - invalidating all caches")
+		s.redis.InvalidateAllCache(context.Background())
+	}
+}
+
+// partitionCutoff returns the time before which partitions may be dropped:
+// the partition retention, pushed back to the longest retention policy of
+// any project or level so a drop never removes events a policy still keeps.
+// When a policy keeps events indefinitely, or the policies can't be read,
+// nothing is dropped and row retention expires events instead.
+func (s *ErrorService) partitionCutoff(now time.Time, retention time.Duration) (time.Time, bool) {
+	projects, err := s.db.ListProjectSettings()
+	if err != nil {
+		log.Printf("Failed to list project settings, not dropping partitions: %v", err)
+		return time.Time{}, false
+	}
+
+	policies := []models.RetentionSettings{effectiveRetention(s.retention.Defaults, models.RetentionSettings{})}
+	for _, settings := range projects {
+		policies = append(policies, effectiveRetention(s.retention.Defaults, settings.Retention))
+	}
+	days, bounded := longestRetentionDays(policies)
+	if !bounded {
+		log.Printf("Not dropping partitions: a retention policy keeps some events indefinitely")
+		return time.Time{}, false
+	}
+
+	cutoff := now.Add(-retention)
+	if policyCutoff := now.AddDate(0, 0, -days); policyCutoff.Before(cutoff) {
+		cutoff = policyCutoff
+	}
+	return cutoff, true
+}
+
+// longestRetentionDays returns the most days any of the policies keeps an
+// event, or false when one keeps some level indefinitely. Resolved events
+// only ever expire sooner, so ResolvedDays doesn't matter here.
+func longestRetentionDays(policies []models.RetentionSettings) (int, bool) {
+	longest := 0
+	for _, policy := range policies {
+		if policy.Days <= 0 {
+			return 0, false
+		}
+		longest = max(longest, policy.Days)
+		for _, days := range policy.Levels {
+			if days <= 0 {
+				return 0, false
+			}
+			longest = max(longest, days)
+		}
+	}
+	return longest, true
+}
+
+// archivePartition archives every event of a partition about to be dropped.
+func archivePartition(ctx context.Context, partitions storage.Partitioner, partition string, w *archive.Writer) error {
+	var afterTimestamp time.Time
+	var afterID *uuid.UUID
+	archived := 0
+	for {
+		events, err := partitions.ListPartitionErrors(partition, afterTimestamp, afterID, retentionBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			if err := w.Write(ctx, events); err != nil {
+				return fmt.Errorf("failed to archive partition %s: %w", partition, err)
+			}
+			archived += len(events)
+		}
+		if len(events) < retentionBatchSize {
+			break
+		}
+		last := events[len(events)-1]
+		afterTimestamp, afterID = last.Timestamp, &last.ID
+	}
+	log.Printf("Archived %d errors from partition %s", archived, partition)
+	return nil
+}
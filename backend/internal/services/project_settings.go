@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+	"error-logs/internal/scrub"
+)
+
+// Settings are read on every ingested event, so they are kept in memory
+// briefly rather than fetched from Postgres each time.
+const projectSettingsTTL = time.Minute
+
+// scrubSaltSecret names the server secret that projects' scrubbing salts are
+// derived from.
+const scrubSaltSecret = "scrub_salt"
+
+type projectConfig struct {
+	settings *models.ProjectSettings
+	scrubber *scrub.Scrubber
+	loadedAt time.Time
+}
+
+func newProjectConfig(settings *models.ProjectSettings, salt string) (*projectConfig, error) {
+	if err := validateIPSettings(&settings.IP); err != nil {
+		return nil, err
+	}
+	if err := validateBreadcrumbSettings(&settings.Breadcrumbs); err != nil {
+		return nil, err
+	}
+	if err := validateRetentionSettings(&settings.Retention); err != nil {
+		return nil, err
+	}
+	scrubber, err := scrub.New(settings.Scrubbing, salt)
+	if err != nil {
+		return nil, err
+	}
+	return &projectConfig{
+		settings: settings,
+		scrubber: scrubber,
+		loadedAt: time.Now(),
+	}, nil
+}
+
+// projectConfig returns the cached settings for a project, or the defaults
+// for events without one. It fails closed: when a project's settings can't
+// be loaded, its previous settings stay in use, and without those every
+// detector runs and IPs are dropped, so a lookup failure never stores more
+// than the project allows. The next event retries the lookup.
+func (s *ErrorService) projectConfig(projectID *uuid.UUID) *projectConfig {
+	if projectID == nil {
+		return s.defaultConfig
+	}
+
+	s.projectsMu.Lock()
+	cached, ok := s.projects[*projectID]
+	s.projectsMu.Unlock()
+	if ok && time.Since(cached.loadedAt) < projectSettingsTTL {
+		return cached
+	}
+
+	cfg, err := s.loadProjectConfig(*projectID)
+	if err != nil {
+		if ok {
+			log.Printf("Failed to load settings for project %s, keeping the previous ones: %v", projectID, err)
+			return cached
+		}
+		log.Printf("Failed to load settings for project %s, applying the strictest settings: %v", projectID, err)
+		return s.strictConfig
+	}
+
+	s.projectsMu.Lock()
+	s.projects[*projectID] = cfg
+	s.projectsMu.Unlock()
+	return cfg
+}
+
+func (s *ErrorService) loadProjectConfig(projectID uuid.UUID) (*projectConfig, error) {
+	settings, err := s.db.GetProjectSettings(projectID)
+	if err != nil {
+		return nil, err
+	}
+	salt, err := s.projectSalt(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scrubbing salt: %w", err)
+	}
+	cfg, err := newProjectConfig(settings, salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	return cfg, nil
+}
+
+func (s *ErrorService) GetProjectSettings(ctx context.Context, projectID uuid.UUID) (*models.ProjectSettings, error) {
+	return s.db.GetProjectSettings(projectID)
+}
+
+// UpdateProjectSettings validates and stores new settings. Invalid settings
+// (unknown detectors, bad regular expressions, bad IP or retention options) are rejected with an error
+// prefixed "invalid settings".
+func (s *ErrorService) UpdateProjectSettings(ctx context.Context, projectID uuid.UUID, settings *models.ProjectSettings) error {
+	salt, err := s.projectSalt(projectID)
+	if err != nil {
+		return err
+	}
+	cfg, err := newProjectConfig(settings, salt)
+	if err != nil {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+	if err := s.db.UpdateProjectSettings(projectID, settings); err != nil {
+		return err
+	}
+
+	s.projectsMu.Lock()
+	s.projects[projectID] = cfg
+	s.projectsMu.Unlock()
+	return nil
+}
+
+// projectSalt returns the salt for hashing a project's scrubbed values. It is
+// derived from a random server secret rather than anything public like the
+// project ID, so hashes of guessable values such as email addresses can't be
+// recomputed from outside.
+func (s *ErrorService) projectSalt(projectID uuid.UUID) (string, error) {
+	s.scrubSaltMu.Lock()
+	defer s.scrubSaltMu.Unlock()
+	if s.scrubSalt == "" {
+		secret, err := newSecret()
+		if err != nil {
+			return "", err
+		}
+		if secret, err = s.db.GetOrCreateSecret(scrubSaltSecret, secret, nil); err != nil {
+			return "", err
+		}
+		s.scrubSalt = secret
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.scrubSalt))
+	mac.Write(projectID[:])
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func newSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+	"error-logs/internal/notify"
+)
+
+// persist stores an event after resolving its stack frames, recording its
+// release and checking it against the issue's resolution, then counts its
+// user. Bookkeeping failures are logged rather than losing the event.
+func (s *ErrorService) persist(error *models.Error) error {
+	s.symbolicate(error)
+	if error.Fingerprint != nil {
+		if err := s.trackRelease(error); err != nil {
+			log.Printf("Failed to track release for issue %s: %v", *error.Fingerprint, err)
+		}
+	}
+	if err := s.db.CreateError(error); err != nil {
+		return err
+	}
+	s.countUser(error)
+	return nil
+}
+
+func (s *ErrorService) trackRelease(error *models.Error) error {
+	fingerprint := *error.Fingerprint
+	if error.Release != nil {
+		if err := s.db.RecordRelease(error.ProjectID, fingerprint, *error.Release, error.Timestamp); err != nil {
+			return err
+		}
+	}
+
+	state, err := s.db.GetIssueState(error.ProjectID, fingerprint)
+	if err != nil || state == nil || state.Status == models.IssueStatusRegressed {
+		return err
+	}
+
+	regressed, err := s.isRegression(state, error)
+	if err != nil {
+		return err
+	}
+	if !regressed {
+		// Still resolved: the event is from a release without the fix
+		error.Resolved = true
+		return nil
+	}
+
+	release := "unknown"
+	if error.Release != nil {
+		release = *error.Release
+	}
+	log.Printf("REGRESSION: issue %s reappeared in release %s", fingerprint, release)
+	if err := s.db.RegressIssue(error.ProjectID, fingerprint, error.Release); err != nil {
+		return err
+	}
+	s.notif.Send(notify.Notification{
+		Type:      notify.TypeRegression,
+		ProjectID: error.ProjectID,
+		Data: map[string]string{
+			"error_id":    error.ID.String(),
+			"fingerprint": fingerprint,
+			"release":     release,
+		},
+	})
+	return nil
+}
+
+// isRegression decides whether an event reopens a resolved issue: it must
+// have happened after the resolution and come from a release newer than the
+// one the issue was resolved in. When releases aren't reported, any later
+// event of a plainly resolved issue counts.
+func (s *ErrorService) isRegression(state *models.IssueState, error *models.Error) (bool, error) {
+	if !error.Timestamp.After(state.ResolvedAt) {
+		return false, nil
+	}
+
+	switch state.Status {
+	case models.IssueStatusResolved:
+		if error.Release == nil || state.ResolvedIn == nil {
+			return true, nil
+		}
+	case models.IssueStatusResolvedInNextRelease:
+		if error.Release == nil {
+			return false, nil
+		}
+		if state.ResolvedIn == nil {
+			return true, nil
+		}
+	default:
+		return false, nil
+	}
+	return s.db.IsNewerRelease(error.ProjectID, *error.Release, *state.ResolvedIn)
+}
+
+// resolveIssue resolves every event of the project sharing the fingerprint.
+// A plain resolve is pinned to the newest release the issue was seen in; "in
+// next release" is pinned to the project's newest release, so only a later
+// deploy reopens it.
+func (s *ErrorService) resolveIssue(projectID *uuid.UUID, fingerprint string, inNextRelease bool) error {
+	status, scope := models.IssueStatusResolved, fingerprint
+	if inNextRelease {
+		status, scope = models.IssueStatusResolvedInNextRelease, ""
+	}
+
+	latest, err := s.db.LatestRelease(projectID, scope)
+	if err != nil {
+		return err
+	}
+	return s.db.ResolveIssue(projectID, fingerprint, status, optionalString(latest))
+}
+
+func (s *ErrorService) GetIssueReleases(ctx context.Context, id uuid.UUID) (*models.IssueReleasesResponse, error) {
+	error, err := s.db.GetErrorByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if error.Fingerprint == nil {
+		return nil, fmt.Errorf("error not found")
+	}
+
+	state, err := s.db.GetIssueState(error.ProjectID, *error.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	releases, err := s.db.GetIssueReleases(error.ProjectID, *error.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	return &models.IssueReleasesResponse{
+		Fingerprint: *error.Fingerprint,
+		State:       state,
+		Releases:    releases,
+	}, nil
+}
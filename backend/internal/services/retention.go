@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/archive"
+	"error-logs/internal/blob"
+	"error-logs/internal/models"
+)
+
+const (
+	retentionEvery      = time.Hour
+	retentionBatchSize  = 1000
+	retentionBatchPause = 100 * time.Millisecond
+)
+
+// RetentionConfig is the default retention policy, which projects can
+// override, and where expired events are archived before being deleted.
+type RetentionConfig struct {
+	Defaults models.RetentionSettings
+	Archive  blob.Store // nil to delete without archiving
+}
+
+func validateRetentionSettings(settings *models.RetentionSettings) error {
+	if settings.Days < 0 || settings.ResolvedDays < 0 {
+		return fmt.Errorf("retention.days and retention.resolved_days must not be negative")
+	}
+	for level, days := range settings.Levels {
+		if level == "" {
+			return fmt.Errorf("retention.levels must not have an empty level")
+		}
+		if days < 0 {
+			return fmt.Errorf("retention.levels[%q] must not be negative", level)
+		}
+	}
+	return nil
+}
+
+// effectiveRetention overlays a project's retention settings on the server
+// defaults.
+func effectiveRetention(defaults, project models.RetentionSettings) models.RetentionSettings {
+	policy := models.RetentionSettings{
+		Days:         orDefault(project.Days, defaults.Days),
+		ResolvedDays: orDefault(project.ResolvedDays, defaults.ResolvedDays),
+		Levels:       make(map[string]int),
+	}
+	for level, days := range defaults.Levels {
+		policy.Levels[level] = days
+	}
+	for level, days := range project.Levels {
+		if days > 0 {
+			policy.Levels[level] = days
+		}
+	}
+	return policy
+}
+
+// retentionRules turns a policy into rules, each with a description for the
+// report. A level listed with 0 days is kept, even past the policy's Days.
+func retentionRules(projectID *uuid.UUID, policy models.RetentionSettings, now time.Time) ([]models.RetentionRule, []string) {
+	var rules []models.RetentionRule
+	var descriptions []string
+	before := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+
+	levels := make([]string, 0, len(policy.Levels))
+	for level := range policy.Levels {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+
+	for _, level := range levels {
+		if days := policy.Levels[level]; days > 0 {
+			rules = append(rules, models.RetentionRule{ProjectID: projectID, Levels: []string{level}, Before: before(days)})
+			descriptions = append(descriptions, fmt.Sprintf("level %s older than %d days", level, days))
+		}
+	}
+	if policy.Days > 0 {
+		rules = append(rules, models.RetentionRule{ProjectID: projectID, ExceptLevels: levels, Before: before(policy.Days)})
+		descriptions = append(descriptions, fmt.Sprintf("other levels older than %d days", policy.Days))
+	}
+	if policy.ResolvedDays > 0 {
+		rules = append(rules, models.RetentionRule{ProjectID: projectID, ResolvedOnly: true, Before: before(policy.ResolvedDays)})
+		descriptions = append(descriptions, fmt.Sprintf("resolved older than %d days", policy.ResolvedDays))
+	}
+	return rules, descriptions
+}
+
+// StartRetention deletes expired events every hour.
+func (s *ErrorService) StartRetention(ctx context.Context) {
+	ticker := time.NewTicker(retentionEvery)
+	defer ticker.Stop()
+
+	for {
+		s.applyRetention(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ErrorService) applyRetention(ctx context.Context) {
+	projects, err := s.db.ListProjectSettings()
+	if err != nil {
+		log.Printf("Failed to list project settings: %v", err)
+		return
+	}
+
+	var w *archive.Writer
+	if s.retention.Archive != nil {
+		w = archive.NewWriter(s.retention.Archive)
+	}
+
+	report := &models.RetentionReport{StartedAt: time.Now().UTC(), Rules: []models.RetentionRuleOutcome{}}
+	apply := func(projectID *uuid.UUID, policy models.RetentionSettings) {
+		rules, descriptions := retentionRules(projectID, policy, report.StartedAt)
+		for i, rule := range rules {
+			deleted, err := s.deleteExpired(ctx, rule, w)
+			outcome := models.RetentionRuleOutcome{
+				ProjectID: projectID,
+				Rule:      descriptions[i],
+				Before:    rule.Before,
+				Deleted:   deleted,
+			}
+			if err != nil {
+				outcome.Error = err.Error()
+				log.Printf("RETENTION: failed to apply %q to project %s: %v", descriptions[i], projectName(projectID), err)
+			}
+			if deleted > 0 {
+				log.Printf("RETENTION: deleted %d errors of project %s (%s)", deleted, projectName(projectID), descriptions[i])
+			}
+			report.Deleted += deleted
+			report.Rules = append(report.Rules, outcome)
+		}
+	}
+
+	apply(nil, effectiveRetention(s.retention.Defaults, models.RetentionSettings{}))
+	for projectID, settings := range projects {
+		projectID := projectID
+		apply(&projectID, effectiveRetention(s.retention.Defaults, settings.Retention))
+	}
+	report.FinishedAt = time.Now().UTC()
+
+	s.retentionMu.Lock()
+	s.lastRetention = report
+	s.retentionMu.Unlock()
+
+	if report.Deleted > 0 {
+		log.Printf("RETENTION: deleted %d expired errors in %v", report.Deleted, report.FinishedAt.Sub(report.StartedAt))
+		log.Printf("CACHE INVALIDATION: applyRetention - invalidating all caches")
+		s.redis.InvalidateAllCache(context.Background())
+	}
+}
+
+// deleteExpired deletes a rule's events in batches, pausing between them so
+// retention doesn't crowd out ingestion. With a writer each batch is
+// archived first, and nothing more is deleted once archiving fails.
+func (s *ErrorService) deleteExpired(ctx context.Context, rule models.RetentionRule, w *archive.Writer) (int64, error) {
+	var total int64
+	for {
+		var n int64
+		var more bool
+		var err error
+		if w != nil {
+			n, more, err = s.archiveExpired(ctx, rule, w)
+		} else {
+			n, err = s.db.DeleteExpiredErrors(rule, retentionBatchSize)
+			more = n == retentionBatchSize
+		}
+		total += n
+		if err != nil || !more {
+			return total, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(retentionBatchPause):
+		}
+	}
+}
+
+// GetRetention returns a project's effective retention policy and what the
+// last run deleted from it.
+func (s *ErrorService) GetRetention(ctx context.Context, projectID uuid.UUID) (*models.RetentionResponse, error) {
+	settings, err := s.db.GetProjectSettings(projectID)
+	if err != nil {
+		return nil, err
+	}
+	response := &models.RetentionResponse{Policy: effectiveRetention(s.retention.Defaults, settings.Retention)}
+
+	s.retentionMu.Lock()
+	last := s.lastRetention
+	s.retentionMu.Unlock()
+	if last == nil {
+		return response, nil
+	}
+
+	run := &models.RetentionReport{StartedAt: last.StartedAt, FinishedAt: last.FinishedAt, Rules: []models.RetentionRuleOutcome{}}
+	for _, outcome := range last.Rules {
+		if outcome.ProjectID != nil && *outcome.ProjectID == projectID {
+			run.Rules = append(run.Rules, outcome)
+			run.Deleted += outcome.Deleted
+		}
+	}
+	response.LastRun = run
+	return response, nil
+}
+
+// archiveExpired archives and deletes one batch of a rule's events,
+// reporting whether there may be more.
+func (s *ErrorService) archiveExpired(ctx context.Context, rule models.RetentionRule, w *archive.Writer) (int64, bool, error) {
+	events, err := s.db.ListExpiredErrors(rule, retentionBatchSize)
+	if err != nil || len(events) == 0 {
+		return 0, false, err
+	}
+	if err := w.Write(ctx, events); err != nil {
+		return 0, false, fmt.Errorf("failed to archive expired errors: %w", err)
+	}
+	deleted, err := s.db.DeleteArchivedErrors(rule, events)
+	return deleted, len(events) == retentionBatchSize, err
+}
+
+func projectName(projectID *uuid.UUID) string {
+	if projectID == nil {
+		return "(none)"
+	}
+	return projectID.String()
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+	"error-logs/internal/sourcemap"
+)
+
+const (
+	maxSourceMapSize   = 50 << 20
+	maxSourceMapName   = 200
+	maxStackFrames     = 100
+	maxContextLine     = 200
+	sourceMapCacheSize = 64
+	sourceMapCacheTTL  = 5 * time.Minute
+)
+
+// Browser stack frames: V8 (Chrome, Edge, Node) writes "at fn (url:1:2)" or
+// "at url:1:2"; SpiderMonkey and JavaScriptCore write "fn@url:1:2".
+var (
+	v8FrameRe    = regexp.MustCompile(`^\s*at (?:(.+?) \()?(.+?):(\d+):(\d+)\)?$`)
+	geckoFrameRe = regexp.MustCompile(`^\s*([^@]*)@(.+?):(\d+):(\d+)$`)
+)
+
+// sourceMapCache keeps recently used maps parsed, including the fact that a
+// file has none, so each event doesn't hit the database and blob storage.
+type sourceMapCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedSourceMap
+}
+
+type cachedSourceMap struct {
+	m        *sourcemap.Map // nil when no map was uploaded
+	loadedAt time.Time
+}
+
+func sourceMapCacheKey(projectID *uuid.UUID, release, name string) string {
+	project := ""
+	if projectID != nil {
+		project = projectID.String()
+	}
+	return project + "\x00" + release + "\x00" + name
+}
+
+func (c *sourceMapCache) get(key string) (cachedSourceMap, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Since(entry.loadedAt) > sourceMapCacheTTL {
+		return cachedSourceMap{}, false
+	}
+	return entry, true
+}
+
+func (c *sourceMapCache) put(key string, m *sourcemap.Map) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || len(c.entries) >= sourceMapCacheSize {
+		c.entries = make(map[string]cachedSourceMap)
+	}
+	c.entries[key] = cachedSourceMap{m: m, loadedAt: time.Now()}
+}
+
+func (c *sourceMapCache) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// UploadSourceMap validates a source map and stores it for a release of the
+// caller's project. It is matched to stack frames by the file name of the minified script, taken
+// from the map's "file" field or else the upload name without ".map".
+// Errors are prefixed "invalid source map" for rejected uploads and
+// "source map too large" past the size limit.
+func (s *ErrorService) UploadSourceMap(ctx context.Context, release, filename string, r io.Reader) (*models.SourceMap, error) {
+	release = strings.TrimSpace(release)
+	if release == "" {
+		return nil, fmt.Errorf("invalid source map: release is required")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxSourceMapSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source map: %w", err)
+	}
+	if len(data) > maxSourceMapSize {
+		return nil, fmt.Errorf("source map too large: limit is %d MB", maxSourceMapSize>>20)
+	}
+	m, err := sourcemap.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source map: %v", err)
+	}
+
+	name := path.Base(m.File)
+	if m.File == "" {
+		name = strings.TrimSuffix(path.Base(filename), ".map")
+	}
+	if name == "" || name == "." || name == "/" || len(name) > maxSourceMapName {
+		return nil, fmt.Errorf("invalid source map: can't tell which file %q maps", filename)
+	}
+
+	projectID := ProjectIDFromContext(ctx)
+	project := "default"
+	if projectID != nil {
+		project = projectID.String()
+	}
+	sum := sha256.Sum256([]byte(release))
+	sm := &models.SourceMap{
+		ProjectID:  projectID,
+		Release:    release,
+		Name:       name,
+		Size:       int64(len(data)),
+		StorageKey: fmt.Sprintf("sourcemaps/%s/%s/%s", project, hex.EncodeToString(sum[:]), url.PathEscape(name)),
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.blobs.Put(ctx, sm.StorageKey, bytes.NewReader(data), sm.Size, "application/json"); err != nil {
+		return nil, err
+	}
+	if err := s.db.SaveSourceMap(sm); err != nil {
+		return nil, err
+	}
+	s.sourceMaps.invalidate(sourceMapCacheKey(projectID, release, name))
+
+	log.Printf("Stored source map for %s in release %s (%d bytes)", name, release, sm.Size)
+	return sm, nil
+}
+
+func (s *ErrorService) ListSourceMaps(ctx context.Context, release string) ([]models.SourceMap, error) {
+	return s.db.ListSourceMaps(ProjectIDFromContext(ctx), release)
+}
+
+// symbolicate splits a JavaScript stack trace into frames and resolves those
+// whose script has a source map in the event's project and release. Stacks in other
+// formats are left alone.
+func (s *ErrorService) symbolicate(error *models.Error) {
+	if error.StackTrace == nil {
+		return
+	}
+	frames := parseStackFrames(*error.StackTrace)
+	if len(frames) == 0 {
+		return
+	}
+	if error.Release != nil {
+		for i := range frames {
+			s.resolveFrame(error.ProjectID, *error.Release, &frames[i])
+		}
+	}
+	error.Frames = frames
+}
+
+func (s *ErrorService) resolveFrame(projectID *uuid.UUID, release string, frame *models.StackFrame) {
+	m, err := s.loadSourceMap(projectID, release, scriptName(frame.File))
+	if err != nil {
+		log.Printf("Failed to load source map for %s in release %s: %v", frame.File, release, err)
+		return
+	}
+	if m == nil {
+		return
+	}
+
+	pos, ok := m.Lookup(frame.Line-1, frame.Column-1)
+	if !ok {
+		return
+	}
+	function := pos.Name
+	if function == "" {
+		function = frame.Function
+	}
+	frame.Resolved = &models.ResolvedFrame{
+		Function: function,
+		File:     pos.Source,
+		Line:     pos.Line + 1,
+		Column:   pos.Column + 1,
+	}
+	if line, ok := m.SourceLine(pos.Source, pos.Line); ok {
+		frame.Resolved.ContextLine = truncate(strings.TrimSpace(line), maxContextLine)
+	}
+}
+
+func (s *ErrorService) loadSourceMap(projectID *uuid.UUID, release, name string) (*sourcemap.Map, error) {
+	key := sourceMapCacheKey(projectID, release, name)
+	if entry, ok := s.sourceMaps.get(key); ok {
+		return entry.m, nil
+	}
+
+	sm, err := s.db.GetSourceMap(projectID, release, name)
+	if err != nil {
+		return nil, err
+	}
+	if sm == nil {
+		s.sourceMaps.put(key, nil)
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	content, err := s.blobs.Get(ctx, sm.StorageKey)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	data, err := io.ReadAll(io.LimitReader(content, maxSourceMapSize))
+	if err != nil {
+		return nil, err
+	}
+	m, err := sourcemap.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	s.sourceMaps.put(key, m)
+	return m, nil
+}
+
+// parseStackFrames returns the frames of a V8 or Firefox/Safari style stack
+// trace, or none when no line looks like a frame.
+func parseStackFrames(stack string) []models.StackFrame {
+	var frames []models.StackFrame
+	for _, line := range strings.Split(stack, "\n") {
+		line = strings.TrimRight(line, "\r")
+		m := v8FrameRe.FindStringSubmatch(line)
+		if m == nil {
+			m = geckoFrameRe.FindStringSubmatch(line)
+		}
+		if m == nil {
+			continue
+		}
+		lineNo, _ := strconv.Atoi(m[3])
+		column, _ := strconv.Atoi(m[4])
+		frames = append(frames, models.StackFrame{
+			Function: m[1],
+			File:     m[2],
+			Line:     lineNo,
+			Column:   column,
+		})
+		if len(frames) == maxStackFrames {
+			break
+		}
+	}
+	return frames
+}
+
+// scriptName reduces a frame's script URL to the file name source maps are
+// stored under, e.g. "https://app.example.com/assets/index-abc123.js?v=2"
+// to "index-abc123.js".
+func scriptName(file string) string {
+	if i := strings.IndexAny(file, "?#"); i >= 0 {
+		file = file[:i]
+	}
+	return path.Base(file)
+}
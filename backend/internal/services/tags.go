@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+const (
+	maxTags           = 50
+	maxTagKeyLength   = 32
+	maxTagValueLength = 200
+	tagValuesPerKey   = 10
+)
+
+var tagKeyRe = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// ValidTagKey reports whether key can be stored as a tag.
+func ValidTagKey(key string) bool {
+	return len(key) <= maxTagKeyLength && tagKeyRe.MatchString(key)
+}
+
+// normalizeTags drops tags that can't be indexed rather than rejecting the
+// event: invalid keys, empty values and anything past the first maxTags keys
+// in sorted order. Values are trimmed and cut to maxTagValueLength.
+func normalizeTags(tags map[string]string) map[string]string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	normalized := make(map[string]string)
+	for _, key := range keys {
+		if len(normalized) == maxTags {
+			log.Printf("Dropping %d tags over the limit of %d", len(keys)-maxTags, maxTags)
+			break
+		}
+		value := strings.TrimSpace(strings.ReplaceAll(tags[key], "\n", " "))
+		if !ValidTagKey(key) || value == "" {
+			continue
+		}
+		normalized[key] = truncate(value, maxTagValueLength)
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+func tagsCacheKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "_%s=%s", key, tags[key])
+	}
+	return b.String()
+}
+
+// GetTagDistribution returns the most common values of every tag seen on the
+// issue an error belongs to.
+func (s *ErrorService) GetTagDistribution(ctx context.Context, id uuid.UUID) (*models.TagDistributionResponse, error) {
+	error, err := s.db.GetErrorByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if error.Fingerprint == nil {
+		return nil, fmt.Errorf("error not found")
+	}
+
+	start := time.Now()
+	tags, err := s.db.GetTagDistribution(*error.Fingerprint, tagValuesPerKey)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("DATABASE QUERY: GetTagDistribution completed in %v", time.Since(start))
+
+	return &models.TagDistributionResponse{
+		Fingerprint: *error.Fingerprint,
+		Tags:        tags,
+	}, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"error-logs/internal/models"
+	"error-logs/internal/tracecontext"
+)
+
+const (
+	maxRequestIDLength = 200
+	maxTraceErrors     = 1000
+)
+
+// normalizeTraceContext lowercases the trace and span IDs of an event and
+// drops the ones that aren't valid W3C IDs, so a malformed ID never costs the
+// event itself.
+func normalizeTraceContext(req *models.CreateErrorRequest) {
+	if req.TraceID != nil {
+		id := strings.ToLower(strings.TrimSpace(*req.TraceID))
+		if !tracecontext.ValidTraceID(id) {
+			log.Printf("Dropping invalid trace ID %q", *req.TraceID)
+			req.TraceID, req.SpanID = nil, nil
+		} else {
+			req.TraceID = &id
+		}
+	}
+	if req.SpanID != nil {
+		id := strings.ToLower(strings.TrimSpace(*req.SpanID))
+		if req.TraceID == nil || !tracecontext.ValidSpanID(id) {
+			req.SpanID = nil
+		} else {
+			req.SpanID = &id
+		}
+	}
+	if req.RequestID != nil {
+		id := truncate(strings.TrimSpace(*req.RequestID), maxRequestIDLength)
+		req.RequestID = optionalString(id)
+	}
+}
+
+// GetTraceErrors returns every error reported for a trace. Malformed IDs are
+// rejected with an error prefixed "invalid trace".
+func (s *ErrorService) GetTraceErrors(ctx context.Context, traceID string) (*models.TraceErrorsResponse, error) {
+	traceID = strings.ToLower(traceID)
+	if !tracecontext.ValidTraceID(traceID) {
+		return nil, fmt.Errorf("invalid trace: %q is not a 32 digit hex trace ID", traceID)
+	}
+
+	start := time.Now()
+	errors, err := s.db.GetTraceErrors(traceID, maxTraceErrors)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("DATABASE QUERY: GetTraceErrors completed in %v", time.Since(start))
+
+	return &models.TraceErrorsResponse{
+		TraceID: traceID,
+		Errors:  errors,
+	}, nil
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+const (
+	maxUserFieldLength = 200
+	userRollupInterval = 30 * time.Second
+	userRollupBatch    = 500
+	maxHourlyUserRange = 7 * 24 * time.Hour
+)
+
+// userGroupings are the group_by values affected-user counts accept.
+var userGroupings = map[string]bool{"hour": true, "day": true, "week": true, "month": true}
+
+// normalizeUser trims and bounds the user fields, dropping an IP that doesn't
+// parse. It returns nil when nothing identifies the user.
+func normalizeUser(user *models.EventUser) *models.EventUser {
+	if user == nil {
+		return nil
+	}
+	normalized := &models.EventUser{
+		ID:       truncate(strings.TrimSpace(user.ID), maxUserFieldLength),
+		Email:    truncate(strings.TrimSpace(user.Email), maxUserFieldLength),
+		Username: truncate(strings.TrimSpace(user.Username), maxUserFieldLength),
+	}
+	if ip := net.ParseIP(strings.TrimSpace(user.IPAddress)); ip != nil {
+		normalized.IPAddress = ip.String()
+	}
+	if userKey(normalized) == "" {
+		return nil
+	}
+	return normalized
+}
+
+// userKey is what makes a user unique, from the most to the least specific
+// field they were reported with.
+func userKey(user *models.EventUser) string {
+	switch {
+	case user.ID != "":
+		return "id:" + user.ID
+	case user.Email != "":
+		return "email:" + strings.ToLower(user.Email)
+	case user.Username != "":
+		return "username:" + user.Username
+	case user.IPAddress != "":
+		return "ip:" + user.IPAddress
+	}
+	return ""
+}
+
+// userBuckets are the all-time, daily and hourly sets an event's user is
+// counted in.
+func userBuckets(fingerprint string, at time.Time) []models.UserBucket {
+	at = at.UTC()
+	return []models.UserBucket{
+		allTimeUserBucket(fingerprint),
+		{Fingerprint: fingerprint, Granularity: models.UserBucketDay, Start: at.Truncate(24 * time.Hour)},
+		{Fingerprint: fingerprint, Granularity: models.UserBucketHour, Start: at.Truncate(time.Hour)},
+	}
+}
+
+func allTimeUserBucket(fingerprint string) models.UserBucket {
+	return models.UserBucket{Fingerprint: fingerprint, Granularity: models.UserBucketAll, Start: time.Unix(0, 0).UTC()}
+}
+
+// countUser adds a stored event's user to its issue's HyperLogLogs. Failures
+// only cost accuracy, so they are logged.
+func (s *ErrorService) countUser(error *models.Error) {
+	if error.User == nil || error.Fingerprint == nil {
+		return
+	}
+	key := userKey(error.User)
+	if key == "" {
+		return
+	}
+	if err := s.redis.AddUser(context.Background(), key, userBuckets(*error.Fingerprint, error.Timestamp)); err != nil {
+		log.Printf("Failed to count user for issue %s: %v", *error.Fingerprint, err)
+	}
+}
+
+// StartUserRollup periodically copies changed HyperLogLogs from Redis to
+// Postgres, so affected-user counts survive Redis restarts and evictions.
+func (s *ErrorService) StartUserRollup(ctx context.Context) {
+	ticker := time.NewTicker(userRollupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.rollupUsers(ctx)
+		}
+	}
+}
+
+func (s *ErrorService) rollupUsers(ctx context.Context) {
+	for {
+		buckets, err := s.redis.PopDirtyUserBuckets(ctx, userRollupBatch)
+		if err != nil {
+			log.Printf("Failed to roll up affected users: %v", err)
+			return
+		}
+		if len(buckets) == 0 {
+			return
+		}
+
+		if err := s.rollupUserBuckets(ctx, buckets); err != nil {
+			log.Printf("Failed to roll up affected users: %v", err)
+			if err := s.redis.MarkUserBucketsDirty(ctx, buckets); err != nil {
+				log.Printf("Failed to requeue user buckets: %v", err)
+			}
+			return
+		}
+		if len(buckets) < userRollupBatch {
+			return
+		}
+	}
+}
+
+func (s *ErrorService) rollupUserBuckets(ctx context.Context, buckets []models.UserBucket) error {
+	stored, err := s.db.GetUserRollups(buckets)
+	if err != nil {
+		return err
+	}
+	storedHLL := make(map[string][]byte, len(stored))
+	for _, r := range stored {
+		storedHLL[userBucketID(r.Bucket)] = r.HLL
+	}
+
+	rollups := make([]models.UserRollup, 0, len(buckets))
+	for _, b := range buckets {
+		r, err := s.redis.MergeUserRollup(ctx, b, storedHLL[userBucketID(b)])
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			rollups = append(rollups, *r)
+		}
+	}
+	if err := s.db.SaveUserRollups(rollups); err != nil {
+		return err
+	}
+	log.Printf("Rolled up %d affected-user counts", len(rollups))
+	return nil
+}
+
+func userBucketID(b models.UserBucket) string {
+	return fmt.Sprintf("%s:%s:%d", b.Fingerprint, b.Granularity, b.Start.Unix())
+}
+
+// countUsers estimates the unique users in each group of buckets, first
+// restoring buckets Redis no longer holds from their rollups.
+func (s *ErrorService) countUsers(ctx context.Context, groups [][]models.UserBucket) ([]int64, error) {
+	var all []models.UserBucket
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+
+	missing, err := s.redis.MissingUserBuckets(ctx, all)
+	if err != nil {
+		return nil, err
+	}
+	if len(missing) > 0 {
+		rollups, err := s.db.GetUserRollups(missing)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.redis.RestoreUserRollups(ctx, rollups); err != nil {
+			return nil, fmt.Errorf("failed to restore user rollups: %w", err)
+		}
+	}
+	return s.redis.CountUsers(ctx, groups)
+}
+
+// fillAffectedUsers sets the affected-user count of each listed error's
+// issue. Counts are best effort; a failure leaves them unset.
+func (s *ErrorService) fillAffectedUsers(ctx context.Context, errors []models.Error) {
+	var groups [][]models.UserBucket
+	index := make(map[string]int)
+	for _, e := range errors {
+		if e.Fingerprint == nil {
+			continue
+		}
+		if _, ok := index[*e.Fingerprint]; !ok {
+			index[*e.Fingerprint] = len(groups)
+			groups = append(groups, []models.UserBucket{allTimeUserBucket(*e.Fingerprint)})
+		}
+	}
+	if len(groups) == 0 {
+		return
+	}
+
+	counts, err := s.countUsers(ctx, groups)
+	if err != nil {
+		log.Printf("Failed to count affected users: %v", err)
+		return
+	}
+	for i := range errors {
+		if errors[i].Fingerprint != nil {
+			count := counts[index[*errors[i].Fingerprint]]
+			errors[i].AffectedUsers = &count
+		}
+	}
+}
+
+// GetIssueUsers counts the unique users affected by an error's issue, overall
+// and per hour, day, week or month of the period. Unknown periods or
+// groupings are rejected with an error prefixed "invalid users".
+func (s *ErrorService) GetIssueUsers(ctx context.Context, id uuid.UUID, period, groupBy string) (*models.IssueUsersResponse, error) {
+	window, ok := analyticsPeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("invalid users: unknown period %q", period)
+	}
+	if !userGroupings[groupBy] {
+		return nil, fmt.Errorf("invalid users: unknown group_by %q", groupBy)
+	}
+	if groupBy == "hour" && window > maxHourlyUserRange {
+		return nil, fmt.Errorf("invalid users: group_by hour needs a period of a week or less")
+	}
+
+	error, err := s.db.GetErrorByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if error.Fingerprint == nil {
+		return nil, fmt.Errorf("error not found")
+	}
+	fingerprint := *error.Fingerprint
+
+	now := time.Now().UTC()
+	since := now.Add(-window)
+	groups := [][]models.UserBucket{{allTimeUserBucket(fingerprint)}}
+	var starts []time.Time
+	for start := userGroupStart(since, groupBy); !start.After(now); start = nextUserGroup(start, groupBy) {
+		starts = append(starts, start)
+		groups = append(groups, userGroupBuckets(fingerprint, start, nextUserGroup(start, groupBy), since, now, groupBy))
+	}
+
+	counts, err := s.countUsers(ctx, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]models.UserCountPoint, len(starts))
+	for i, start := range starts {
+		points[i] = models.UserCountPoint{Timestamp: start, Users: counts[i+1]}
+	}
+	return &models.IssueUsersResponse{
+		Fingerprint: fingerprint,
+		Users:       counts[0],
+		Period:      period,
+		GroupBy:     groupBy,
+		DataPoints:  points,
+	}, nil
+}
+
+// userGroupStart truncates t like Postgres date_trunc, with weeks starting
+// on Monday.
+func userGroupStart(t time.Time, groupBy string) time.Time {
+	day := t.UTC().Truncate(24 * time.Hour)
+	switch groupBy {
+	case "hour":
+		return t.UTC().Truncate(time.Hour)
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case "month":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+func nextUserGroup(start time.Time, groupBy string) time.Time {
+	switch groupBy {
+	case "hour":
+		return start.Add(time.Hour)
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// userGroupBuckets lists the buckets whose union is a group: its hourly
+// bucket, or the daily buckets within both the group and the period.
+func userGroupBuckets(fingerprint string, start, end, since, now time.Time, groupBy string) []models.UserBucket {
+	if groupBy == "hour" {
+		return []models.UserBucket{{Fingerprint: fingerprint, Granularity: models.UserBucketHour, Start: start}}
+	}
+
+	var buckets []models.UserBucket
+	day := start
+	if first := since.Truncate(24 * time.Hour); day.Before(first) {
+		day = first
+	}
+	for ; day.Before(end) && !day.After(now); day = day.AddDate(0, 0, 1) {
+		buckets = append(buckets, models.UserBucket{Fingerprint: fingerprint, Granularity: models.UserBucketDay, Start: day})
+	}
+	return buckets
+}
@@ -0,0 +1,202 @@
+// Package sourcemap decodes version 3 source maps
+// (https://tc39.es/source-map/) and maps positions in generated JavaScript
+// back to the original sources. Index maps with "sections" are not supported;
+// bundlers emit regular maps per output file.
+package sourcemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Map is a decoded source map.
+type Map struct {
+	File        string
+	sources     []string
+	sourceLines [][]string // by source, nil when not embedded
+	names       []string
+	lines       [][]segment // by generated line
+}
+
+type segment struct {
+	genColumn int
+	source    int // -1 when the segment maps to no source
+	line      int
+	column    int
+	name      int // -1 when unnamed
+}
+
+// Position is an original source location. Line and Column are 0-based.
+type Position struct {
+	Source string
+	Line   int
+	Column int
+	Name   string
+}
+
+type rawMap struct {
+	Version        int       `json:"version"`
+	File           string    `json:"file"`
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+	Names          []string  `json:"names"`
+	Mappings       string    `json:"mappings"`
+	Sections       []any     `json:"sections"`
+}
+
+func Parse(data []byte) (*Map, error) {
+	// Maps served to browsers may start with an XSSI guard line
+	if strings.HasPrefix(string(data), ")]}") {
+		if i := strings.IndexByte(string(data), '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+
+	var raw rawMap
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid source map: %w", err)
+	}
+	if raw.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version %d", raw.Version)
+	}
+	if raw.Sections != nil {
+		return nil, fmt.Errorf("index source maps with sections are not supported")
+	}
+
+	m := &Map{
+		File:        raw.File,
+		sources:     make([]string, len(raw.Sources)),
+		sourceLines: make([][]string, len(raw.Sources)),
+		names:       raw.Names,
+	}
+	for i, source := range raw.Sources {
+		if raw.SourceRoot != "" && !strings.Contains(source, "://") && !path.IsAbs(source) {
+			source = strings.TrimSuffix(raw.SourceRoot, "/") + "/" + source
+		}
+		m.sources[i] = source
+		if i < len(raw.SourcesContent) && raw.SourcesContent[i] != nil {
+			m.sourceLines[i] = strings.Split(*raw.SourcesContent[i], "\n")
+		}
+	}
+	if err := m.decodeMappings(raw.Mappings); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// decodeMappings decodes the Base64 VLQ "mappings" field. Source, line,
+// column and name fields are relative to the previous segment; the generated
+// column resets on every line.
+func (m *Map) decodeMappings(mappings string) error {
+	var source, line, column, name int
+	for _, lineMappings := range strings.Split(mappings, ";") {
+		var segments []segment
+		genColumn := 0
+		for _, field := range strings.Split(lineMappings, ",") {
+			if field == "" {
+				continue
+			}
+			values, err := decodeVLQ(field)
+			if err != nil {
+				return err
+			}
+
+			genColumn += values[0]
+			seg := segment{genColumn: genColumn, source: -1, name: -1}
+			switch len(values) {
+			case 1:
+			case 4, 5:
+				source += values[1]
+				line += values[2]
+				column += values[3]
+				if source < 0 || source >= len(m.sources) {
+					return fmt.Errorf("invalid source map: source index %d out of range", source)
+				}
+				seg.source, seg.line, seg.column = source, line, column
+				if len(values) == 5 {
+					name += values[4]
+					if name >= 0 && name < len(m.names) {
+						seg.name = name
+					}
+				}
+			default:
+				return fmt.Errorf("invalid source map: segment with %d fields", len(values))
+			}
+			segments = append(segments, seg)
+		}
+		sort.SliceStable(segments, func(i, j int) bool { return segments[i].genColumn < segments[j].genColumn })
+		m.lines = append(m.lines, segments)
+	}
+	return nil
+}
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+func decodeVLQ(field string) ([]int, error) {
+	var values []int
+	value, shift := 0, 0
+	for i := 0; i < len(field); i++ {
+		digit := strings.IndexByte(base64Chars, field[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid source map: bad character %q in mappings", field[i])
+		}
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			if shift > 30 {
+				return nil, fmt.Errorf("invalid source map: VLQ value too large")
+			}
+			continue
+		}
+		if value&1 != 0 {
+			values = append(values, -(value >> 1))
+		} else {
+			values = append(values, value>>1)
+		}
+		value, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, fmt.Errorf("invalid source map: truncated VLQ value")
+	}
+	return values, nil
+}
+
+// Lookup returns the original position of a 0-based generated line and
+// column: that of the closest segment at or before the column.
+func (m *Map) Lookup(line, column int) (Position, bool) {
+	if line < 0 || line >= len(m.lines) {
+		return Position{}, false
+	}
+	segments := m.lines[line]
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].genColumn > column }) - 1
+	if i < 0 || segments[i].source < 0 {
+		return Position{}, false
+	}
+
+	seg := segments[i]
+	pos := Position{Source: m.sources[seg.source], Line: seg.line, Column: seg.column}
+	if seg.name >= 0 {
+		pos.Name = m.names[seg.name]
+	}
+	return pos, true
+}
+
+// SourceLine returns a 0-based line of an original source when the map
+// embeds its content.
+func (m *Map) SourceLine(source string, line int) (string, bool) {
+	for i, s := range m.sources {
+		if s != source {
+			continue
+		}
+		lines := m.sourceLines[i]
+		if line < 0 || line >= len(lines) {
+			return "", false
+		}
+		return strings.TrimRight(lines[line], "\r"), true
+	}
+	return "", false
+}
@@ -0,0 +1,130 @@
+package sourcemap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeVLQ(t *testing.T) {
+	tests := []struct {
+		field   string
+		want    []int
+		wantErr string
+	}{
+		{field: "A", want: []int{0}},
+		{field: "C", want: []int{1}},
+		{field: "D", want: []int{-1}},
+		{field: "gB", want: []int{16}},
+		{field: "jB", want: []int{-17}},
+		{field: "2H", want: []int{123}},
+		{field: "AACA", want: []int{0, 0, 1, 0}},
+		{field: "A!", wantErr: "bad character"},
+		{field: "Ag", wantErr: "truncated"},
+		{field: "gggggggB", wantErr: "too large"},
+	}
+	for _, tt := range tests {
+		got, err := decodeVLQ(tt.field)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("decodeVLQ(%q) error = %v, want %q", tt.field, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("decodeVLQ(%q) = %v, %v, want %v", tt.field, got, err, tt.want)
+		}
+	}
+}
+
+// testMap has two sources and maps:
+//
+//	line 0: col 0 -> a.js 0:0, col 2 -> a.js 0:2 "foo", col 6 -> a.js 0:6
+//	line 1: col 0 -> b.js 1:6
+//	line 2: col 0 unmapped, col 2 -> b.js 1:6
+const testMap = `)]}'
+{
+	"version": 3,
+	"file": "app.min.js",
+	"sourceRoot": "src/",
+	"sources": ["a.js", "webpack://app/b.js"],
+	"sourcesContent": ["const a = 1;\r\nfoo()", null],
+	"names": ["foo"],
+	"mappings": "AAAA,EAAEA,IAAI;ACCA;A,EAAA"
+}`
+
+func TestLookup(t *testing.T) {
+	m, err := Parse([]byte(testMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.File != "app.min.js" {
+		t.Errorf("File = %q", m.File)
+	}
+
+	tests := []struct {
+		line, column int
+		want         Position
+		ok           bool
+	}{
+		{0, 0, Position{Source: "src/a.js", Line: 0, Column: 0}, true},
+		{0, 3, Position{Source: "src/a.js", Line: 0, Column: 2, Name: "foo"}, true},
+		{0, 100, Position{Source: "src/a.js", Line: 0, Column: 6}, true},
+		{1, 5, Position{Source: "webpack://app/b.js", Line: 1, Column: 6}, true},
+		{2, 1, Position{}, false},
+		{2, 2, Position{Source: "webpack://app/b.js", Line: 1, Column: 6}, true},
+		{3, 0, Position{}, false},
+		{-1, 0, Position{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := m.Lookup(tt.line, tt.column)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Lookup(%d, %d) = %+v, %v, want %+v, %v", tt.line, tt.column, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if line, ok := m.SourceLine("src/a.js", 0); !ok || line != "const a = 1;" {
+		t.Errorf("SourceLine = %q, %v", line, ok)
+	}
+	if _, ok := m.SourceLine("webpack://app/b.js", 0); ok {
+		t.Error("SourceLine of a source without content must fail")
+	}
+}
+
+func TestLookupUnsortedSegments(t *testing.T) {
+	// Segments are relative to the previous one, so a negative delta lists
+	// a column before the one it follows.
+	m, err := Parse([]byte(`{"version":3,"sources":["a.js"],"names":[],"mappings":"KAAK,FAAC"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos, ok := m.Lookup(0, 3); !ok || pos.Column != 6 {
+		t.Errorf("Lookup(0, 3) = %+v, %v, want column 6", pos, ok)
+	}
+	if pos, ok := m.Lookup(0, 5); !ok || pos.Column != 5 {
+		t.Errorf("Lookup(0, 5) = %+v, %v, want column 5", pos, ok)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"not json", `{`, "invalid source map"},
+		{"version", `{"version":2,"mappings":""}`, "unsupported source map version 2"},
+		{"sections", `{"version":3,"sections":[]}`, "not supported"},
+		{"source out of range", `{"version":3,"sources":["a.js"],"mappings":"ACAA"}`, "out of range"},
+		{"field count", `{"version":3,"sources":["a.js"],"mappings":"AA"}`, "segment with 2 fields"},
+		{"bad character", `{"version":3,"sources":["a.js"],"mappings":"AA.A"}`, "bad character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
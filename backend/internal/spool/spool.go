@@ -0,0 +1,300 @@
+// Package spool is an on-disk queue of event batches waiting to be sent to
+// an error-logs server. It is used by the log-tailing agent and relay mode.
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"error-logs/internal/models"
+	"error-logs/pkg/client"
+)
+
+// sendBatchSize caps how many spooled events are combined into one upstream
+// request, matching the server's batch limit.
+const sendBatchSize = 100
+
+// Spool writes each batch as one JSON file and removes it once the server
+// has accepted it.
+type Spool struct {
+	dir      string
+	maxBytes int64
+	notify   chan struct{}
+	mu       sync.Mutex
+	seq      int
+}
+
+func New(dir string, maxMB int) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	return &Spool{
+		dir:      dir,
+		maxBytes: int64(maxMB) * 1024 * 1024,
+		notify:   make(chan struct{}, 1),
+	}, nil
+}
+
+// Write durably stores a batch; once it returns nil the events survive a crash.
+func (s *Spool) Write(batch []*models.CreateErrorRequest) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	s.mu.Lock()
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), s.seq%1000000)
+	s.mu.Unlock()
+
+	if err := writeFile(filepath.Join(s.dir, name), data); err != nil {
+		return err
+	}
+
+	s.enforceLimit()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// writeFile replaces path atomically, so a crash leaves either the old
+// contents or the new ones.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync spool file: %w", err)
+	}
+	f.Close()
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of batches waiting to be sent.
+func (s *Spool) Len() int {
+	return len(s.files())
+}
+
+// files returns spooled batches oldest first.
+func (s *Spool) files() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Printf("Failed to list spool: %v", err)
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// enforceLimit drops the oldest batches once the spool exceeds its size cap,
+// so a long outage can't fill the disk.
+func (s *Spool) enforceLimit() {
+	if s.maxBytes <= 0 {
+		return
+	}
+	names := s.files()
+	sizes := make([]int64, len(names))
+	var total int64
+	for i, name := range names {
+		if fi, err := os.Stat(filepath.Join(s.dir, name)); err == nil {
+			sizes[i] = fi.Size()
+			total += fi.Size()
+		}
+	}
+	for i := 0; total > s.maxBytes && i < len(names); i++ {
+		log.Printf("Spool over %d bytes, dropping oldest batch %s", s.maxBytes, names[i])
+		os.Remove(filepath.Join(s.dir, names[i]))
+		total -= sizes[i]
+	}
+}
+
+// Run ships spooled batches until ctx is cancelled. Small files are combined
+// so that many single-event writes still go upstream as a few requests.
+func (s *Spool) Run(ctx context.Context, c *client.Client) {
+	for {
+		names := s.files()
+		for len(names) > 0 && ctx.Err() == nil {
+			sent, err := s.sendNext(ctx, c, names)
+			if err != nil {
+				log.Printf("Failed to send spooled events: %v", err)
+				break
+			}
+			names = names[sent:]
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.notify:
+		case <-time.After(30 * time.Second):
+		}
+	}
+}
+
+// sendNext sends the oldest files up to sendBatchSize events and returns how
+// many files were consumed. When only some events are delivered, the files
+// are rewritten to hold the rest, so nothing is sent twice.
+func (s *Spool) sendNext(ctx context.Context, c *client.Client, names []string) (int, error) {
+	var batch []*models.CreateErrorRequest
+	var paths []string
+
+	for _, name := range names {
+		path := filepath.Join(s.dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				paths = append(paths, path)
+				continue
+			}
+			return 0, err
+		}
+
+		var events []*models.CreateErrorRequest
+		if err := json.Unmarshal(data, &events); err != nil {
+			log.Printf("Discarding corrupt spool file %s: %v", name, err)
+			os.Remove(path)
+			paths = append(paths, path)
+			continue
+		}
+
+		if len(batch) > 0 && len(batch)+len(events) > sendBatchSize {
+			break
+		}
+		batch = append(batch, events...)
+		paths = append(paths, path)
+	}
+
+	if len(batch) > 0 {
+		events := make([]client.Event, len(batch))
+		for i, req := range batch {
+			events[i] = toEvent(req)
+		}
+		err := c.Send(ctx, events)
+		if err != nil {
+			var sendErr *client.SendError
+			if !errors.As(err, &sendErr) {
+				return 0, err
+			}
+			keep := sendErr.Failed
+			if rejected(sendErr.Err) {
+				// The server will never accept these events; retrying would block
+				// the spool. Events after them were never tried and are kept.
+				n := len(keep) - sendErr.Unsent
+				log.Printf("Discarding %d spooled events rejected by server: %v", n, sendErr.Err)
+				keep = keep[n:]
+				err = nil
+			}
+
+			remaining := make([]*models.CreateErrorRequest, len(keep))
+			for i, index := range keep {
+				remaining[i] = batch[index]
+			}
+			if len(remaining) > 0 {
+				if rerr := s.replace(paths, remaining); rerr != nil {
+					return 0, rerr
+				}
+				return 0, err
+			}
+		} else {
+			log.Printf("Sent %d spooled events from %d files", len(batch), len(paths))
+		}
+	}
+
+	for _, path := range paths {
+		os.Remove(path)
+	}
+	return len(paths), nil
+}
+
+// replace swaps the files a batch was read from for one holding only the
+// given events. It reuses the oldest name so they keep their place in line.
+func (s *Spool) replace(paths []string, events []*models.CreateErrorRequest) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+	if err := writeFile(paths[0], data); err != nil {
+		return err
+	}
+	for _, path := range paths[1:] {
+		os.Remove(path)
+	}
+	return nil
+}
+
+// rejected reports whether the server refused events in a way resending
+// won't fix. A bad API key is left for the operator to correct.
+func rejected(err error) bool {
+	var statusErr *client.StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+		statusErr.StatusCode != http.StatusTooManyRequests && statusErr.StatusCode != http.StatusUnauthorized
+}
+
+// toEvent converts a spooled request for the client. Spool files keep the
+// API's format, so they stay readable across client versions.
+func toEvent(req *models.CreateErrorRequest) client.Event {
+	event := client.Event{
+		Level:       req.Level,
+		Message:     req.Message,
+		StackTrace:  deref(req.StackTrace),
+		Context:     req.Context,
+		Source:      req.Source,
+		Environment: deref(req.Environment),
+		URL:         deref(req.URL),
+		Release:     deref(req.Release),
+		Tags:        req.Tags,
+		TraceID:     deref(req.TraceID),
+		SpanID:      deref(req.SpanID),
+		RequestID:   deref(req.RequestID),
+		ClientIP:    deref(req.ClientIP),
+	}
+	for _, b := range req.Breadcrumbs {
+		breadcrumb := client.Breadcrumb{Category: b.Category, Level: b.Level, Message: b.Message, Data: b.Data}
+		if b.Timestamp != nil {
+			breadcrumb.Timestamp = *b.Timestamp
+		}
+		event.Breadcrumbs = append(event.Breadcrumbs, breadcrumb)
+	}
+	if req.User != nil {
+		event.User = &client.User{ID: req.User.ID, Email: req.User.Email, Username: req.User.Username, IPAddress: req.User.IPAddress}
+	}
+	if req.Timestamp != nil {
+		event.Timestamp = *req.Timestamp
+	}
+	return event
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,129 @@
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"error-logs/internal/models"
+	"error-logs/pkg/client"
+)
+
+// recorder stores the messages of every batch it receives and answers with
+// the status returned by respond.
+type recorder struct {
+	mu       sync.Mutex
+	received [][]string
+	respond  func(messages []string) int
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var events []map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&events)
+	messages := make([]string, len(events))
+	for i, e := range events {
+		messages[i], _ = e["message"].(string)
+	}
+	rec.mu.Lock()
+	rec.received = append(rec.received, messages)
+	rec.mu.Unlock()
+	w.WriteHeader(rec.respond(messages))
+}
+
+func newTestSpool(t *testing.T, rec *recorder, batches ...[]string) (*Spool, *client.Client) {
+	t.Helper()
+	server := httptest.NewServer(rec)
+	t.Cleanup(server.Close)
+
+	c, err := client.New(client.Options{
+		Endpoint:    server.URL,
+		APIKey:      "test",
+		BatchSize:   2,
+		MaxRetries:  -1,
+		Passthrough: true,
+		OnError:     func(error, int) {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close(context.Background()) })
+
+	s, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, messages := range batches {
+		batch := make([]*models.CreateErrorRequest, len(messages))
+		for i, m := range messages {
+			batch[i] = &models.CreateErrorRequest{Level: "error", Message: m}
+		}
+		if err := s.Write(batch); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond) // keep file names in write order
+	}
+	return s, c
+}
+
+// A batch that fails after earlier ones were stored must only resend the
+// events the server doesn't have.
+func TestSendNextKeepsUndelivered(t *testing.T) {
+	down := true
+	rec := &recorder{respond: func(messages []string) int {
+		if messages[0] == "c" && down {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusCreated
+	}}
+	s, c := newTestSpool(t, rec, []string{"a", "b", "c"}, []string{"d", "e"})
+
+	if _, err := s.sendNext(context.Background(), c, s.files()); err == nil {
+		t.Fatal("sendNext succeeded while the server was down")
+	}
+	if n := s.Len(); n != 1 {
+		t.Errorf("Len = %d, want the undelivered events in 1 file", n)
+	}
+
+	down = false
+	names := s.files()
+	sent, err := s.sendNext(context.Background(), c, names)
+	if err != nil || sent != len(names) {
+		t.Fatalf("sendNext = %d, %v", sent, err)
+	}
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"c", "d"}, {"e"}}
+	if !reflect.DeepEqual(rec.received, want) {
+		t.Errorf("received %v, want %v", rec.received, want)
+	}
+	if n := s.Len(); n != 0 {
+		t.Errorf("Len = %d after delivery", n)
+	}
+}
+
+// Events the server rejects are dropped, but those after them are still sent.
+func TestSendNextDiscardsRejected(t *testing.T) {
+	rec := &recorder{respond: func(messages []string) int {
+		if messages[0] == "a" {
+			return http.StatusBadRequest
+		}
+		return http.StatusCreated
+	}}
+	s, c := newTestSpool(t, rec, []string{"a", "b", "c"})
+
+	for i := 0; s.Len() > 0; i++ {
+		if i == 2 {
+			t.Fatalf("spool not empty after %d sends", i)
+		}
+		if _, err := s.sendNext(context.Background(), c, s.files()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := [][]string{{"a", "b"}, {"c"}}
+	if !reflect.DeepEqual(rec.received, want) {
+		t.Errorf("received %v, want %v", rec.received, want)
+	}
+}
@@ -0,0 +1,357 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+// errorColumns are the columns of the errors table in the order scanError
+// reads them and insertError writes them.
+const errorColumns = `id, timestamp, level, message, stack_trace, context, source,
+	environment, user_agent, ip_address, url, fingerprint, resolved,
+	count, first_seen, last_seen, created_at, updated_at, project_id,
+	geo_country, geo_region, geo_city, geo_asn, geo_as_org,
+	browser_name, browser_version, os_name, os_version, device_type, is_bot,
+	release, trace_id, span_id, request_id, user_info`
+
+// scanError reads errorColumns into e, followed by any extra columns.
+func scanError(row scanner, e *models.Error, extra ...interface{}) error {
+	var contextJSON, userJSON []byte
+	dest := []interface{}{
+		&e.ID, &e.Timestamp, &e.Level, &e.Message, &e.StackTrace,
+		&contextJSON, &e.Source, &e.Environment, &e.UserAgent,
+		&e.IPAddress, &e.URL, &e.Fingerprint, &e.Resolved,
+		&e.Count, &e.FirstSeen, &e.LastSeen, &e.CreatedAt, &e.UpdatedAt,
+		&e.ProjectID,
+		&e.Country, &e.Region, &e.City, &e.ASN, &e.ASOrg,
+		&e.BrowserName, &e.BrowserVersion, &e.OSName, &e.OSVersion, &e.DeviceType, &e.IsBot,
+		&e.Release, &e.TraceID, &e.SpanID, &e.RequestID, &userJSON,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(contextJSON, &e.Context); err != nil {
+		e.Context = make(map[string]interface{})
+	}
+	if len(userJSON) > 0 {
+		if err := json.Unmarshal(userJSON, &e.User); err != nil {
+			return fmt.Errorf("failed to unmarshal user: %w", err)
+		}
+	}
+	return nil
+}
+
+// scanErrorDetail reads errorColumns followed by breadcrumbs and frames.
+func scanErrorDetail(row scanner, e *models.Error) error {
+	var breadcrumbsJSON, framesJSON []byte
+	if err := scanError(row, e, &breadcrumbsJSON, &framesJSON); err != nil {
+		return err
+	}
+
+	if len(breadcrumbsJSON) > 0 {
+		if err := json.Unmarshal(breadcrumbsJSON, &e.Breadcrumbs); err != nil {
+			return fmt.Errorf("failed to unmarshal breadcrumbs: %w", err)
+		}
+	}
+	if len(framesJSON) > 0 {
+		if err := json.Unmarshal(framesJSON, &e.Frames); err != nil {
+			return fmt.Errorf("failed to unmarshal frames: %w", err)
+		}
+	}
+	return nil
+}
+
+func (db *DB) CreateError(error *models.Error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := insertError(tx, error, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertError writes an event and its tags. With skipExisting an event
+// already stored under the same ID is left alone and false is returned.
+func insertError(tx *Tx, error *models.Error, skipExisting bool) (bool, error) {
+	query := `
+		INSERT INTO errors (` + errorColumns + `, breadcrumbs, frames) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
+			$20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37
+		)`
+	if skipExisting {
+		query += " ON CONFLICT DO NOTHING"
+	}
+
+	contextJSON, err := json.Marshal(error.Context)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal context: %w", err)
+	}
+
+	var userJSON []byte
+	if error.User != nil {
+		if userJSON, err = json.Marshal(error.User); err != nil {
+			return false, fmt.Errorf("failed to marshal user: %w", err)
+		}
+	}
+
+	var breadcrumbsJSON []byte
+	if len(error.Breadcrumbs) > 0 {
+		if breadcrumbsJSON, err = json.Marshal(error.Breadcrumbs); err != nil {
+			return false, fmt.Errorf("failed to marshal breadcrumbs: %w", err)
+		}
+	}
+
+	var framesJSON []byte
+	if len(error.Frames) > 0 {
+		if framesJSON, err = json.Marshal(error.Frames); err != nil {
+			return false, fmt.Errorf("failed to marshal frames: %w", err)
+		}
+	}
+
+	result, err := tx.Exec(query,
+		error.ID, error.Timestamp, error.Level, error.Message, error.StackTrace,
+		jsonText(contextJSON), error.Source, error.Environment, error.UserAgent,
+		error.IPAddress, error.URL, error.Fingerprint, error.Resolved,
+		error.Count, error.FirstSeen, error.LastSeen, error.CreatedAt, error.UpdatedAt,
+		error.ProjectID,
+		error.Country, error.Region, error.City, error.ASN, error.ASOrg,
+		error.BrowserName, error.BrowserVersion, error.OSName, error.OSVersion, error.DeviceType, error.IsBot,
+		error.Release, error.TraceID, error.SpanID, error.RequestID,
+		jsonText(userJSON), jsonText(breadcrumbsJSON), jsonText(framesJSON),
+	)
+	if err != nil {
+		return false, err
+	}
+	if skipExisting {
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return false, err
+		}
+	}
+
+	for key, value := range error.Tags {
+		_, err := tx.Exec("INSERT INTO error_tags (error_id, key, value, timestamp) VALUES ($1, $2, $3, $4)",
+			error.ID, key, value, error.Timestamp)
+		if err != nil {
+			return false, fmt.Errorf("failed to insert tag %q: %w", key, err)
+		}
+	}
+	return true, nil
+}
+
+func (db *DB) GetErrors(limit, offset int, filter models.ErrorFilter) ([]models.Error, int, error) {
+	whereClause, args := filterClause(filter)
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM errors "+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to get total count: %w", err)
+	}
+
+	args = append(args, limit, offset)
+	query := fmt.Sprintf("SELECT %s FROM errors %s ORDER BY timestamp DESC LIMIT $%d OFFSET $%d",
+		errorColumns, whereClause, len(args)-1, len(args))
+	errors, err := db.listErrors(query, false, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return errors, total, nil
+}
+
+// listErrors runs a query selecting errorColumns, followed by breadcrumbs
+// and frames when detail is set, and loads the tags of the results.
+func (db *DB) listErrors(query string, detail bool, args ...interface{}) ([]models.Error, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query errors: %w", err)
+	}
+	defer rows.Close()
+
+	var errors []models.Error
+	for rows.Next() {
+		var e models.Error
+		if detail {
+			err = scanErrorDetail(rows, &e)
+		} else {
+			err = scanError(rows, &e)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan error: %w", err)
+		}
+		errors = append(errors, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read errors: %w", err)
+	}
+	rows.Close()
+
+	if err := db.loadTags(errors); err != nil {
+		return nil, err
+	}
+	return errors, nil
+}
+
+// filterClause builds the WHERE clause and arguments for an error filter.
+func filterClause(filter models.ErrorFilter) (string, []interface{}) {
+	whereClause := "WHERE 1=1"
+	args := []interface{}{}
+
+	add := func(column string, value interface{}) {
+		args = append(args, value)
+		whereClause += fmt.Sprintf(" AND %s = $%d", column, len(args))
+	}
+
+	if filter.Level != "" {
+		add("level", filter.Level)
+	}
+	if filter.Source != "" {
+		add("source", filter.Source)
+	}
+	if filter.Country != "" {
+		add("geo_country", filter.Country)
+	}
+	if filter.Region != "" {
+		add("geo_region", filter.Region)
+	}
+	if filter.City != "" {
+		add("geo_city", filter.City)
+	}
+	if filter.ASN != 0 {
+		add("geo_asn", filter.ASN)
+	}
+	if filter.Browser != "" {
+		add("browser_name", filter.Browser)
+	}
+	if filter.BrowserVersion != "" {
+		// "17" matches 17, 17.4 and 17.4.1
+		args = append(args, filter.BrowserVersion)
+		whereClause += fmt.Sprintf(" AND (browser_version = $%[1]d OR browser_version LIKE $%[1]d || '.%%')", len(args))
+	}
+	if filter.OS != "" {
+		add("os_name", filter.OS)
+	}
+	if filter.Device != "" {
+		add("device_type", filter.Device)
+	}
+	if filter.Bot != nil {
+		add("is_bot", *filter.Bot)
+	}
+	if filter.Release != "" {
+		add("release", filter.Release)
+	}
+	if filter.TraceID != "" {
+		add("trace_id", filter.TraceID)
+	}
+	if filter.RequestID != "" {
+		add("request_id", filter.RequestID)
+	}
+	keys := make([]string, 0, len(filter.Tags))
+	for key := range filter.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, key, filter.Tags[key])
+		whereClause += fmt.Sprintf(
+			" AND EXISTS (SELECT 1 FROM error_tags t WHERE t.error_id = errors.id AND t.key = $%d AND t.value = $%d)",
+			len(args)-1, len(args))
+	}
+
+	return whereClause, args
+}
+
+// loadTags fills in the tags of a page of errors with a single query.
+func (db *DB) loadTags(errors []models.Error) error {
+	if len(errors) == 0 {
+		return nil
+	}
+
+	index := make(map[uuid.UUID]int, len(errors))
+	ids := make([]string, len(errors))
+	for i, e := range errors {
+		index[e.ID] = i
+		ids[i] = e.ID.String()
+	}
+	idsJSON, _ := json.Marshal(ids)
+
+	rows, err := db.Query("SELECT error_id, key, value FROM error_tags WHERE error_id IN (SELECT value FROM json_each($1))", string(idsJSON))
+	if err != nil {
+		return fmt.Errorf("failed to query tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var key, value string
+		if err := rows.Scan(&id, &key, &value); err != nil {
+			return fmt.Errorf("failed to scan tag: %w", err)
+		}
+		e := &errors[index[id]]
+		if e.Tags == nil {
+			e.Tags = make(map[string]string)
+		}
+		e.Tags[key] = value
+	}
+	return rows.Err()
+}
+
+func (db *DB) GetErrorByID(id uuid.UUID) (*models.Error, error) {
+	var e models.Error
+	err := scanErrorDetail(db.QueryRow("SELECT "+errorColumns+", breadcrumbs, frames FROM errors WHERE id = $1", id), &e)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("error not found")
+		}
+		return nil, fmt.Errorf("failed to get error: %w", err)
+	}
+
+	errors := []models.Error{e}
+	if err := db.loadTags(errors); err != nil {
+		return nil, err
+	}
+	return &errors[0], nil
+}
+
+// GetTraceErrors returns the errors reported for a trace, oldest first.
+func (db *DB) GetTraceErrors(traceID string, limit int) ([]models.Error, error) {
+	errors, err := db.listErrors("SELECT "+errorColumns+" FROM errors WHERE trace_id = $1 ORDER BY timestamp LIMIT $2", false, traceID, limit)
+	if errors == nil && err == nil {
+		errors = []models.Error{}
+	}
+	return errors, err
+}
+
+func (db *DB) ResolveError(id uuid.UUID) error {
+	_, err := db.Exec("UPDATE errors SET resolved = true, updated_at = $2 WHERE id = $1", id, time.Now())
+	return err
+}
+
+// DeleteError deletes an event; its tags go with it by ON DELETE CASCADE.
+func (db *DB) DeleteError(id uuid.UUID) error {
+	_, err := db.Exec("DELETE FROM errors WHERE id = $1", id)
+	return err
+}
+
+// ClearIPAddresses removes stored client IPs from a project's errors that
+// occurred before the given time.
+func (db *DB) ClearIPAddresses(projectID uuid.UUID, before time.Time) (int64, error) {
+	result, err := db.Exec(`
+		UPDATE errors SET ip_address = NULL, user_info = json_remove(user_info, '$.ip_address')
+		WHERE project_id = $1 AND timestamp < $2
+			AND (ip_address IS NOT NULL OR json_extract(user_info, '$.ip_address') IS NOT NULL)
+	`, projectID, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear IP addresses: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,304 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+// projectKey is the project_id of a project's releases and issue states.
+// Events without a project use the nil UUID, as key columns can't be NULL.
+func projectKey(projectID *uuid.UUID) uuid.UUID {
+	if projectID == nil {
+		return uuid.Nil
+	}
+	return *projectID
+}
+
+// RecordRelease notes that an issue was seen in a release at the given time,
+// keeping first/last seen both for the release and for the issue within it.
+func (db *DB) RecordRelease(projectID *uuid.UUID, fingerprint, release string, seenAt time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO releases (project_id, version, first_seen, last_seen) VALUES ($1, $2, $3, $3)
+		ON CONFLICT (project_id, version) DO UPDATE SET
+			first_seen = min(releases.first_seen, excluded.first_seen),
+			last_seen = max(releases.last_seen, excluded.last_seen)
+	`, projectKey(projectID), release, seenAt)
+	if err != nil {
+		return fmt.Errorf("failed to record release: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO issue_releases (project_id, fingerprint, release, first_seen, last_seen, count) VALUES ($1, $2, $3, $4, $4, 1)
+		ON CONFLICT (project_id, fingerprint, release) DO UPDATE SET
+			first_seen = min(issue_releases.first_seen, excluded.first_seen),
+			last_seen = max(issue_releases.last_seen, excluded.last_seen),
+			count = issue_releases.count + 1
+	`, projectKey(projectID), fingerprint, release, seenAt)
+	if err != nil {
+		return fmt.Errorf("failed to record issue release: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// IsNewerRelease reports whether release a first appeared in a project after
+// release b. A release that hasn't been recorded yet is newer than any known
+// one.
+func (db *DB) IsNewerRelease(projectID *uuid.UUID, a, b string) (bool, error) {
+	aSeen, err := db.releaseFirstSeen(projectID, a)
+	if err != nil {
+		return false, err
+	}
+	bSeen, err := db.releaseFirstSeen(projectID, b)
+	if err != nil {
+		return false, err
+	}
+	return aSeen == nil || bSeen == nil || aSeen.After(*bSeen), nil
+}
+
+func (db *DB) releaseFirstSeen(projectID *uuid.UUID, version string) (*time.Time, error) {
+	var firstSeen time.Time
+	err := db.QueryRow(`
+		SELECT first_seen FROM releases WHERE project_id = $1 AND version = $2
+	`, projectKey(projectID), version).Scan(&firstSeen)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to compare releases: %w", err)
+	}
+	return &firstSeen, nil
+}
+
+// LatestRelease returns a project's most recently first-seen release,
+// optionally limited to those an issue was seen in. It returns "" if there is
+// none.
+func (db *DB) LatestRelease(projectID *uuid.UUID, fingerprint string) (string, error) {
+	var release string
+	var err error
+	if fingerprint == "" {
+		err = db.QueryRow(`
+			SELECT version FROM releases WHERE project_id = $1 ORDER BY first_seen DESC LIMIT 1
+		`, projectKey(projectID)).Scan(&release)
+	} else {
+		err = db.QueryRow(`
+			SELECT r.version FROM issue_releases ir
+			JOIN releases r ON r.project_id = ir.project_id AND r.version = ir.release
+			WHERE ir.project_id = $1 AND ir.fingerprint = $2 ORDER BY r.first_seen DESC LIMIT 1
+		`, projectKey(projectID), fingerprint).Scan(&release)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get latest release: %w", err)
+	}
+	return release, nil
+}
+
+// GetIssueState returns the resolution state of an issue, or nil if it was
+// never resolved.
+func (db *DB) GetIssueState(projectID *uuid.UUID, fingerprint string) (*models.IssueState, error) {
+	var state models.IssueState
+	err := db.QueryRow(`
+		SELECT fingerprint, status, resolved_in, resolved_at, regressed_in, regressed_at
+		FROM issue_states WHERE project_id = $1 AND fingerprint = $2
+	`, projectKey(projectID), fingerprint).Scan(
+		&state.Fingerprint, &state.Status, &state.ResolvedIn, &state.ResolvedAt,
+		&state.RegressedIn, &state.RegressedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get issue state: %w", err)
+	}
+	return &state, nil
+}
+
+// ResolveIssue marks every event of an issue resolved and records how, so
+// later events can be checked for regressions.
+func (db *DB) ResolveIssue(projectID *uuid.UUID, fingerprint, status string, resolvedIn *string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO issue_states (project_id, fingerprint, status, resolved_in, resolved_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (project_id, fingerprint) DO UPDATE SET
+			status = excluded.status, resolved_in = excluded.resolved_in, resolved_at = excluded.resolved_at
+	`, projectKey(projectID), fingerprint, status, resolvedIn, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record resolution: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE errors SET resolved = true WHERE fingerprint = $1 AND project_id IS $2", fingerprint, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to resolve errors: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// RegressIssue reopens a resolved issue that reappeared.
+func (db *DB) RegressIssue(projectID *uuid.UUID, fingerprint string, release *string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE issue_states SET status = $3, regressed_in = $4, regressed_at = $5
+		WHERE project_id = $1 AND fingerprint = $2
+	`, projectKey(projectID), fingerprint, models.IssueStatusRegressed, release, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record regression: %w", err)
+	}
+
+	_, err = tx.Exec("UPDATE errors SET resolved = false WHERE fingerprint = $1 AND project_id IS $2", fingerprint, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to reopen errors: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetIssueReleases lists the releases an issue was seen in, newest first.
+func (db *DB) GetIssueReleases(projectID *uuid.UUID, fingerprint string) ([]models.IssueRelease, error) {
+	rows, err := db.Query(`
+		SELECT ir.release, ir.first_seen, ir.last_seen, ir.count
+		FROM issue_releases ir JOIN releases r ON r.project_id = ir.project_id AND r.version = ir.release
+		WHERE ir.project_id = $1 AND ir.fingerprint = $2
+		ORDER BY r.first_seen DESC
+	`, projectKey(projectID), fingerprint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query issue releases: %w", err)
+	}
+	defer rows.Close()
+
+	releases := []models.IssueRelease{}
+	for rows.Next() {
+		var r models.IssueRelease
+		if err := rows.Scan(&r.Release, &r.FirstSeen, &r.LastSeen, &r.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan issue release: %w", err)
+		}
+		releases = append(releases, r)
+	}
+	return releases, rows.Err()
+}
+
+func (db *DB) CreateFeedback(feedback *models.Feedback) error {
+	_, err := db.Exec(`
+		INSERT INTO user_feedback (id, project_id, event_id, name, email, comments, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, feedback.ID, feedback.ProjectID, feedback.EventID, feedback.Name, feedback.Email,
+		feedback.Comments, feedback.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create feedback: %w", err)
+	}
+	return nil
+}
+
+// GetIssueFeedback returns the newest feedback on any event of an issue.
+func (db *DB) GetIssueFeedback(fingerprint string, limit int) ([]models.Feedback, error) {
+	rows, err := db.Query(`
+		SELECT f.id, f.project_id, f.event_id, f.name, f.email, f.comments, f.created_at
+		FROM user_feedback f JOIN errors e ON e.id = f.event_id
+		WHERE e.fingerprint = $1
+		ORDER BY f.created_at DESC
+		LIMIT $2
+	`, fingerprint, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feedback: %w", err)
+	}
+	defer rows.Close()
+
+	feedback := []models.Feedback{}
+	for rows.Next() {
+		var f models.Feedback
+		if err := rows.Scan(&f.ID, &f.ProjectID, &f.EventID, &f.Name, &f.Email, &f.Comments, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feedback: %w", err)
+		}
+		feedback = append(feedback, f)
+	}
+	return feedback, rows.Err()
+}
+
+// GetUserRollups returns the stored HyperLogLogs of the given buckets.
+// Buckets that were never rolled up are left out.
+func (db *DB) GetUserRollups(buckets []models.UserBucket) ([]models.UserRollup, error) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+
+	type bucketKey struct {
+		Fingerprint string `json:"f"`
+		Granularity string `json:"g"`
+		Start       int64  `json:"s"`
+	}
+	keys := make([]bucketKey, len(buckets))
+	for i, b := range buckets {
+		keys[i] = bucketKey{b.Fingerprint, b.Granularity, b.Start.Unix()}
+	}
+	keysJSON, _ := json.Marshal(keys)
+
+	rows, err := db.Query(`
+		SELECT u.fingerprint, u.granularity, u.bucket_start, u.hll, u.users
+		FROM issue_users u
+		JOIN json_each($1) b
+			ON u.fingerprint = json_extract(b.value, '$.f') AND u.granularity = json_extract(b.value, '$.g')
+			AND u.bucket_start = json_extract(b.value, '$.s')
+	`, string(keysJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user rollups: %w", err)
+	}
+	defer rows.Close()
+
+	var rollups []models.UserRollup
+	for rows.Next() {
+		var r models.UserRollup
+		var start int64
+		if err := rows.Scan(&r.Bucket.Fingerprint, &r.Bucket.Granularity, &start, &r.HLL, &r.Users); err != nil {
+			return nil, fmt.Errorf("failed to scan user rollup: %w", err)
+		}
+		r.Bucket.Start = time.Unix(start, 0).UTC()
+		rollups = append(rollups, r)
+	}
+	return rollups, rows.Err()
+}
+
+// SaveUserRollups stores HyperLogLogs copied from Redis, replacing older
+// copies. Callers merge the old copy into Redis first, so nothing is lost.
+func (db *DB) SaveUserRollups(rollups []models.UserRollup) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, r := range rollups {
+		_, err := tx.Exec(`
+			INSERT INTO issue_users (fingerprint, granularity, bucket_start, hll, users, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (fingerprint, granularity, bucket_start) DO UPDATE SET
+				hll = excluded.hll, users = excluded.users, updated_at = excluded.updated_at
+		`, r.Bucket.Fingerprint, r.Bucket.Granularity, r.Bucket.Start.Unix(), r.HLL, r.Users, now)
+		if err != nil {
+			return fmt.Errorf("failed to save user rollup: %w", err)
+		}
+	}
+	return tx.Commit()
+}
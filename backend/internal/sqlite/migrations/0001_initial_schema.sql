@@ -0,0 +1,212 @@
+-- Initial schema, matching the Postgres tables of database/migrations.
+-- UUIDs and JSON are stored as text, and timestamps as UTC text that sorts
+-- chronologically.
+
+CREATE TABLE errors (
+    id TEXT PRIMARY KEY,
+    project_id TEXT,
+    timestamp TIMESTAMP NOT NULL,
+    level TEXT NOT NULL DEFAULT 'error',
+    message TEXT NOT NULL,
+    stack_trace TEXT,
+    context TEXT DEFAULT '{}',
+    source TEXT NOT NULL,
+    environment TEXT DEFAULT 'production',
+    user_agent TEXT,
+    ip_address TEXT,
+    url TEXT,
+    release TEXT,
+    fingerprint TEXT,
+    resolved BOOLEAN DEFAULT FALSE,
+    count INTEGER DEFAULT 1,
+    first_seen TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    last_seen TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    updated_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    geo_country TEXT,
+    geo_region TEXT,
+    geo_city TEXT,
+    geo_asn INTEGER,
+    geo_as_org TEXT,
+    browser_name TEXT,
+    browser_version TEXT,
+    os_name TEXT,
+    os_version TEXT,
+    device_type TEXT,
+    is_bot BOOLEAN NOT NULL DEFAULT FALSE,
+    trace_id TEXT,
+    span_id TEXT,
+    request_id TEXT,
+    user_info TEXT,
+    breadcrumbs TEXT,
+    frames TEXT
+);
+
+CREATE TABLE error_tags (
+    error_id TEXT NOT NULL REFERENCES errors(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    PRIMARY KEY (error_id, key)
+);
+
+CREATE TABLE user_feedback (
+    id TEXT PRIMARY KEY,
+    project_id TEXT,
+    event_id TEXT NOT NULL,
+    name TEXT,
+    email TEXT,
+    comments TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE attachments (
+    id TEXT PRIMARY KEY,
+    project_id TEXT,
+    event_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+-- bucket_start is in Unix seconds
+CREATE TABLE issue_users (
+    fingerprint TEXT NOT NULL,
+    granularity TEXT NOT NULL,
+    bucket_start INTEGER NOT NULL,
+    hll BLOB NOT NULL,
+    users INTEGER NOT NULL,
+    updated_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    PRIMARY KEY (fingerprint, granularity, bucket_start)
+);
+
+-- Releases, issue state and source maps belong to a project. Those without
+-- one are keyed by the nil UUID, as key columns can't be NULL
+CREATE TABLE releases (
+    project_id TEXT NOT NULL,
+    version TEXT NOT NULL,
+    first_seen TIMESTAMP NOT NULL,
+    last_seen TIMESTAMP NOT NULL,
+    PRIMARY KEY (project_id, version)
+);
+
+CREATE TABLE source_maps (
+    project_id TEXT NOT NULL,
+    release TEXT NOT NULL,
+    name TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    PRIMARY KEY (project_id, release, name)
+);
+
+CREATE TABLE issue_releases (
+    project_id TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    release TEXT NOT NULL,
+    first_seen TIMESTAMP NOT NULL,
+    last_seen TIMESTAMP NOT NULL,
+    count INTEGER NOT NULL DEFAULT 1,
+    PRIMARY KEY (project_id, fingerprint, release),
+    FOREIGN KEY (project_id, release) REFERENCES releases(project_id, version)
+);
+
+CREATE TABLE issue_states (
+    project_id TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    status TEXT NOT NULL,
+    resolved_in TEXT,
+    resolved_at TIMESTAMP NOT NULL,
+    regressed_in TEXT,
+    regressed_at TIMESTAMP,
+    PRIMARY KEY (project_id, fingerprint)
+);
+
+CREATE TABLE deploys (
+    id TEXT PRIMARY KEY,
+    project_id TEXT,
+    environment TEXT NOT NULL,
+    release TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    commit_sha TEXT,
+    deployer TEXT,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE api_keys (
+    id TEXT PRIMARY KEY,
+    key_hash TEXT UNIQUE NOT NULL,
+    name TEXT NOT NULL,
+    project_id TEXT,
+    scope TEXT NOT NULL DEFAULT 'full',
+    active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    last_used TIMESTAMP
+);
+
+CREATE TABLE projects (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    slug TEXT UNIQUE NOT NULL,
+    settings TEXT NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+-- Server-side secrets such as hashing salts, never returned by the API
+CREATE TABLE secrets (
+    name TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX idx_errors_timestamp ON errors(timestamp DESC);
+CREATE INDEX idx_errors_level ON errors(level);
+CREATE INDEX idx_errors_source ON errors(source);
+CREATE INDEX idx_errors_fingerprint ON errors(fingerprint);
+CREATE INDEX idx_errors_resolved ON errors(resolved);
+CREATE INDEX idx_errors_environment ON errors(environment);
+CREATE INDEX idx_errors_project_timestamp ON errors(project_id, timestamp);
+CREATE INDEX idx_errors_geo_country ON errors(geo_country);
+CREATE INDEX idx_errors_browser ON errors(browser_name, browser_version);
+CREATE INDEX idx_errors_os ON errors(os_name);
+CREATE INDEX idx_errors_release ON errors(release);
+CREATE INDEX idx_errors_environment_fingerprint ON errors(environment, fingerprint, timestamp);
+CREATE INDEX idx_errors_trace_id ON errors(trace_id) WHERE trace_id IS NOT NULL;
+CREATE INDEX idx_errors_request_id ON errors(request_id) WHERE request_id IS NOT NULL;
+CREATE INDEX idx_user_feedback_event ON user_feedback(event_id);
+CREATE INDEX idx_attachments_event ON attachments(event_id);
+CREATE INDEX idx_attachments_project ON attachments(project_id);
+CREATE INDEX idx_attachments_created_at ON attachments(created_at);
+CREATE INDEX idx_error_tags_key_value ON error_tags(key, value);
+CREATE INDEX idx_deploys_environment_timestamp ON deploys(environment, timestamp);
+
+CREATE TRIGGER update_errors_updated_at AFTER UPDATE ON errors
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE errors SET updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now') WHERE id = NEW.id;
+END;
+
+-- Sample project, the API key "test-api-key" and the ingest key
+-- "test-ingest-key", as on Postgres. The
+-- project ID is a random version 4 UUID
+INSERT INTO projects (id, name, slug)
+SELECT lower(substr(h, 1, 8) || '-' || substr(h, 9, 4) || '-4' || substr(h, 14, 3) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(h, 18, 3) || '-' || substr(h, 21)),
+    'Default Project', 'default'
+FROM (SELECT hex(randomblob(16)) AS h);
+
+INSERT INTO api_keys (id, key_hash, name, project_id)
+SELECT lower(substr(h, 1, 8) || '-' || substr(h, 9, 4) || '-4' || substr(h, 14, 3) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(h, 18, 3) || '-' || substr(h, 21)),
+    '4c806362b613f7496abf284146efd31da90e4b16169fe001841ca17290f427c4', 'Development Key', (SELECT id FROM projects WHERE slug = 'default')
+FROM (SELECT hex(randomblob(16)) AS h);
+
+INSERT INTO api_keys (id, key_hash, name, project_id, scope)
+SELECT lower(substr(h, 1, 8) || '-' || substr(h, 9, 4) || '-4' || substr(h, 14, 3) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(h, 18, 3) || '-' || substr(h, 21)),
+    '5a0a187600e0173ab293d13ad1589ce62c1f2210a41d18f893930379b0bd992b', 'Development Ingest Key',
+    (SELECT id FROM projects WHERE slug = 'default'), 'ingest'
+FROM (SELECT hex(randomblob(16)) AS h);
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+func (db *DB) ValidateAPIKey(keyHash string) (*models.APIKey, error) {
+	query := `
+		SELECT id, key_hash, name, project_id, scope, active, created_at, last_used
+		FROM api_keys WHERE key_hash = $1 AND active = true
+	`
+
+	var apiKey models.APIKey
+	err := db.QueryRow(query, keyHash).Scan(
+		&apiKey.ID, &apiKey.KeyHash, &apiKey.Name, &apiKey.ProjectID,
+		&apiKey.Scope, &apiKey.Active, &apiKey.CreatedAt, &apiKey.LastUsed,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invalid API key")
+		}
+		return nil, fmt.Errorf("failed to validate API key: %w", err)
+	}
+
+	db.Exec("UPDATE api_keys SET last_used = $1 WHERE id = $2", time.Now(), apiKey.ID)
+
+	return &apiKey, nil
+}
+
+func (db *DB) GetProjectIDBySlug(slug string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := db.QueryRow("SELECT id FROM projects WHERE slug = $1", slug).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return uuid.Nil, fmt.Errorf("project not found")
+		}
+		return uuid.Nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	return id, nil
+}
+
+func (db *DB) GetProjectSettings(projectID uuid.UUID) (*models.ProjectSettings, error) {
+	var settingsJSON []byte
+	err := db.QueryRow("SELECT settings FROM projects WHERE id = $1", projectID).Scan(&settingsJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("project not found")
+		}
+		return nil, fmt.Errorf("failed to get project settings: %w", err)
+	}
+
+	var settings models.ProjectSettings
+	if err := json.Unmarshal(settingsJSON, &settings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal project settings: %w", err)
+	}
+	return &settings, nil
+}
+
+func (db *DB) UpdateProjectSettings(projectID uuid.UUID, settings *models.ProjectSettings) error {
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal project settings: %w", err)
+	}
+
+	result, err := db.Exec("UPDATE projects SET settings = $1 WHERE id = $2", string(settingsJSON), projectID)
+	if err != nil {
+		return fmt.Errorf("failed to update project settings: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("project not found")
+	}
+	return nil
+}
+
+// ListProjectSettings returns the settings of every project.
+func (db *DB) ListProjectSettings() (map[uuid.UUID]*models.ProjectSettings, error) {
+	rows, err := db.Query("SELECT id, settings FROM projects")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query projects: %w", err)
+	}
+	defer rows.Close()
+
+	projects := make(map[uuid.UUID]*models.ProjectSettings)
+	for rows.Next() {
+		var id uuid.UUID
+		var settingsJSON []byte
+		if err := rows.Scan(&id, &settingsJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
+		var settings models.ProjectSettings
+		if err := json.Unmarshal(settingsJSON, &settings); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal settings of project %s: %w", id, err)
+		}
+		projects[id] = &settings
+	}
+	return projects, rows.Err()
+}
@@ -0,0 +1,107 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"error-logs/internal/models"
+)
+
+// retentionClause builds the WHERE conditions selecting the events a rule
+// has expired. Level lists are passed as JSON arrays.
+func retentionClause(rule models.RetentionRule) (string, []interface{}) {
+	args := []interface{}{rule.Before}
+	clause := "timestamp < $1"
+	if rule.ProjectID != nil {
+		args = append(args, *rule.ProjectID)
+		clause += fmt.Sprintf(" AND project_id = $%d", len(args))
+	} else {
+		clause += " AND project_id IS NULL"
+	}
+	if len(rule.Levels) > 0 {
+		levels, _ := json.Marshal(rule.Levels)
+		args = append(args, string(levels))
+		clause += fmt.Sprintf(" AND level IN (SELECT value FROM json_each($%d))", len(args))
+	} else if len(rule.ExceptLevels) > 0 {
+		levels, _ := json.Marshal(rule.ExceptLevels)
+		args = append(args, string(levels))
+		clause += fmt.Sprintf(" AND level NOT IN (SELECT value FROM json_each($%d))", len(args))
+	}
+	if rule.ResolvedOnly {
+		clause += " AND resolved"
+	}
+	return clause, args
+}
+
+// DeleteExpiredErrors deletes up to limit events, with their tags, that a
+// retention rule has expired, and returns how many it deleted.
+func (db *DB) DeleteExpiredErrors(rule models.RetentionRule, limit int) (int64, error) {
+	where, args := retentionClause(rule)
+	args = append(args, limit)
+	query := fmt.Sprintf("DELETE FROM errors WHERE id IN (SELECT id FROM errors WHERE %s LIMIT $%d)", where, len(args))
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired errors: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ListExpiredErrors returns up to limit events, oldest first and complete
+// with tags, breadcrumbs and frames, that a retention rule has expired.
+func (db *DB) ListExpiredErrors(rule models.RetentionRule, limit int) ([]models.Error, error) {
+	where, args := retentionClause(rule)
+	args = append(args, limit)
+	query := fmt.Sprintf("SELECT %s, breadcrumbs, frames FROM errors WHERE %s ORDER BY timestamp, id LIMIT $%d",
+		errorColumns, where, len(args))
+	return db.listErrors(query, true, args...)
+}
+
+// DeleteArchivedErrors deletes the given events, with their tags, provided
+// the rule that listed them still expires them, and returns how many it
+// deleted.
+func (db *DB) DeleteArchivedErrors(rule models.RetentionRule, errors []models.Error) (int64, error) {
+	if len(errors) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, len(errors))
+	for i, e := range errors {
+		ids[i] = e.ID.String()
+	}
+	idsJSON, _ := json.Marshal(ids)
+
+	where, args := retentionClause(rule)
+	args = append(args, string(idsJSON))
+	query := fmt.Sprintf("DELETE FROM errors WHERE %s AND id IN (SELECT value FROM json_each($%d))", where, len(args))
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete archived errors: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// RestoreErrors inserts archived events and their tags in one transaction,
+// skipping any still stored, and returns how many it inserted.
+func (db *DB) RestoreErrors(errors []models.Error) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var restored int64
+	for i := range errors {
+		inserted, err := insertError(tx, &errors[i], true)
+		if err != nil {
+			return 0, fmt.Errorf("failed to restore error %s: %w", errors[i].ID, err)
+		}
+		if inserted {
+			restored++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	return restored, nil
+}
@@ -0,0 +1,34 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetOrCreateSecret returns the secret stored under name, first storing
+// value under it if there is none. Concurrent callers all get the value
+// that was stored first.
+func (db *DB) GetOrCreateSecret(name, value string, expiresAt *time.Time) (string, error) {
+	_, err := db.Exec(`
+		INSERT INTO secrets (name, value, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO NOTHING
+	`, name, value, expiresAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to store secret: %w", err)
+	}
+
+	var stored string
+	if err := db.QueryRow("SELECT value FROM secrets WHERE name = $1", name).Scan(&stored); err != nil {
+		return "", fmt.Errorf("failed to get secret: %w", err)
+	}
+	return stored, nil
+}
+
+// DeleteExpiredSecrets removes secrets whose expiry has passed.
+func (db *DB) DeleteExpiredSecrets(now time.Time) (int64, error) {
+	result, err := db.Exec("DELETE FROM secrets WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired secrets: %w", err)
+	}
+	return result.RowsAffected()
+}
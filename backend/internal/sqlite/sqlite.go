@@ -0,0 +1,206 @@
+// Package sqlite stores everything in a single SQLite file, for small
+// installs and local development that don't want to run Postgres. It uses
+// a pure-Go driver, so the server stays a single static binary.
+//
+// The schema mirrors the Postgres one: UUIDs and JSON are stored as text,
+// and timestamps as UTC text in a format that sorts chronologically, so
+// every time.Time is converted to UTC before it is bound.
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"error-logs/internal/storage"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_\w+\.sql$`)
+
+// timeLayout is how the driver writes times with _time_format=sqlite.
+const timeLayout = "2006-01-02 15:04:05.999999999-07:00"
+
+type DB struct {
+	*sql.DB
+}
+
+var _ storage.Store = (*DB)(nil)
+
+// Open opens or creates the database file at path and brings its schema up
+// to date.
+func Open(path string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite"
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite allows one writer at a time; a single connection queues
+	// writers here instead of failing them with SQLITE_BUSY
+	sqlDB.SetMaxOpenConns(1)
+
+	db := &DB{sqlDB}
+	if err := db.migrate(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// migrate applies the embedded migrations newer than the database's
+// user_version, each in a transaction that also advances user_version.
+func (db *DB) migrate() error {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return fmt.Errorf("failed to read migrations: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var current int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, entry := range entries {
+		m := migrationFileRe.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		if version <= current {
+			continue
+		}
+		script, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		tx, err := db.DB.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		if _, err := tx.Exec(string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s failed: %w", entry.Name(), err)
+		}
+		// PRAGMA takes no parameters; version is a parsed integer
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", entry.Name(), err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", entry.Name(), err)
+		}
+		log.Printf("Applied SQLite migration %s", strings.TrimSuffix(entry.Name(), ".sql"))
+	}
+	return nil
+}
+
+// utcArgs converts times to UTC so they compare correctly as text.
+func utcArgs(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			args[i] = v.UTC()
+		case *time.Time:
+			if v != nil {
+				args[i] = v.UTC()
+			}
+		}
+	}
+	return args
+}
+
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(query, utcArgs(args)...)
+}
+
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(query, utcArgs(args)...)
+}
+
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(query, utcArgs(args)...)
+}
+
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx}, nil
+}
+
+// Tx converts times to UTC like DB.
+type Tx struct {
+	*sql.Tx
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(query, utcArgs(args)...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return tx.Tx.QueryRow(query, utcArgs(args)...)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// timeValue scans a timestamp computed by a query, such as MIN(timestamp),
+// which the driver returns as text since it has no declared type.
+type timeValue struct {
+	t *time.Time
+}
+
+func (v timeValue) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case time.Time:
+		*v.t = s.UTC()
+	case string:
+		return v.parse(s)
+	case []byte:
+		return v.parse(string(s))
+	default:
+		return fmt.Errorf("cannot scan %T into a time", src)
+	}
+	return nil
+}
+
+func (v timeValue) parse(s string) error {
+	for _, layout := range []string{timeLayout, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			*v.t = t.UTC()
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse time %q", s)
+}
+
+// jsonText binds JSON as text: a []byte would be stored as a blob, which
+// SQLite's JSON functions read as its binary JSONB format.
+func jsonText(data []byte) interface{} {
+	if data == nil {
+		return nil
+	}
+	return string(data)
+}
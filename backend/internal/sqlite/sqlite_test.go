@@ -0,0 +1,18 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"error-logs/internal/storage/storetest"
+)
+
+func TestStore(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "error-logs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	storetest.Run(t, db)
+}
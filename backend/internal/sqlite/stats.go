@@ -0,0 +1,163 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+
+	"error-logs/internal/models"
+)
+
+func (db *DB) GetStats() (*models.StatsResponse, error) {
+	stats := &models.StatsResponse{}
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	err := db.QueryRow(`
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE resolved),
+			COUNT(*) FILTER (WHERE timestamp >= $1),
+			COUNT(*) FILTER (WHERE timestamp >= $2),
+			COUNT(*) FILTER (WHERE timestamp >= $3)
+		FROM errors
+	`, today, now.AddDate(0, 0, -7), now.AddDate(0, 0, -30)).Scan(
+		&stats.TotalErrors, &stats.ResolvedErrors, &stats.ErrorsToday, &stats.ErrorsThisWeek, &stats.ErrorsThisMonth,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats: %w", err)
+	}
+	return stats, nil
+}
+
+// trendBuckets truncate timestamps like Postgres's date_trunc, with weeks
+// starting on Monday.
+var trendBuckets = map[string]string{
+	"hour":  "strftime('%Y-%m-%d %H:00:00', timestamp)",
+	"day":   "strftime('%Y-%m-%d 00:00:00', timestamp)",
+	"week":  "strftime('%Y-%m-%d 00:00:00', timestamp, 'weekday 0', '-6 days')",
+	"month": "strftime('%Y-%m-01 00:00:00', timestamp)",
+}
+
+// GetTrends counts errors per time bucket (hour, day, week or month) since
+// the given time.
+func (db *DB) GetTrends(bucket string, filter models.ErrorFilter, since time.Time) ([]models.TrendPoint, error) {
+	expr, ok := trendBuckets[bucket]
+	if !ok {
+		return nil, fmt.Errorf("unknown trend bucket %q", bucket)
+	}
+
+	whereClause, args := filterClause(filter)
+	args = append(args, since)
+	query := fmt.Sprintf(`
+		SELECT %s AS bucket, COUNT(*), COUNT(*) FILTER (WHERE resolved)
+		FROM errors %s AND timestamp >= $%d
+		GROUP BY bucket
+		ORDER BY bucket
+	`, expr, whereClause, len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trends: %w", err)
+	}
+	defer rows.Close()
+
+	points := []models.TrendPoint{}
+	for rows.Next() {
+		var p models.TrendPoint
+		if err := rows.Scan(timeValue{&p.Timestamp}, &p.ErrorCount, &p.ResolvedCount); err != nil {
+			return nil, fmt.Errorf("failed to scan trend: %w", err)
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// breakdownColumns maps the fields errors can be grouped by to columns.
+var breakdownColumns = map[string]string{
+	"level":       "level",
+	"source":      "source",
+	"environment": "environment",
+	"country":     "geo_country",
+	"region":      "geo_region",
+	"city":        "geo_city",
+	"asn":         "CAST(geo_asn AS TEXT)",
+	"as_org":      "geo_as_org",
+	"browser":     "browser_name",
+	// browser_version groups by name and major version, e.g. "Safari 17"
+	"browser_version": "browser_name || ' ' || substr(browser_version, 1, instr(browser_version || '.', '.') - 1)",
+	"os":              "os_name",
+	"os_version":      "os_name || ' ' || os_version",
+	"device":          "device_type",
+	"release":         "release",
+}
+
+// GetBreakdown counts errors since the given time grouped by one field,
+// most frequent first. Events without a value for the field are left out.
+func (db *DB) GetBreakdown(by string, filter models.ErrorFilter, since time.Time, limit int) ([]models.BreakdownItem, error) {
+	column, ok := breakdownColumns[by]
+	if !ok {
+		return nil, fmt.Errorf("unknown breakdown field %q", by)
+	}
+
+	whereClause, args := filterClause(filter)
+	args = append(args, since, limit)
+	query := fmt.Sprintf(`
+		SELECT %[1]s, COUNT(*) FROM errors
+		%[2]s AND timestamp >= $%[3]d AND %[1]s IS NOT NULL
+		GROUP BY %[1]s
+		ORDER BY COUNT(*) DESC
+		LIMIT $%[4]d
+	`, column, whereClause, len(args)-1, len(args))
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query breakdown: %w", err)
+	}
+	defer rows.Close()
+
+	items := []models.BreakdownItem{}
+	for rows.Next() {
+		var item models.BreakdownItem
+		if err := rows.Scan(&item.Value, &item.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan breakdown: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetTagDistribution counts the values of every tag across an issue's
+// events, keeping the most common values of each key.
+func (db *DB) GetTagDistribution(fingerprint string, valuesPerKey int) ([]models.TagDistribution, error) {
+	rows, err := db.Query(`
+		SELECT key, value, count, total FROM (
+			SELECT t.key, t.value, COUNT(*) AS count,
+				SUM(COUNT(*)) OVER (PARTITION BY t.key) AS total,
+				ROW_NUMBER() OVER (PARTITION BY t.key ORDER BY COUNT(*) DESC, t.value) AS rank
+			FROM error_tags t JOIN errors e ON e.id = t.error_id
+			WHERE e.fingerprint = $1
+			GROUP BY t.key, t.value
+		) ranked
+		WHERE rank <= $2
+		ORDER BY total DESC, key, count DESC
+	`, fingerprint, valuesPerKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag distribution: %w", err)
+	}
+	defer rows.Close()
+
+	distribution := []models.TagDistribution{}
+	for rows.Next() {
+		var key string
+		var value models.TagValueCount
+		var total int
+		if err := rows.Scan(&key, &value.Value, &value.Count, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan tag distribution: %w", err)
+		}
+		if n := len(distribution); n == 0 || distribution[n-1].Key != key {
+			distribution = append(distribution, models.TagDistribution{Key: key, Total: total})
+		}
+		last := &distribution[len(distribution)-1]
+		last.Values = append(last.Values, value)
+	}
+	return distribution, rows.Err()
+}
@@ -0,0 +1,119 @@
+// Package storage defines what the services and API key middleware need from
+// the database. database implements it on Postgres; sqlite implements it on
+// a single local file for small installs and development.
+package storage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+)
+
+// ErrorStore holds events with their tags, breadcrumbs and frames.
+type ErrorStore interface {
+	CreateError(error *models.Error) error
+	GetErrors(limit, offset int, filter models.ErrorFilter) ([]models.Error, int, error)
+	GetErrorByID(id uuid.UUID) (*models.Error, error)
+	GetTraceErrors(traceID string, limit int) ([]models.Error, error)
+	ResolveError(id uuid.UUID) error
+	DeleteError(id uuid.UUID) error
+	ClearIPAddresses(projectID uuid.UUID, before time.Time) (int64, error)
+
+	// Retention, archiving and restoring archives
+	DeleteExpiredErrors(rule models.RetentionRule, limit int) (int64, error)
+	ListExpiredErrors(rule models.RetentionRule, limit int) ([]models.Error, error)
+	DeleteArchivedErrors(rule models.RetentionRule, errors []models.Error) (int64, error)
+	RestoreErrors(errors []models.Error) (int64, error)
+}
+
+// KeyStore looks up API keys; it satisfies handlers.KeyValidator.
+type KeyStore interface {
+	ValidateAPIKey(keyHash string) (*models.APIKey, error)
+}
+
+// StatsStore aggregates events for the dashboard and analytics endpoints.
+type StatsStore interface {
+	GetStats() (*models.StatsResponse, error)
+	GetTrends(bucket string, filter models.ErrorFilter, since time.Time) ([]models.TrendPoint, error)
+	GetBreakdown(by string, filter models.ErrorFilter, since time.Time, limit int) ([]models.BreakdownItem, error)
+	GetTagDistribution(fingerprint string, valuesPerKey int) ([]models.TagDistribution, error)
+}
+
+// IssueStore tracks issues across events: releases, resolution, feedback
+// and affected users. Releases and resolutions are per project; a nil
+// projectID means events without one.
+type IssueStore interface {
+	RecordRelease(projectID *uuid.UUID, fingerprint, release string, seenAt time.Time) error
+	IsNewerRelease(projectID *uuid.UUID, a, b string) (bool, error)
+	LatestRelease(projectID *uuid.UUID, fingerprint string) (string, error)
+	GetIssueReleases(projectID *uuid.UUID, fingerprint string) ([]models.IssueRelease, error)
+	GetIssueState(projectID *uuid.UUID, fingerprint string) (*models.IssueState, error)
+	ResolveIssue(projectID *uuid.UUID, fingerprint, status string, resolvedIn *string) error
+	RegressIssue(projectID *uuid.UUID, fingerprint string, release *string) error
+
+	CreateFeedback(feedback *models.Feedback) error
+	GetIssueFeedback(fingerprint string, limit int) ([]models.Feedback, error)
+
+	GetUserRollups(buckets []models.UserBucket) ([]models.UserRollup, error)
+	SaveUserRollups(rollups []models.UserRollup) error
+}
+
+type ProjectStore interface {
+	GetProjectIDBySlug(slug string) (uuid.UUID, error)
+	GetProjectSettings(projectID uuid.UUID) (*models.ProjectSettings, error)
+	UpdateProjectSettings(projectID uuid.UUID, settings *models.ProjectSettings) error
+	ListProjectSettings() (map[uuid.UUID]*models.ProjectSettings, error)
+}
+
+type DeployStore interface {
+	CreateDeploy(deploy *models.Deploy) error
+	GetDeploy(id uuid.UUID) (*models.Deploy, error)
+	ListDeploys(environment string, since, until time.Time, limit int) ([]models.Deploy, error)
+	GetDeployIssues(deploy *models.Deploy, limit int) ([]models.DeployIssue, error)
+}
+
+// FileStore records attachments and source maps, whose content lives in
+// blob storage.
+type FileStore interface {
+	CreateAttachment(a *models.Attachment) error
+	GetAttachment(id uuid.UUID) (*models.Attachment, error)
+	ListEventAttachments(eventID uuid.UUID) ([]models.Attachment, error)
+	ListExpiredAttachments(before time.Time, limit int) ([]models.Attachment, error)
+	AttachmentUsage(projectID *uuid.UUID) (int64, error)
+	DeleteAttachment(id uuid.UUID) error
+
+	SaveSourceMap(sm *models.SourceMap) error
+	GetSourceMap(projectID *uuid.UUID, release, name string) (*models.SourceMap, error)
+	ListSourceMaps(projectID *uuid.UUID, release string) ([]models.SourceMap, error)
+}
+
+// SecretStore keeps server-side secrets, such as hashing salts, that every
+// instance must agree on and that are never returned by the API.
+type SecretStore interface {
+	GetOrCreateSecret(name, value string, expiresAt *time.Time) (string, error)
+	DeleteExpiredSecrets(now time.Time) (int64, error)
+}
+
+// Store is a complete storage backend.
+type Store interface {
+	ErrorStore
+	KeyStore
+	StatsStore
+	IssueStore
+	ProjectStore
+	DeployStore
+	FileStore
+	SecretStore
+	Close() error
+}
+
+// Partitioner is implemented by backends that partition events by time,
+// which only Postgres does.
+type Partitioner interface {
+	IsPartitioned() (bool, error)
+	EnsurePartitions(interval string, until time.Time) ([]string, error)
+	DropPartitionsBefore(before time.Time, beforeDrop func(partition string) error) ([]string, error)
+	ListPartitionErrors(partition string, afterTimestamp time.Time, afterID *uuid.UUID, limit int) ([]models.Error, error)
+}
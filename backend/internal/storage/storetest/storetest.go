@@ -0,0 +1,602 @@
+// Package storetest checks that a storage.Store behaves the way the services
+// expect, so every backend is held to the same contract.
+package storetest
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"error-logs/internal/models"
+	"error-logs/internal/storage"
+)
+
+// Run tests s against the storage contract. The store may be shared and hold
+// other data, so each test works in a project and on names of its own.
+func Run(t *testing.T, s storage.Store) {
+	t.Run("ErrorFilters", func(t *testing.T) { testErrorFilters(t, s) })
+	t.Run("Tags", func(t *testing.T) { testTags(t, s) })
+	t.Run("Retention", func(t *testing.T) { testRetention(t, s) })
+	t.Run("ArchiveRestore", func(t *testing.T) { testArchiveRestore(t, s) })
+	t.Run("Releases", func(t *testing.T) { testReleases(t, s) })
+	t.Run("IssueState", func(t *testing.T) { testIssueState(t, s) })
+	t.Run("SourceMaps", func(t *testing.T) { testSourceMaps(t, s) })
+	t.Run("Settings", func(t *testing.T) { testSettings(t, s) })
+	t.Run("Secrets", func(t *testing.T) { testSecrets(t, s) })
+}
+
+// now is truncated to what every backend stores exactly.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+func strPtr(s string) *string { return &s }
+
+// newError returns an event of a project, taking its source, level and
+// optional fields from e.
+func newError(projectID uuid.UUID, e models.Error) *models.Error {
+	e.ID = uuid.New()
+	e.ProjectID = &projectID
+	if e.Timestamp.IsZero() {
+		e.Timestamp = now()
+	}
+	if e.Level == "" {
+		e.Level = "error"
+	}
+	if e.Message == "" {
+		e.Message = "something broke"
+	}
+	if e.Context == nil {
+		e.Context = map[string]interface{}{}
+	}
+	if e.Environment == "" {
+		e.Environment = "test"
+	}
+	e.Count = 1
+	e.FirstSeen, e.LastSeen = e.Timestamp, e.Timestamp
+	e.CreatedAt, e.UpdatedAt = e.Timestamp, e.Timestamp
+	return &e
+}
+
+func create(t *testing.T, s storage.Store, errors ...*models.Error) {
+	t.Helper()
+	for _, e := range errors {
+		if err := s.CreateError(e); err != nil {
+			t.Fatalf("CreateError: %v", err)
+		}
+	}
+}
+
+func ids(errors []models.Error) []uuid.UUID {
+	ids := make([]uuid.UUID, len(errors))
+	for i, e := range errors {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func testErrorFilters(t *testing.T, s storage.Store) {
+	project := uuid.New()
+	source := "storetest-" + uuid.NewString()
+	base := now().Add(-time.Hour)
+	bot := true
+
+	first := newError(project, models.Error{
+		Source: source, Timestamp: base, Level: "warning", Release: strPtr("1.0"),
+		Country: strPtr("DE"), BrowserName: strPtr("Firefox"), BrowserVersion: strPtr("128.0"),
+		Tags: map[string]string{"plan": "free"},
+	})
+	second := newError(project, models.Error{
+		Source: source, Timestamp: base.Add(time.Minute), Release: strPtr("1.1"),
+		Country: strPtr("FR"), BrowserName: strPtr("Firefox"), BrowserVersion: strPtr("1280.1"),
+		TraceID: strPtr("trace-" + source), IsBot: bot,
+		Tags: map[string]string{"plan": "pro", "region": "eu"},
+	})
+	third := newError(project, models.Error{
+		Source: source, Timestamp: base.Add(2 * time.Minute), Release: strPtr("1.1"),
+		Country: strPtr("FR"), BrowserName: strPtr("Chrome"), BrowserVersion: strPtr("126"),
+		Tags:        map[string]string{"plan": "pro"},
+		User:        &models.EventUser{ID: "42"},
+		Breadcrumbs: []models.Breadcrumb{{Category: "ui.click", Message: "Save"}},
+		Frames:      []models.StackFrame{{Function: "save", File: "app.js", Line: 10, Column: 3}},
+	})
+	create(t, s, first, second, third)
+
+	tests := []struct {
+		name   string
+		filter models.ErrorFilter
+		want   []uuid.UUID // newest first
+	}{
+		{"all", models.ErrorFilter{}, []uuid.UUID{third.ID, second.ID, first.ID}},
+		{"level", models.ErrorFilter{Level: "warning"}, []uuid.UUID{first.ID}},
+		{"release", models.ErrorFilter{Release: "1.1"}, []uuid.UUID{third.ID, second.ID}},
+		{"country", models.ErrorFilter{Country: "DE"}, []uuid.UUID{first.ID}},
+		{"browser version prefix", models.ErrorFilter{Browser: "Firefox", BrowserVersion: "128"}, []uuid.UUID{first.ID}},
+		{"bot", models.ErrorFilter{Bot: &bot}, []uuid.UUID{second.ID}},
+		{"trace", models.ErrorFilter{TraceID: "trace-" + source}, []uuid.UUID{second.ID}},
+		{"tag", models.ErrorFilter{Tags: map[string]string{"plan": "pro"}}, []uuid.UUID{third.ID, second.ID}},
+		{"tags", models.ErrorFilter{Tags: map[string]string{"plan": "pro", "region": "eu"}}, []uuid.UUID{second.ID}},
+		{"no match", models.ErrorFilter{Level: "debug"}, []uuid.UUID{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.filter.Source = source
+			errors, total, err := s.GetErrors(10, 0, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(errors); total != len(tt.want) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetErrors = %v (total %d), want %v", got, total, tt.want)
+			}
+		})
+	}
+
+	errors, total, err := s.GetErrors(1, 1, models.ErrorFilter{Source: source})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(errors); total != 3 || !reflect.DeepEqual(got, []uuid.UUID{second.ID}) {
+		t.Errorf("GetErrors(limit 1, offset 1) = %v (total %d), want [%s] (total 3)", got, total, second.ID)
+	}
+	if len(errors) == 1 && !reflect.DeepEqual(errors[0].Tags, second.Tags) {
+		t.Errorf("Tags = %v, want %v", errors[0].Tags, second.Tags)
+	}
+
+	got, err := s.GetErrorByID(third.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Timestamp.Equal(third.Timestamp) || *got.ProjectID != project || got.Level != third.Level {
+		t.Errorf("GetErrorByID = %+v, want %+v", got, third)
+	}
+	for name, pair := range map[string][2]interface{}{
+		"Tags":        {got.Tags, third.Tags},
+		"User":        {got.User, third.User},
+		"Breadcrumbs": {got.Breadcrumbs, third.Breadcrumbs},
+		"Frames":      {got.Frames, third.Frames},
+	} {
+		if !reflect.DeepEqual(pair[0], pair[1]) {
+			t.Errorf("%s = %+v, want %+v", name, pair[0], pair[1])
+		}
+	}
+	if _, err := s.GetErrorByID(uuid.New()); err == nil || err.Error() != "error not found" {
+		t.Errorf("GetErrorByID(unknown) error = %v, want error not found", err)
+	}
+
+	traced, err := s.GetTraceErrors("trace-"+source, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(traced); !reflect.DeepEqual(got, []uuid.UUID{second.ID}) {
+		t.Errorf("GetTraceErrors = %v, want [%s]", got, second.ID)
+	}
+}
+
+func testTags(t *testing.T, s storage.Store) {
+	project := uuid.New()
+	fingerprint := "storetest-" + uuid.NewString()
+	var events []*models.Error
+	for _, tags := range []map[string]string{
+		{"browser": "Firefox", "plan": "pro"},
+		{"browser": "Firefox", "plan": "pro"},
+		{"browser": "Chrome"},
+	} {
+		events = append(events, newError(project, models.Error{Fingerprint: &fingerprint, Tags: tags}))
+	}
+	create(t, s, events...)
+
+	got, err := s.GetTagDistribution(fingerprint, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.TagDistribution{
+		{Key: "browser", Total: 3, Values: []models.TagValueCount{{Value: "Firefox", Count: 2}, {Value: "Chrome", Count: 1}}},
+		{Key: "plan", Total: 2, Values: []models.TagValueCount{{Value: "pro", Count: 2}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTagDistribution = %+v, want %+v", got, want)
+	}
+
+	got, err = s.GetTagDistribution(fingerprint, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Total != 3 || len(got[0].Values) != 1 {
+		t.Errorf("GetTagDistribution(1 value per key) = %+v, want the top value with the full total", got)
+	}
+
+	// Deleting an event removes its tags
+	if err := s.DeleteError(events[2].ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.GetTagDistribution(fingerprint, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Total != 2 || len(got[0].Values) != 1 {
+		t.Errorf("GetTagDistribution after DeleteError = %+v, want Chrome gone", got)
+	}
+}
+
+func testRetention(t *testing.T, s storage.Store) {
+	project := uuid.New()
+	cutoff := now().Add(-24 * time.Hour)
+	old := cutoff.Add(-time.Hour)
+
+	oldDebug := newError(project, models.Error{Level: "debug", Timestamp: old})
+	oldError := newError(project, models.Error{Timestamp: old.Add(time.Minute)})
+	oldResolved := newError(project, models.Error{Timestamp: old.Add(2 * time.Minute), Resolved: true})
+	recent := newError(project, models.Error{Level: "debug"})
+	other := newError(uuid.New(), models.Error{Level: "debug", Timestamp: old})
+	create(t, s, oldDebug, oldError, oldResolved, recent, other)
+
+	tests := []struct {
+		name string
+		rule models.RetentionRule
+		want []uuid.UUID // oldest first
+	}{
+		{"levels", models.RetentionRule{Levels: []string{"debug"}}, []uuid.UUID{oldDebug.ID}},
+		{"except levels", models.RetentionRule{ExceptLevels: []string{"debug"}}, []uuid.UUID{oldError.ID, oldResolved.ID}},
+		{"resolved", models.RetentionRule{ResolvedOnly: true}, []uuid.UUID{oldResolved.ID}},
+		{"all levels", models.RetentionRule{}, []uuid.UUID{oldDebug.ID, oldError.ID, oldResolved.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.ProjectID = &project
+			tt.rule.Before = cutoff
+			errors, err := s.ListExpiredErrors(tt.rule, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(errors); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListExpiredErrors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	rule := models.RetentionRule{ProjectID: &project, Before: cutoff}
+	deleted, err := s.DeleteExpiredErrors(rule, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteExpiredErrors(limit 2) = %d, want 2", deleted)
+	}
+	if deleted, err = s.DeleteExpiredErrors(rule, 10); err != nil || deleted != 1 {
+		t.Errorf("DeleteExpiredErrors = %d, %v, want the remaining 1", deleted, err)
+	}
+	for _, e := range []*models.Error{recent, other} {
+		if _, err := s.GetErrorByID(e.ID); err != nil {
+			t.Errorf("event %s outside the rule was deleted: %v", e.ID, err)
+		}
+	}
+}
+
+func testArchiveRestore(t *testing.T, s storage.Store) {
+	project := uuid.New()
+	fingerprint := "storetest-" + uuid.NewString()
+	cutoff := now().Add(-24 * time.Hour)
+	events := []*models.Error{
+		newError(project, models.Error{
+			Timestamp: cutoff.Add(-2 * time.Hour), Fingerprint: &fingerprint, Resolved: true,
+			Context:     map[string]interface{}{"order": "1234"},
+			Tags:        map[string]string{"plan": "pro"},
+			User:        &models.EventUser{Email: "jane@example.com"},
+			Breadcrumbs: []models.Breadcrumb{{Category: "navigation", Message: "/checkout"}},
+			Frames:      []models.StackFrame{{File: "app.js", Line: 1, Column: 2}},
+		}),
+		newError(project, models.Error{Timestamp: cutoff.Add(-time.Hour), Fingerprint: &fingerprint, Resolved: true}),
+	}
+	create(t, s, events...)
+
+	rule := models.RetentionRule{ProjectID: &project, ResolvedOnly: true, Before: cutoff}
+	archived, err := s.ListExpiredErrors(rule, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archived) != 2 {
+		t.Fatalf("ListExpiredErrors returned %d events, want 2", len(archived))
+	}
+	got := archived[0]
+	if !reflect.DeepEqual(got.Tags, events[0].Tags) || !reflect.DeepEqual(got.Breadcrumbs, events[0].Breadcrumbs) ||
+		!reflect.DeepEqual(got.Frames, events[0].Frames) || !reflect.DeepEqual(got.Context, events[0].Context) ||
+		!reflect.DeepEqual(got.User, events[0].User) {
+		t.Errorf("ListExpiredErrors = %+v, want %+v complete with details", got, events[0])
+	}
+
+	deleted, err := s.DeleteArchivedErrors(rule, archived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteArchivedErrors = %d, want 2", deleted)
+	}
+	if _, err := s.GetErrorByID(events[0].ID); err == nil {
+		t.Error("archived event is still stored")
+	}
+
+	restored, err := s.RestoreErrors(archived)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 2 {
+		t.Errorf("RestoreErrors = %d, want 2", restored)
+	}
+	if restored, err = s.RestoreErrors(archived); err != nil || restored != 0 {
+		t.Errorf("RestoreErrors of stored events = %d, %v, want 0", restored, err)
+	}
+	back, err := s.GetErrorByID(events[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !back.Timestamp.Equal(events[0].Timestamp) || back.Message != events[0].Message ||
+		!reflect.DeepEqual(back.Tags, events[0].Tags) || !reflect.DeepEqual(back.Breadcrumbs, events[0].Breadcrumbs) {
+		t.Errorf("restored event = %+v, want %+v", back, events[0])
+	}
+
+	// An event the rule no longer expires, here reopened, isn't deleted
+	if err := s.RegressIssue(&project, fingerprint, nil); err != nil {
+		t.Fatal(err)
+	}
+	if deleted, err = s.DeleteArchivedErrors(rule, archived); err != nil || deleted != 0 {
+		t.Errorf("DeleteArchivedErrors of reopened events = %d, %v, want 0", deleted, err)
+	}
+}
+
+func testReleases(t *testing.T, s storage.Store) {
+	project, other := uuid.New(), uuid.New()
+	fingerprint := "storetest-" + uuid.NewString()
+	base := now().Add(-time.Hour)
+
+	for _, r := range []struct {
+		project *uuid.UUID
+		release string
+		seenAt  time.Time
+	}{
+		{&project, "1.0", base},
+		{&project, "1.1", base.Add(time.Minute)},
+		{&project, "1.1", base.Add(2 * time.Minute)},
+		{&other, "2.0", base.Add(3 * time.Minute)},
+	} {
+		if err := s.RecordRelease(r.project, fingerprint, r.release, r.seenAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	releases, err := s.GetIssueReleases(&project, fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range releases {
+		names = append(names, r.Release)
+	}
+	if !reflect.DeepEqual(names, []string{"1.1", "1.0"}) {
+		t.Fatalf("GetIssueReleases = %v, want [1.1 1.0]", names)
+	}
+	if r := releases[0]; r.Count != 2 || !r.FirstSeen.Equal(base.Add(time.Minute)) || !r.LastSeen.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("release 1.1 = %+v, want count 2 from the first to the last sighting", r)
+	}
+
+	latest, err := s.LatestRelease(&project, fingerprint)
+	if err != nil || latest != "1.1" {
+		t.Errorf("LatestRelease = %q, %v, want 1.1", latest, err)
+	}
+	if latest, err = s.LatestRelease(&project, ""); err != nil || latest != "1.1" {
+		t.Errorf("LatestRelease of the project = %q, %v, want 1.1", latest, err)
+	}
+	if latest, err = s.LatestRelease(&other, fingerprint); err != nil || latest != "2.0" {
+		t.Errorf("LatestRelease of the other project = %q, %v, want 2.0", latest, err)
+	}
+
+	for _, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"1.1", "1.0", true},
+		{"1.0", "1.1", false},
+		{"9.9", "1.1", true}, // never seen
+	} {
+		newer, err := s.IsNewerRelease(&project, tt.a, tt.b)
+		if err != nil || newer != tt.want {
+			t.Errorf("IsNewerRelease(%s, %s) = %v, %v, want %v", tt.a, tt.b, newer, err, tt.want)
+		}
+	}
+}
+
+func testIssueState(t *testing.T, s storage.Store) {
+	project, other := uuid.New(), uuid.New()
+	fingerprint := "storetest-" + uuid.NewString()
+	mine := newError(project, models.Error{Fingerprint: &fingerprint})
+	theirs := newError(other, models.Error{Fingerprint: &fingerprint})
+	create(t, s, mine, theirs)
+
+	resolved := func(e *models.Error) bool {
+		t.Helper()
+		got, err := s.GetErrorByID(e.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.Resolved
+	}
+
+	if state, err := s.GetIssueState(&project, fingerprint); err != nil || state != nil {
+		t.Fatalf("GetIssueState before resolving = %+v, %v, want nil", state, err)
+	}
+	if err := s.ResolveIssue(&project, fingerprint, models.IssueStatusResolved, strPtr("1.0")); err != nil {
+		t.Fatal(err)
+	}
+	state, err := s.GetIssueState(&project, fingerprint)
+	if err != nil || state == nil {
+		t.Fatalf("GetIssueState = %+v, %v", state, err)
+	}
+	if state.Status != models.IssueStatusResolved || state.ResolvedIn == nil || *state.ResolvedIn != "1.0" || state.RegressedAt != nil {
+		t.Errorf("GetIssueState = %+v, want resolved in 1.0", state)
+	}
+	if !resolved(mine) || resolved(theirs) {
+		t.Error("ResolveIssue must resolve the project's events only")
+	}
+	if state, err := s.GetIssueState(&other, fingerprint); err != nil || state != nil {
+		t.Errorf("GetIssueState of the other project = %+v, %v, want nil", state, err)
+	}
+
+	if err := s.RegressIssue(&project, fingerprint, strPtr("1.1")); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = s.GetIssueState(&project, fingerprint); err != nil || state == nil {
+		t.Fatalf("GetIssueState = %+v, %v", state, err)
+	}
+	if state.Status != models.IssueStatusRegressed || state.RegressedIn == nil || *state.RegressedIn != "1.1" || state.RegressedAt == nil {
+		t.Errorf("GetIssueState = %+v, want regressed in 1.1", state)
+	}
+	if resolved(mine) {
+		t.Error("RegressIssue must reopen the project's events")
+	}
+}
+
+func testSourceMaps(t *testing.T, s storage.Store) {
+	project, other := uuid.New(), uuid.New()
+	release := "storetest-" + uuid.NewString()
+
+	for _, sm := range []*models.SourceMap{
+		{ProjectID: &project, Release: release, Name: "app.js", Size: 1, StorageKey: "old", CreatedAt: now()},
+		{ProjectID: &project, Release: release, Name: "app.js", Size: 2, StorageKey: "mine", CreatedAt: now()},
+		{ProjectID: &other, Release: release, Name: "app.js", Size: 3, StorageKey: "theirs", CreatedAt: now()},
+		{Release: release, Name: "vendor.js", Size: 4, StorageKey: "unscoped", CreatedAt: now()},
+	} {
+		if err := s.SaveSourceMap(sm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		project *uuid.UUID
+		name    string
+		want    string
+	}{
+		{&project, "app.js", "mine"},
+		{&other, "app.js", "theirs"},
+		{nil, "app.js", ""},
+		{nil, "vendor.js", "unscoped"},
+		{&project, "vendor.js", ""},
+	} {
+		sm, err := s.GetSourceMap(tt.project, release, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if sm != nil {
+			got = sm.StorageKey
+		}
+		if got != tt.want {
+			t.Errorf("GetSourceMap(%v, %s) = %q, want %q", tt.project, tt.name, got, tt.want)
+		}
+	}
+
+	maps, err := s.ListSourceMaps(&project, release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(maps) != 1 || maps[0].Size != 2 || maps[0].ProjectID == nil || *maps[0].ProjectID != project {
+		t.Errorf("ListSourceMaps = %+v, want the project's replaced upload only", maps)
+	}
+}
+
+func testSettings(t *testing.T, s storage.Store) {
+	project, err := s.GetProjectIDBySlug("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetProjectIDBySlug("storetest-" + uuid.NewString()); err == nil {
+		t.Error("GetProjectIDBySlug(unknown) succeeded")
+	}
+
+	original, err := s.GetProjectSettings(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := s.UpdateProjectSettings(project, original); err != nil {
+			t.Errorf("failed to restore settings: %v", err)
+		}
+	})
+
+	settings := &models.ProjectSettings{
+		Scrubbing: models.ScrubbingSettings{Action: "hash"},
+		Retention: models.RetentionSettings{Days: 30, Levels: map[string]int{"debug": 3}},
+	}
+	if err := s.UpdateProjectSettings(project, settings); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetProjectSettings(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, settings) {
+		t.Errorf("GetProjectSettings = %+v, want %+v", got, settings)
+	}
+
+	all, err := s.ListProjectSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(all[project], settings) {
+		t.Errorf("ListProjectSettings()[%s] = %+v, want %+v", project, all[project], settings)
+	}
+
+	if err := s.UpdateProjectSettings(uuid.New(), settings); err == nil {
+		t.Error("UpdateProjectSettings of an unknown project succeeded")
+	}
+	if _, err := s.GetProjectSettings(uuid.New()); err == nil {
+		t.Error("GetProjectSettings of an unknown project succeeded")
+	}
+}
+
+func testSecrets(t *testing.T, s storage.Store) {
+	prefix := "storetest-" + uuid.NewString()
+	expired := now().Add(-time.Minute)
+	later := now().Add(time.Hour)
+
+	for _, tt := range []struct {
+		name      string
+		expiresAt *time.Time
+	}{
+		{prefix + "-expired", &expired},
+		{prefix + "-later", &later},
+		{prefix + "-forever", nil},
+	} {
+		value, err := s.GetOrCreateSecret(tt.name, "first", tt.expiresAt)
+		if err != nil || value != "first" {
+			t.Fatalf("GetOrCreateSecret(%s) = %q, %v, want first", tt.name, value, err)
+		}
+		if value, err = s.GetOrCreateSecret(tt.name, "second", tt.expiresAt); err != nil || value != "first" {
+			t.Errorf("GetOrCreateSecret(%s) again = %q, %v, want the stored value", tt.name, value, err)
+		}
+	}
+
+	deleted, err := s.DeleteExpiredSecrets(now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted < 1 {
+		t.Errorf("DeleteExpiredSecrets = %d, want at least the expired one", deleted)
+	}
+
+	var replaced []string
+	for _, name := range []string{prefix + "-expired", prefix + "-later", prefix + "-forever"} {
+		value, err := s.GetOrCreateSecret(name, "second", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value == "second" {
+			replaced = append(replaced, name)
+		}
+	}
+	sort.Strings(replaced)
+	if want := []string{prefix + "-expired"}; !reflect.DeepEqual(replaced, want) {
+		t.Errorf("secrets replaced after DeleteExpiredSecrets = %v, want %v", replaced, want)
+	}
+}
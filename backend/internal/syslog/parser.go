@@ -0,0 +1,246 @@
+package syslog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message is a parsed syslog message in either RFC 5424 or RFC 3164 format.
+type Message struct {
+	Facility       int
+	Severity       int
+	Timestamp      *time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData map[string]map[string]string
+	Message        string
+	Format         string
+}
+
+// Level maps the syslog severity onto the levels used by the errors table.
+func (m *Message) Level() string {
+	switch {
+	case m.Severity <= 3: // emerg, alert, crit, err
+		return "error"
+	case m.Severity == 4:
+		return "warning"
+	case m.Severity <= 6: // notice, info
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+const nilValue = "-"
+
+var rfc3164Layouts = []string{
+	"Jan _2 15:04:05.000",
+	time.Stamp,
+	time.RFC3339,
+}
+
+// Parse detects the format of a raw syslog message and parses it.
+func Parse(raw []byte) (*Message, error) {
+	line := strings.TrimRight(string(raw), "\r\n\x00")
+	if line == "" {
+		return nil, fmt.Errorf("empty message")
+	}
+
+	pri, rest, err := parsePriority(line)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{
+		Facility: pri / 8,
+		Severity: pri % 8,
+	}
+
+	// RFC 5424 messages carry a version number right after the priority.
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ' {
+		if err := parseRFC5424(msg, rest[2:]); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	parseRFC3164(msg, rest)
+	return msg, nil
+}
+
+func parsePriority(line string) (int, string, error) {
+	if line[0] != '<' {
+		return 0, "", fmt.Errorf("missing priority")
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return 0, "", fmt.Errorf("invalid priority")
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, "", fmt.Errorf("invalid priority %q", line[1:end])
+	}
+	return pri, line[end+1:], nil
+}
+
+func parseRFC5424(msg *Message, rest string) error {
+	msg.Format = "rfc5424"
+
+	fields := make([]string, 0, 5)
+	for i := 0; i < 5; i++ {
+		field, remainder, ok := strings.Cut(rest, " ")
+		if !ok && i < 4 {
+			return fmt.Errorf("truncated RFC 5424 header")
+		}
+		fields = append(fields, field)
+		rest = remainder
+	}
+
+	if fields[0] != nilValue {
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q: %w", fields[0], err)
+		}
+		msg.Timestamp = &ts
+	}
+	msg.Hostname = nilToEmpty(fields[1])
+	msg.AppName = nilToEmpty(fields[2])
+	msg.ProcID = nilToEmpty(fields[3])
+	msg.MsgID = nilToEmpty(fields[4])
+
+	sd, rest, err := parseStructuredData(rest)
+	if err != nil {
+		return err
+	}
+	msg.StructuredData = sd
+
+	rest = strings.TrimPrefix(rest, " ")
+	msg.Message = strings.TrimPrefix(rest, "\ufeff")
+	return nil
+}
+
+func parseStructuredData(s string) (map[string]map[string]string, string, error) {
+	if strings.HasPrefix(s, nilValue) {
+		return nil, s[1:], nil
+	}
+	if !strings.HasPrefix(s, "[") {
+		return nil, "", fmt.Errorf("invalid structured data")
+	}
+
+	sd := make(map[string]map[string]string)
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+		end := strings.IndexAny(s, " ]")
+		if end <= 0 {
+			return nil, "", fmt.Errorf("invalid structured data element")
+		}
+		id := s[:end]
+		params := make(map[string]string)
+		s = s[end:]
+
+		for strings.HasPrefix(s, " ") {
+			s = strings.TrimLeft(s, " ")
+			eq := strings.Index(s, "=\"")
+			if eq <= 0 {
+				return nil, "", fmt.Errorf("invalid structured data parameter in %q", id)
+			}
+			name := s[:eq]
+			value, remainder, err := parseParamValue(s[eq+2:])
+			if err != nil {
+				return nil, "", err
+			}
+			params[name] = value
+			s = remainder
+		}
+
+		if !strings.HasPrefix(s, "]") {
+			return nil, "", fmt.Errorf("unterminated structured data element %q", id)
+		}
+		s = s[1:]
+		sd[id] = params
+	}
+	return sd, s, nil
+}
+
+// parseParamValue reads a quoted SD-PARAM value, unescaping '"', '\' and ']'.
+func parseParamValue(s string) (string, string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+				i++
+			}
+			b.WriteByte(s[i])
+		case '"':
+			return b.String(), s[i+1:], nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", fmt.Errorf("unterminated structured data value")
+}
+
+// parseRFC3164 is deliberately lenient: BSD syslog senders disagree on almost
+// every detail, so anything that doesn't fit ends up in the message body.
+func parseRFC3164(msg *Message, rest string) {
+	msg.Format = "rfc3164"
+
+	for _, layout := range rfc3164Layouts {
+		if len(rest) < len(layout) {
+			continue
+		}
+		candidate := rest[:len(layout)]
+		if layout == time.RFC3339 {
+			candidate, _, _ = strings.Cut(rest, " ")
+		}
+		ts, err := time.Parse(layout, candidate)
+		if err != nil {
+			continue
+		}
+		if ts.Year() == 0 {
+			now := time.Now()
+			ts = ts.AddDate(now.Year(), 0, 0)
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+		}
+		msg.Timestamp = &ts
+		rest = strings.TrimPrefix(rest[len(candidate):], " ")
+
+		if host, remainder, ok := strings.Cut(rest, " "); ok && !strings.HasSuffix(host, ":") {
+			msg.Hostname = host
+			rest = remainder
+		}
+		break
+	}
+
+	if tag, remainder, ok := strings.Cut(rest, ": "); ok && isTag(tag) {
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			msg.ProcID = tag[open+1 : len(tag)-1]
+			tag = tag[:open]
+		}
+		msg.AppName = tag
+		rest = remainder
+	}
+
+	msg.Message = rest
+}
+
+func isTag(s string) bool {
+	if s == "" || len(s) > 48 {
+		return false
+	}
+	return !strings.ContainsAny(s, " \t")
+}
+
+func nilToEmpty(s string) string {
+	if s == nilValue {
+		return ""
+	}
+	return s
+}
@@ -0,0 +1,229 @@
+package syslog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseRFC5424(t *testing.T) {
+	ts := time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)
+
+	tests := []struct {
+		name string
+		raw  string
+		want Message
+	}{
+		{
+			name: "full header",
+			raw:  `<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su 1234 ID47 - 'su root' failed`,
+			want: Message{
+				Facility: 4, Severity: 2, Timestamp: &ts, Hostname: "mymachine.example.com",
+				AppName: "su", ProcID: "1234", MsgID: "ID47", Message: "'su root' failed", Format: "rfc5424",
+			},
+		},
+		{
+			name: "nil values",
+			raw:  `<13>1 - - - - - - hello`,
+			want: Message{Facility: 1, Severity: 5, Message: "hello", Format: "rfc5424"},
+		},
+		{
+			name: "byte order mark",
+			raw:  "<11>1 - host app - - - \ufeffcaf\u00e9 down",
+			want: Message{Facility: 1, Severity: 3, Hostname: "host", AppName: "app", Message: "caf\u00e9 down", Format: "rfc5424"},
+		},
+		{
+			name: "structured data",
+			raw:  `<165>1 2003-10-11T22:14:15.003Z host app - - [exampleSDID@32473 iut="3" eventSource="Application"][origin ip="192.0.2.1"] started`,
+			want: Message{
+				Facility: 20, Severity: 5, Timestamp: &ts, Hostname: "host", AppName: "app", Format: "rfc5424",
+				StructuredData: map[string]map[string]string{
+					"exampleSDID@32473": {"iut": "3", "eventSource": "Application"},
+					"origin":            {"ip": "192.0.2.1"},
+				},
+				Message: "started",
+			},
+		},
+		{
+			name: "escaped parameter values",
+			raw:  `<14>1 - host app - - [ex@1 quote="say \"hi\"" slash="C:\\temp" bracket="a\]b" other="\n"]`,
+			want: Message{
+				Facility: 1, Severity: 6, Hostname: "host", AppName: "app", Format: "rfc5424",
+				StructuredData: map[string]map[string]string{
+					// only '"', '\' and ']' are escapes; other backslashes are kept
+					"ex@1": {"quote": `say "hi"`, "slash": `C:\temp`, "bracket": "a]b", "other": `\n`},
+				},
+			},
+		},
+		{
+			name: "element without parameters",
+			raw:  `<14>1 - host app - - [empty@1] msg`,
+			want: Message{
+				Facility: 1, Severity: 6, Hostname: "host", AppName: "app", Format: "rfc5424",
+				StructuredData: map[string]map[string]string{"empty@1": {}}, Message: "msg",
+			},
+		},
+		{
+			name: "trailing newline and NUL",
+			raw:  "<14>1 - host app - - - msg\r\n\x00",
+			want: Message{Facility: 1, Severity: 6, Hostname: "host", AppName: "app", Message: "msg", Format: "rfc5424"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.raw))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"empty", ""},
+		{"missing priority", "hello"},
+		{"priority out of range", "<192>1 - - - - - - x"},
+		{"priority not a number", "<1a>hello"},
+		{"truncated header", "<14>1 2003-10-11T22:14:15Z host"},
+		{"bad timestamp", "<14>1 yesterday host app - - - x"},
+		{"bad structured data", "<14>1 - host app - - oops"},
+		{"unterminated element", `<14>1 - host app - - [ex@1 a="b"`},
+		{"unterminated value", `<14>1 - host app - - [ex@1 a="b]`},
+		{"parameter without value", `<14>1 - host app - - [ex@1 a]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if msg, err := Parse([]byte(tt.raw)); err == nil {
+				t.Errorf("Parse(%q) = %+v, want error", tt.raw, msg)
+			}
+		})
+	}
+}
+
+func TestParseRFC3164(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    Message
+		stamp   string // month, day and time of the timestamp, if any
+		rfc3339 *time.Time
+	}{
+		{
+			name:  "classic",
+			raw:   "<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed",
+			want:  Message{Facility: 4, Severity: 2, Hostname: "mymachine", AppName: "su", ProcID: "230", Message: "'su root' failed", Format: "rfc3164"},
+			stamp: "Oct 11 22:14:15.000",
+		},
+		{
+			name:  "space padded day",
+			raw:   "<13>Feb  5 01:02:03 host cron: job done",
+			want:  Message{Facility: 1, Severity: 5, Hostname: "host", AppName: "cron", Message: "job done", Format: "rfc3164"},
+			stamp: "Feb  5 01:02:03.000",
+		},
+		{
+			name:  "milliseconds",
+			raw:   "<13>Feb  5 01:02:03.250 host app: x",
+			want:  Message{Facility: 1, Severity: 5, Hostname: "host", AppName: "app", Message: "x", Format: "rfc3164"},
+			stamp: "Feb  5 01:02:03.250",
+		},
+		{
+			name:  "no hostname",
+			raw:   "<13>Feb  5 01:02:03 app: x",
+			want:  Message{Facility: 1, Severity: 5, AppName: "app", Message: "x", Format: "rfc3164"},
+			stamp: "Feb  5 01:02:03.000",
+		},
+		{
+			name:    "RFC 3339 timestamp",
+			raw:     "<13>2024-03-01T10:00:00Z host app: x",
+			want:    Message{Facility: 1, Severity: 5, Hostname: "host", AppName: "app", Message: "x", Format: "rfc3164"},
+			rfc3339: ptr(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)),
+		},
+		{
+			name: "no timestamp",
+			raw:  "<11>kernel: oops",
+			want: Message{Facility: 1, Severity: 3, AppName: "kernel", Message: "oops", Format: "rfc3164"},
+		},
+		{
+			name: "body only",
+			raw:  "<11>something went wrong: badly",
+			want: Message{Facility: 1, Severity: 3, Message: "something went wrong: badly", Format: "rfc3164"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.raw))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			ts := got.Timestamp
+			got.Timestamp = nil
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse = %+v, want %+v", *got, tt.want)
+			}
+
+			switch {
+			case tt.rfc3339 != nil:
+				if ts == nil || !ts.Equal(*tt.rfc3339) {
+					t.Errorf("Timestamp = %v, want %v", ts, tt.rfc3339)
+				}
+			case tt.stamp != "":
+				if ts == nil {
+					t.Fatal("Timestamp = nil")
+				}
+				if s := ts.Format("Jan _2 15:04:05.000"); s != tt.stamp {
+					t.Errorf("Timestamp = %s, want %s", s, tt.stamp)
+				}
+			default:
+				if ts != nil {
+					t.Errorf("Timestamp = %v, want nil", ts)
+				}
+			}
+		})
+	}
+}
+
+// RFC 3164 timestamps have no year: the current one is assumed, or the
+// previous one for dates more than a day ahead, such as December messages
+// read in January.
+func TestParseRFC3164MissingYear(t *testing.T) {
+	now := time.Now().UTC()
+	for _, offset := range []time.Duration{0, -30 * 24 * time.Hour, 2 * 24 * time.Hour, 200 * 24 * time.Hour} {
+		sent := now.Add(offset).Truncate(time.Second)
+		msg, err := Parse([]byte("<13>" + sent.Format(time.Stamp) + " host app: x"))
+		if err != nil {
+			t.Fatalf("Parse: %v", err)
+		}
+		if msg.Timestamp.After(now.Add(24 * time.Hour)) {
+			t.Errorf("timestamp %v for %v is in the future", msg.Timestamp, sent)
+		}
+		if now.Sub(*msg.Timestamp) > 366*24*time.Hour {
+			t.Errorf("timestamp %v for %v is over a year old", msg.Timestamp, sent)
+		}
+		if msg.Timestamp.Format(time.Stamp) != sent.Format(time.Stamp) {
+			t.Errorf("timestamp %v, want month, day and time of %v", msg.Timestamp, sent)
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	want := []string{"error", "error", "error", "error", "warning", "info", "info", "debug"}
+	for severity, level := range want {
+		if got := (&Message{Severity: severity}).Level(); got != level {
+			t.Errorf("severity %d: Level() = %q, want %q", severity, got, level)
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,282 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"error-logs/internal/models"
+)
+
+const (
+	maxMessageSize = 64 * 1024
+	tcpIdleTimeout = 5 * time.Minute
+)
+
+// Ingester accepts parsed events; it is satisfied by services.ErrorService so
+// syslog traffic goes through the same queue as the HTTP API.
+type Ingester interface {
+	CreateError(ctx context.Context, req *models.CreateErrorRequest, userAgent, ipAddress string) (*models.Error, error)
+}
+
+type Server struct {
+	ingester    Ingester
+	maxSeverity int
+
+	mu      sync.Mutex
+	closers map[io.Closer]struct{}
+	wg      sync.WaitGroup
+}
+
+// NewServer creates a syslog server that forwards messages at or above
+// minLevel (error, warning, info or debug).
+func NewServer(ingester Ingester, minLevel string) *Server {
+	return &Server{
+		ingester:    ingester,
+		maxSeverity: severityForLevel(minLevel),
+		closers:     make(map[io.Closer]struct{}),
+	}
+}
+
+func severityForLevel(level string) int {
+	switch level {
+	case "error":
+		return 3
+	case "info":
+		return 6
+	case "debug":
+		return 7
+	default:
+		return 4
+	}
+}
+
+func (s *Server) ListenUDP(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on UDP %s: %w", addr, err)
+	}
+	s.track(conn)
+
+	log.Printf("Syslog UDP listener started on %s", addr)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		buf := make([]byte, maxMessageSize)
+		for {
+			n, remote, err := conn.ReadFrom(buf)
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("Syslog UDP read error: %v", err)
+				}
+				return
+			}
+			s.handle(buf[:n], hostOf(remote))
+		}
+	}()
+	return nil
+}
+
+func (s *Server) ListenTCP(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on TCP %s: %w", addr, err)
+	}
+	log.Printf("Syslog TCP listener started on %s", addr)
+	s.serve(ln)
+	return nil
+}
+
+func (s *Server) ListenTLS(addr, certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load syslog TLS certificate: %w", err)
+	}
+	ln, err := tls.Listen("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to listen on TLS %s: %w", addr, err)
+	}
+	log.Printf("Syslog TLS listener started on %s", addr)
+	s.serve(ln)
+	return nil
+}
+
+// Close stops all listeners and waits for open connections to drain.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	for c := range s.closers {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) track(c io.Closer) {
+	s.mu.Lock()
+	s.closers[c] = struct{}{}
+	s.mu.Unlock()
+}
+
+func (s *Server) untrack(c io.Closer) {
+	s.mu.Lock()
+	delete(s.closers, c)
+	s.mu.Unlock()
+}
+
+func (s *Server) serve(ln net.Listener) {
+	s.track(ln)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Printf("Syslog accept error: %v", err)
+				}
+				return
+			}
+			s.track(conn)
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer s.untrack(conn)
+				defer conn.Close()
+				s.readStream(conn)
+			}()
+		}
+	}()
+}
+
+// readStream handles both octet-counted (RFC 6587 3.4.1) and newline
+// delimited framing, detected per frame.
+func (s *Server) readStream(conn net.Conn) {
+	remote := hostOf(conn.RemoteAddr())
+	r := bufio.NewReaderSize(conn, maxMessageSize)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(tcpIdleTimeout))
+
+		first, err := r.Peek(1)
+		if err != nil {
+			return
+		}
+
+		var frame []byte
+		if first[0] >= '1' && first[0] <= '9' {
+			frame, err = readOctetCounted(r)
+		} else {
+			frame, err = r.ReadSlice('\n')
+			if errors.Is(err, io.EOF) && len(frame) > 0 {
+				err = nil
+			}
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Syslog stream error from %s: %v", remote, err)
+			}
+			return
+		}
+		s.handle(frame, remote)
+	}
+}
+
+func readOctetCounted(r *bufio.Reader) ([]byte, error) {
+	lenStr, err := r.ReadString(' ')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(lenStr[:len(lenStr)-1])
+	if err != nil || n <= 0 || n > maxMessageSize {
+		return nil, fmt.Errorf("invalid frame length %q", lenStr)
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (s *Server) handle(raw []byte, remote string) {
+	msg, err := Parse(raw)
+	if err != nil {
+		log.Printf("Syslog parse error from %s: %v", remote, err)
+		return
+	}
+	if msg.Severity > s.maxSeverity || msg.Message == "" {
+		return
+	}
+
+	req := toCreateRequest(msg)
+	if _, err := s.ingester.CreateError(context.Background(), req, "", remote); err != nil {
+		log.Printf("Failed to ingest syslog message from %s: %v", remote, err)
+	}
+}
+
+func toCreateRequest(msg *Message) *models.CreateErrorRequest {
+	meta := map[string]interface{}{
+		"format":   msg.Format,
+		"facility": msg.Facility,
+		"severity": msg.Severity,
+	}
+	if msg.Hostname != "" {
+		meta["hostname"] = msg.Hostname
+	}
+	if msg.ProcID != "" {
+		meta["procid"] = msg.ProcID
+	}
+	if msg.MsgID != "" {
+		meta["msgid"] = msg.MsgID
+	}
+	// Structured data is nested so an element can't replace the fields above
+	// or other context keys, whatever its ID. It is stored with the generic
+	// JSON types the scrubber walks, as params may carry personal data.
+	if len(msg.StructuredData) > 0 {
+		sd := make(map[string]interface{}, len(msg.StructuredData))
+		for id, params := range msg.StructuredData {
+			element := make(map[string]interface{}, len(params))
+			for name, value := range params {
+				element[name] = value
+			}
+			sd[id] = element
+		}
+		meta["structured_data"] = sd
+	}
+
+	source := msg.AppName
+	if source == "" {
+		source = "syslog"
+	}
+
+	return &models.CreateErrorRequest{
+		Level:     msg.Level(),
+		Message:   msg.Message,
+		Context:   map[string]interface{}{"syslog": meta},
+		Source:    source,
+		Timestamp: msg.Timestamp,
+	}
+}
+
+func hostOf(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
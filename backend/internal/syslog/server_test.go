@@ -0,0 +1,95 @@
+package syslog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"error-logs/internal/models"
+	"error-logs/internal/scrub"
+)
+
+func TestToCreateRequest(t *testing.T) {
+	ts := time.Date(2003, 10, 11, 22, 14, 15, 0, time.UTC)
+	msg := &Message{
+		Facility:  4,
+		Severity:  2,
+		Timestamp: &ts,
+		Hostname:  "host",
+		AppName:   "su",
+		ProcID:    "1234",
+		StructuredData: map[string]map[string]string{
+			// an element named like the meta key must not replace it
+			"syslog": {"format": "spoofed"},
+			"origin": {"ip": "192.0.2.1"},
+		},
+		Message: "failed",
+		Format:  "rfc5424",
+	}
+
+	req := toCreateRequest(msg)
+
+	if req.Timestamp == nil || !req.Timestamp.Equal(ts) {
+		t.Errorf("Timestamp = %v, want %v", req.Timestamp, ts)
+	}
+	if req.Level != "error" || req.Source != "su" || req.Message != "failed" {
+		t.Errorf("Level, Source, Message = %q, %q, %q", req.Level, req.Source, req.Message)
+	}
+	if len(req.Context) != 1 {
+		t.Errorf("Context has keys besides syslog: %v", req.Context)
+	}
+	want := map[string]interface{}{
+		"format":   "rfc5424",
+		"facility": 4,
+		"severity": 2,
+		"hostname": "host",
+		"procid":   "1234",
+		"structured_data": map[string]interface{}{
+			"syslog": map[string]interface{}{"format": "spoofed"},
+			"origin": map[string]interface{}{"ip": "192.0.2.1"},
+		},
+	}
+	if !reflect.DeepEqual(req.Context["syslog"], want) {
+		t.Errorf("Context[syslog] = %v, want %v", req.Context["syslog"], want)
+	}
+}
+
+// Structured data params go through PII scrubbing like any other context.
+func TestToCreateRequestScrubbed(t *testing.T) {
+	req := toCreateRequest(&Message{
+		Severity: 3,
+		StructuredData: map[string]map[string]string{
+			"login@32473": {"password": "hunter2", "email": "a@b.com", "attempt": "3"},
+		},
+		Message: "login failed",
+		Format:  "rfc5424",
+	})
+	s, err := scrub.New(models.ScrubbingSettings{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Request(req)
+
+	sd := req.Context["syslog"].(map[string]interface{})["structured_data"].(map[string]interface{})
+	params := sd["login@32473"].(map[string]interface{})
+	if params["password"] == "hunter2" || params["email"] == "a@b.com" {
+		t.Errorf("structured data not scrubbed: %v", params)
+	}
+	if params["attempt"] != "3" {
+		t.Errorf("attempt = %v, want 3 left alone", params["attempt"])
+	}
+}
+
+func TestToCreateRequestDefaults(t *testing.T) {
+	req := toCreateRequest(&Message{Severity: 6, Message: "x", Format: "rfc3164"})
+	if req.Source != "syslog" {
+		t.Errorf("Source = %q, want syslog", req.Source)
+	}
+	if req.Timestamp != nil {
+		t.Errorf("Timestamp = %v, want nil", req.Timestamp)
+	}
+	meta := req.Context["syslog"].(map[string]interface{})
+	if _, ok := meta["structured_data"]; ok {
+		t.Errorf("structured_data set without structured data: %v", meta)
+	}
+}
@@ -0,0 +1,58 @@
+// Package tracecontext parses W3C Trace Context traceparent headers
+// (https://www.w3.org/TR/trace-context/) and validates the IDs in them.
+package tracecontext
+
+import (
+	"strings"
+)
+
+const (
+	Header = "traceparent"
+
+	traceIDLength = 32
+	spanIDLength  = 16
+)
+
+// Parse extracts the trace and parent span IDs from a traceparent header
+// value. Versions after 00 are accepted as long as they start with the
+// version 00 fields, as the spec requires.
+func Parse(header string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return "", "", false
+	}
+	version := parts[0]
+	if len(version) != 2 || !isHex(version) || version == "ff" {
+		return "", "", false
+	}
+	if version == "00" && len(parts) != 4 {
+		return "", "", false
+	}
+	if len(parts[3]) != 2 || !isHex(parts[3]) {
+		return "", "", false
+	}
+	if !ValidTraceID(parts[1]) || !ValidSpanID(parts[2]) {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// ValidTraceID reports whether id is 32 lowercase hex digits, not all zero.
+func ValidTraceID(id string) bool {
+	return len(id) == traceIDLength && isHex(id) && strings.Trim(id, "0") != ""
+}
+
+// ValidSpanID reports whether id is 16 lowercase hex digits, not all zero.
+func ValidSpanID(id string) bool {
+	return len(id) == spanIDLength && isHex(id) && strings.Trim(id, "0") != ""
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
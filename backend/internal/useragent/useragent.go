@@ -0,0 +1,130 @@
+// Package useragent extracts browser, OS and device information from
+// User-Agent strings. It recognises the common browsers and platforms rather
+// than every client ever shipped; anything else is reported as "Other".
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+
+	Other = "Other"
+)
+
+type Info struct {
+	BrowserName    string
+	BrowserVersion string
+	OSName         string
+	OSVersion      string
+	DeviceType     string
+	Bot            bool
+}
+
+type pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Browsers are matched in order; embedders and Chromium forks come before
+// Chrome and Safari because they repeat those tokens.
+var browsers = []pattern{
+	{"Edge", regexp.MustCompile(`(?:Edg|Edge|EdgA|EdgiOS)/([\d.]+)`)},
+	{"Opera", regexp.MustCompile(`(?:OPR|Opera|OPiOS)/([\d.]+)`)},
+	{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/([\d.]+)`)},
+	{"Yandex", regexp.MustCompile(`YaBrowser/([\d.]+)`)},
+	{"Vivaldi", regexp.MustCompile(`Vivaldi/([\d.]+)`)},
+	{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/([\d.]+)`)},
+	{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/([\d.]+)`)},
+	{"Safari", regexp.MustCompile(`Version/([\d.]+).*Safari/`)},
+	{"Internet Explorer", regexp.MustCompile(`(?:MSIE |Trident/.*rv:)([\d.]+)`)},
+}
+
+var (
+	botRe = regexp.MustCompile(`(?i)bot\b|crawler|spider|slurp|headless|lighthouse|pingdom|uptime|monitor|preview|curl/|wget/|python-requests|go-http-client|okhttp|axios/|node-fetch|java/|libwww`)
+
+	windowsRe  = regexp.MustCompile(`Windows NT ([\d.]+)`)
+	iosRe      = regexp.MustCompile(`(?:iPhone|CPU) OS ([\d_]+)`)
+	macRe      = regexp.MustCompile(`Mac OS X ([\d_.]+)`)
+	androidRe  = regexp.MustCompile(`Android ([\d.]+)`)
+	chromeOSRe = regexp.MustCompile(`CrOS \S+ ([\d.]+)`)
+)
+
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.1":  "XP",
+}
+
+// Parse never fails; unknown parts are "Other" or empty.
+func Parse(ua string) Info {
+	info := Info{BrowserName: Other, OSName: Other, DeviceType: DeviceDesktop}
+	if ua == "" {
+		return info
+	}
+
+	if botRe.MatchString(ua) {
+		info.Bot = true
+		info.DeviceType = DeviceBot
+	}
+
+	for _, b := range browsers {
+		if m := b.re.FindStringSubmatch(ua); m != nil {
+			info.BrowserName = b.name
+			info.BrowserVersion = m[1]
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(ua, "iPad"):
+		info.OSName = "iPadOS"
+		info.OSVersion = submatch(iosRe, ua)
+	case strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPod"):
+		info.OSName = "iOS"
+		info.OSVersion = submatch(iosRe, ua)
+	case strings.Contains(ua, "Android"):
+		info.OSName = "Android"
+		info.OSVersion = submatch(androidRe, ua)
+	case strings.Contains(ua, "Windows"):
+		info.OSName = "Windows"
+		if v, ok := windowsVersions[submatch(windowsRe, ua)]; ok {
+			info.OSVersion = v
+		}
+	case strings.Contains(ua, "CrOS"):
+		info.OSName = "Chrome OS"
+		info.OSVersion = submatch(chromeOSRe, ua)
+	case strings.Contains(ua, "Mac OS X"):
+		info.OSName = "macOS"
+		info.OSVersion = submatch(macRe, ua)
+	case strings.Contains(ua, "Linux"):
+		info.OSName = "Linux"
+	}
+	info.OSVersion = strings.ReplaceAll(info.OSVersion, "_", ".")
+
+	if !info.Bot {
+		switch {
+		case info.OSName == "iPadOS" || strings.Contains(ua, "Tablet") ||
+			(info.OSName == "Android" && !strings.Contains(ua, "Mobile")):
+			info.DeviceType = DeviceTablet
+		case info.OSName == "iOS" || strings.Contains(ua, "Mobi"):
+			info.DeviceType = DeviceMobile
+		}
+	}
+	return info
+}
+
+func submatch(re *regexp.Regexp, s string) string {
+	if m := re.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+	return ""
+}
@@ -3,23 +3,39 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 
+	"error-logs/internal/archive"
+	"error-logs/internal/blob"
+	"error-logs/internal/clientip"
 	"error-logs/internal/config"
 	"error-logs/internal/database"
+	"error-logs/internal/geoip"
 	"error-logs/internal/handlers"
+	"error-logs/internal/models"
+	"error-logs/internal/notify"
 	"error-logs/internal/redis"
+	"error-logs/internal/relay"
 	"error-logs/internal/services"
+	"error-logs/internal/spool"
+	"error-logs/internal/sqlite"
+	"error-logs/internal/storage"
+	"error-logs/internal/syslog"
 )
 
 func main() {
@@ -31,11 +47,21 @@ func main() {
 	// Initialize configuration
 	cfg := config.Load()
 
-	// Initialize database
-	db, err := database.Connect(cfg.DatabaseURL)
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "archive" {
+		runArchive(cfg, os.Args[2:])
+		return
 	}
+	if cfg.Mode == "relay" {
+		runRelay(cfg)
+		return
+	}
+
+	// Initialize database
+	db := openStore(cfg)
 	defer db.Close()
 
 	redisClient, err := redis.NewClient(cfg.RedisURL)
@@ -44,13 +70,113 @@ func main() {
 	}
 	defer redisClient.Close()
 
-	redisClient.FlushAll(context.Background())
+	// Cached lists and stats may be stale after a restart. Only they are
+	// cleared: the ingest queue and the affected-user HyperLogLogs that
+	// haven't been rolled up to the database yet live in Redis too.
+	if err := redisClient.InvalidateAllCache(context.Background()); err != nil {
+		log.Printf("Failed to clear cache on startup: %v", err)
+	}
+
+	geo, err := geoip.Open(cfg.GeoIPDBPath, cfg.GeoIPASNDBPath)
+	if err != nil {
+		log.Fatalf("Failed to open GeoIP database: %v", err)
+	}
+	go geo.Watch(context.Background(), 30*time.Second)
 
-	errorService := services.NewErrorService(db, redisClient)
+	errorService := services.NewErrorService(db, redisClient, geo, notify.New(cfg.NotifyWebhookURL), newBlobStore(cfg), services.AttachmentConfig{
+		MaxSize:      int64(cfg.AttachmentMaxSizeMB) << 20,
+		ProjectQuota: int64(cfg.AttachmentQuotaMB) << 20,
+		Retention:    time.Duration(cfg.AttachmentRetentionDays) * 24 * time.Hour,
+		ContentTypes: cfg.AttachmentContentTypes,
+	}, services.RetentionConfig{
+		Defaults: models.RetentionSettings{
+			Days:         cfg.RetentionDays,
+			Levels:       cfg.RetentionLevels,
+			ResolvedDays: cfg.RetentionResolvedDays,
+		},
+		Archive: newArchiveStore(cfg),
+	})
 	errorHandler := handlers.NewErrorHandler(errorService)
+	ingestHandler := handlers.NewIngestHandler(errorService, newIPResolver(cfg))
+
+	r := newRouter()
+
+	// API routes
+	r.Route("/api", func(r chi.Router) {
+		// Browser reports can't carry custom headers, so an ingest key is in the query string
+		r.With(handlers.QueryAPIKeyMiddleware(db)).Post("/reports", ingestHandler.CreateReports)
+
+		// Endpoints that only accept events take ingest keys too
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.IngestAPIKeyMiddleware(db))
+
+			r.Post("/errors", ingestHandler.CreateError)
+			r.Post("/errors/batch", ingestHandler.CreateErrors)
+			r.Post("/feedback", errorHandler.CreateFeedback)
+		})
+
+		r.Group(func(r chi.Router) {
+			// API Key authentication middleware
+			r.Use(handlers.APIKeyMiddleware(db))
+
+			// Error endpoints
+			r.Get("/errors", errorHandler.GetErrors)
+			r.Get("/errors/{id}", errorHandler.GetError)
+			r.Put("/errors/{id}/resolve", errorHandler.ResolveError)
+			r.Get("/errors/{id}/releases", errorHandler.GetIssueReleases)
+			r.Get("/errors/{id}/tags", errorHandler.GetTagDistribution)
+			r.Get("/errors/{id}/users", errorHandler.GetIssueUsers)
+			r.Post("/errors/{id}/attachments", errorHandler.CreateAttachments)
+			r.Get("/errors/{id}/attachments", errorHandler.ListAttachments)
+			r.Get("/attachments/{id}", errorHandler.DownloadAttachment)
+			r.Post("/releases/{release}/sourcemaps", errorHandler.UploadSourceMaps)
+			r.Get("/releases/{release}/sourcemaps", errorHandler.ListSourceMaps)
+			r.Get("/traces/{traceID}", errorHandler.GetTraceErrors)
+			r.Delete("/errors/{id}", errorHandler.DeleteError)
+
+			// Stats endpoint
+			r.Get("/stats", errorHandler.GetStats)
+			r.Get("/analytics/breakdown", errorHandler.GetBreakdown)
+			r.Get("/analytics/trends", errorHandler.GetTrends)
+
+			// Deploys
+			r.Post("/deploys", errorHandler.CreateDeploy)
+			r.Get("/deploys", errorHandler.ListDeploys)
+			r.Get("/deploys/{id}/issues", errorHandler.GetDeployIssues)
+
+			// Project settings
+			r.Get("/settings/project", errorHandler.GetProjectSettings)
+			r.Put("/settings/project", errorHandler.UpdateProjectSettings)
+			r.Get("/settings/retention", errorHandler.GetRetention)
+		})
+	})
+
+	// Start background worker for processing Redis queue
+	go errorService.StartQueueProcessor(context.Background())
+	go errorService.StartIPRetention(context.Background())
+	go errorService.StartUserRollup(context.Background())
+	go errorService.StartAttachmentRetention(context.Background())
+	go errorService.StartPartitionMaintenance(context.Background(), partitionConfig(cfg))
+	go errorService.StartRetention(context.Background())
+
+	syslogServer := startSyslog(cfg, errorService)
+	defer syslogServer.Close()
+
+	// Start server
+	server := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: r,
+	}
+
+	serve(server)
+}
 
+// newRouter sets up the middleware and health check shared by server and
+// relay mode.
+func newRouter() *chi.Mux {
 	r := chi.NewRouter()
 
+	r.Use(handlers.RedactQueryKey)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
@@ -74,32 +200,120 @@ func main() {
 		})
 	})
 
-	// API routes
-	r.Route("/api", func(r chi.Router) {
-		// API Key authentication middleware
-		r.Use(handlers.APIKeyMiddleware(db))
-
-		// Error endpoints
-		r.Post("/errors", errorHandler.CreateError)
-		r.Get("/errors", errorHandler.GetErrors)
-		r.Get("/errors/{id}", errorHandler.GetError)
-		r.Put("/errors/{id}/resolve", errorHandler.ResolveError)
-		r.Delete("/errors/{id}", errorHandler.DeleteError)
-
-		// Stats endpoint
-		r.Get("/stats", errorHandler.GetStats)
-	})
+	return r
+}
 
-	// Start background worker for processing Redis queue
-	go errorService.StartQueueProcessor(context.Background())
+// newIPResolver builds the client IP resolver from TRUSTED_PROXIES and
+// TRUSTED_RELAYS. Anything that keys on the client address must use it, so a
+// spoofed forwarding header can't be used to dodge per-client limits.
+func newIPResolver(cfg *config.Config) *clientip.Resolver {
+	ips, err := clientip.New(cfg.TrustedProxies, cfg.TrustedRelays)
+	if err != nil {
+		log.Fatalf("Invalid client IP settings: %v", err)
+	}
+	return ips
+}
 
-	// Start server
-	server := &http.Server{
-		Addr:    ":" + cfg.Port,
-		Handler: r,
+// openStore connects to the database in DATABASE_URL: Postgres, or a SQLite
+// file for a "sqlite:" URL, which is migrated whenever it is opened.
+func openStore(cfg *config.Config) storage.Store {
+	if path, ok := sqlitePath(cfg.DatabaseURL); ok {
+		db, err := sqlite.Open(path)
+		if err != nil {
+			log.Fatalf("Failed to open SQLite database: %v", err)
+		}
+		return db
+	}
+
+	db, err := database.Connect(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	if cfg.AutoMigrate {
+		if _, err := db.MigrateUp(context.Background()); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+	return db
+}
+
+// sqlitePath returns the file of a sqlite:path or sqlite://path URL.
+func sqlitePath(databaseURL string) (string, bool) {
+	path, ok := strings.CutPrefix(databaseURL, "sqlite:")
+	return strings.TrimPrefix(path, "//"), ok
+}
+
+// newBlobStore opens the file storage selected by BLOB_STORAGE.
+func newBlobStore(cfg *config.Config) blob.Store {
+	return openBlobStore(cfg, "BLOB_STORAGE", cfg.BlobStorage, cfg.BlobDir, cfg.S3Bucket, cfg.S3Prefix)
+}
+
+// newArchiveStore opens the storage selected by ARCHIVE_STORAGE, or returns
+// nil when retention deletes without archiving.
+func newArchiveStore(cfg *config.Config) blob.Store {
+	if cfg.ArchiveStorage == "none" {
+		return nil
+	}
+	return openBlobStore(cfg, "ARCHIVE_STORAGE", cfg.ArchiveStorage, cfg.ArchiveDir, cfg.ArchiveS3Bucket, cfg.ArchiveS3Prefix)
+}
+
+// openBlobStore opens a filesystem or S3 store; setting names the variable
+// that chose it, for errors.
+func openBlobStore(cfg *config.Config, setting, storage, dir, bucket, prefix string) blob.Store {
+	switch storage {
+	case "filesystem":
+		store, err := blob.NewFileStore(dir)
+		if err != nil {
+			log.Fatalf("Failed to open %s storage: %v", setting, err)
+		}
+		return store
+	case "s3":
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		store, err := blob.NewS3Store(ctx, blob.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    bucket,
+			Prefix:    prefix,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+		})
+		if err != nil {
+			log.Fatalf("Failed to open %s storage: %v", setting, err)
+		}
+		return store
+	}
+	log.Fatalf("Unknown %s %q (use filesystem or s3)", setting, storage)
+	return nil
+}
+
+// startSyslog starts the optional syslog listeners for devices that can't
+// speak HTTP.
+func startSyslog(cfg *config.Config, ingester syslog.Ingester) *syslog.Server {
+	syslogServer := syslog.NewServer(ingester, cfg.SyslogMinLevel)
+
+	if cfg.SyslogUDPAddr != "" {
+		if err := syslogServer.ListenUDP(cfg.SyslogUDPAddr); err != nil {
+			log.Fatalf("Failed to start syslog listener: %v", err)
+		}
+	}
+	if cfg.SyslogTCPAddr != "" {
+		if err := syslogServer.ListenTCP(cfg.SyslogTCPAddr); err != nil {
+			log.Fatalf("Failed to start syslog listener: %v", err)
+		}
+	}
+	if cfg.SyslogTLSAddr != "" {
+		if err := syslogServer.ListenTLS(cfg.SyslogTLSAddr, cfg.SyslogTLSCertFile, cfg.SyslogTLSKeyFile); err != nil {
+			log.Fatalf("Failed to start syslog listener: %v", err)
+		}
 	}
+	return syslogServer
+}
 
-	// Graceful shutdown
+// serve runs the HTTP server until SIGINT or SIGTERM, then shuts it down
+// gracefully.
+func serve(server *http.Server) {
 	go func() {
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -111,8 +325,222 @@ func main() {
 		server.Shutdown(ctx)
 	}()
 
-	log.Printf("Server starting on port %s", cfg.Port)
+	log.Printf("Server starting on %s", server.Addr)
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+func partitionConfig(cfg *config.Config) services.PartitionConfig {
+	if cfg.PartitionInterval != database.PartitionDay && cfg.PartitionInterval != database.PartitionWeek {
+		log.Fatalf("Unknown ERRORS_PARTITION_INTERVAL %q (use day or week)", cfg.PartitionInterval)
+	}
+	return services.PartitionConfig{
+		Interval:  cfg.PartitionInterval,
+		Ahead:     cfg.PartitionsAhead,
+		Retention: time.Duration(cfg.PartitionRetentionDays) * 24 * time.Hour,
+	}
+}
+
+// runMigrate handles "migrate up", "migrate down [steps]" and
+// "migrate status".
+func runMigrate(cfg *config.Config, args []string) {
+	usage := "Usage: migrate up | down [steps] | status"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	if _, ok := sqlitePath(cfg.DatabaseURL); ok {
+		log.Fatal("migrate manages Postgres; SQLite databases are migrated when the server opens them")
+	}
+
+	db, err := database.Connect(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx)
+		if err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+		log.Printf("Applied %d migration(s)", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal(usage)
+			}
+		}
+		reverted, err := db.MigrateDown(ctx, steps)
+		if err != nil {
+			log.Fatalf("Failed to revert migrations: %v", err)
+		}
+		log.Printf("Reverted %d migration(s)", reverted)
+	case "status":
+		statuses, err := db.MigrationStatus(ctx)
+		if err != nil {
+			log.Fatalf("Failed to get migration status: %v", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// runArchive handles "archive restore", which reloads archived events into
+// a project for investigation.
+func runArchive(cfg *config.Config, args []string) {
+	usage := "Usage: archive restore -project <id|none> -from YYYY-MM-DD [-to YYYY-MM-DD] [-into <project slug>]"
+	if len(args) == 0 || args[0] != "restore" {
+		log.Fatal(usage)
+	}
+
+	flags := flag.NewFlagSet("archive restore", flag.ExitOnError)
+	project := flags.String("project", "", "project ID the events were archived under, or none")
+	from := flags.String("from", "", "first event date to restore")
+	to := flags.String("to", "", "last event date to restore (default -from)")
+	into := flags.String("into", "", "slug of the project to restore into (default the original project)")
+	flags.Parse(args[1:])
+
+	if *project == "" || *from == "" {
+		log.Fatal(usage)
+	}
+	if *project != archive.NoProject {
+		if _, err := uuid.Parse(*project); err != nil {
+			log.Fatalf("Invalid -project %q: %v", *project, err)
+		}
+	}
+	if *to == "" {
+		*to = *from
+	}
+	fromDate, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	toDate, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		log.Fatalf("Invalid -to: %v", err)
+	}
+
+	store := newArchiveStore(cfg)
+	if store == nil {
+		log.Fatal("ARCHIVE_STORAGE is none; set it to where the archive is")
+	}
+
+	db := openStore(cfg)
+	defer db.Close()
+
+	var target *uuid.UUID
+	if *into != "" {
+		id, err := db.GetProjectIDBySlug(*into)
+		if err != nil {
+			log.Fatalf("Failed to find project %q: %v", *into, err)
+		}
+		target = &id
+	}
+
+	ctx := context.Background()
+	files, err := archive.Files(ctx, store, *project, fromDate, toDate)
+	if err != nil {
+		log.Fatalf("Failed to list archive: %v", err)
+	}
+	if len(files) == 0 {
+		log.Printf("No archived events for project %s from %s to %s", *project, *from, *to)
+		return
+	}
+
+	var read, restored int64
+	for _, file := range files {
+		if file.SHA256 == "" {
+			log.Printf("Warning: %s is not in a manifest and can't be verified", file.Key)
+		}
+		events, err := archive.Read(ctx, store, file)
+		if err != nil {
+			log.Fatalf("Failed to read archive: %v", err)
+		}
+		if *into != "" {
+			for i := range events {
+				events[i].ProjectID = target
+			}
+		}
+		n, err := db.RestoreErrors(events)
+		if err != nil {
+			log.Fatalf("Failed to restore %s: %v", file.Key, err)
+		}
+		read += int64(len(events))
+		restored += n
+		log.Printf("Restored %d of %d events from %s", n, len(events), file.Key)
+	}
+	log.Printf("Restored %d events from %d files (%d were already stored)", restored, len(files), read-restored)
+
+	// The server caches query results; clear them so restored events show up
+	if redisClient, err := redis.NewClient(cfg.RedisURL); err != nil {
+		log.Printf("Warning: failed to connect to Redis to clear caches: %v", err)
+	} else {
+		redisClient.InvalidateAllCache(ctx)
+		redisClient.Close()
+	}
+}
+
+// runRelay serves only the ingestion API, spooling events to disk and
+// forwarding them to RELAY_UPSTREAM_URL. It needs neither Postgres nor Redis.
+func runRelay(cfg *config.Config) {
+	if cfg.RelayUpstreamURL == "" || cfg.RelayUpstreamAPIKey == "" {
+		log.Fatal("RELAY_UPSTREAM_URL and RELAY_UPSTREAM_API_KEY are required in relay mode")
+	}
+	if len(cfg.RelayAPIKeys) == 0 {
+		log.Fatal("RELAY_API_KEYS is required in relay mode")
+	}
+
+	sp, err := spool.New(cfg.RelaySpoolDir, cfg.RelaySpoolMaxMB)
+	if err != nil {
+		log.Fatalf("Failed to open relay spool: %v", err)
+	}
+	rl, err := relay.New(sp, cfg.RelayAPIKeys, cfg.RelaySampleRate, cfg.RelayScrubKeys)
+	if err != nil {
+		log.Fatalf("Failed to create relay: %v", err)
+	}
+	ingestHandler := handlers.NewIngestHandler(rl, newIPResolver(cfg))
+
+	r := newRouter()
+	r.Route("/api", func(r chi.Router) {
+		r.With(handlers.QueryAPIKeyMiddleware(rl)).Post("/reports", ingestHandler.CreateReports)
+
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.IngestAPIKeyMiddleware(rl))
+
+			r.Post("/errors", ingestHandler.CreateError)
+			r.Post("/errors/batch", ingestHandler.CreateErrors)
+		})
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		if err := rl.Run(ctx, cfg.RelayUpstreamURL, cfg.RelayUpstreamAPIKey); err != nil {
+			log.Fatalf("Failed to start relay forwarder: %v", err)
+		}
+	}()
+
+	syslogServer := startSyslog(cfg, rl)
+	defer syslogServer.Close()
+
+	log.Printf("Relay mode: forwarding to %s", cfg.RelayUpstreamURL)
+	serve(&http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: r,
+	})
+
+	cancel()
+	<-forwarded
+}
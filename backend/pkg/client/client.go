@@ -0,0 +1,353 @@
+// Package client sends errors to the error-logs API.
+//
+// Events are buffered in memory and shipped in batches by a background
+// goroutine, so capturing an error never blocks on the network. When the
+// buffer is full new events are dropped rather than growing memory.
+//
+//	c, err := client.New(client.Options{
+//		Endpoint: "https://errors.example.com",
+//		APIKey:   os.Getenv("ERROR_LOGS_API_KEY"),
+//	})
+//	defer c.Close(context.Background())
+//
+//	r.Use(c.Middleware)
+//	c.CaptureError(err, map[string]interface{}{"order_id": id})
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Options struct {
+	// Endpoint is the base URL of the service, e.g. http://localhost:8080.
+	Endpoint string
+	APIKey   string
+
+	// Source, Environment and Release are attached to every event. When
+	// empty they are detected from the binary name, the ENVIRONMENT / APP_ENV
+	// variables and RELEASE or the Go build info respectively.
+	Source      string
+	Environment string
+	Release     string
+
+	// Passthrough sends events exactly as given, without the tags above.
+	// Used when forwarding events that were captured elsewhere.
+	Passthrough bool
+
+	BufferSize    int           // events held in memory, default 1000
+	BatchSize     int           // events per request, default 50 (server max 100)
+	FlushInterval time.Duration // default 5s
+
+	MaxRetries     int           // default 3, negative disables retries
+	InitialBackoff time.Duration // default 500ms
+	MaxBackoff     time.Duration // default 30s
+
+	HTTPClient *http.Client
+
+	// OnError is called when a batch could not be delivered. Defaults to
+	// writing to stderr.
+	OnError func(err error, events int)
+}
+
+type Client struct {
+	opts Options
+
+	queue    chan wireEvent
+	flushReq chan chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	closeOnce sync.Once
+	dropped   atomic.Int64
+}
+
+var ErrClosed = errors.New("client: closed")
+
+func New(opts Options) (*Client, error) {
+	if opts.Endpoint == "" {
+		return nil, fmt.Errorf("client: endpoint is required")
+	}
+	if opts.APIKey == "" {
+		return nil, fmt.Errorf("client: API key is required")
+	}
+	opts.Endpoint = strings.TrimRight(opts.Endpoint, "/")
+
+	if opts.Source == "" {
+		opts.Source = filepath.Base(os.Args[0])
+	}
+	if opts.Environment == "" {
+		opts.Environment = firstEnv("ENVIRONMENT", "APP_ENV", "GO_ENV")
+	}
+	if opts.Release == "" {
+		opts.Release = detectRelease()
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1000
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > 100 {
+		opts.BatchSize = 50
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = 5 * time.Second
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	} else if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = 500 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 30 * time.Second
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error, events int) {
+			fmt.Fprintf(os.Stderr, "error-logs client: dropped %d events: %v\n", events, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		opts:     opts,
+		queue:    make(chan wireEvent, opts.BufferSize),
+		flushReq: make(chan chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	go c.run()
+	return c, nil
+}
+
+// Capture queues an event. It returns false if the event was dropped because
+// the buffer is full or the client is closed.
+func (c *Client) Capture(event *Event) bool {
+	if event == nil || event.Message == "" {
+		return false
+	}
+	wire := c.toWire(event)
+
+	select {
+	case <-c.done:
+		c.dropped.Add(1)
+		return false
+	default:
+	}
+
+	select {
+	case c.queue <- wire:
+		return true
+	default:
+		c.dropped.Add(1)
+		return false
+	}
+}
+
+// CaptureError queues err at level "error" with the caller's stack trace.
+func (c *Client) CaptureError(err error, context map[string]interface{}) bool {
+	if err == nil {
+		return false
+	}
+	return c.Capture(&Event{
+		Level:      "error",
+		Message:    err.Error(),
+		StackTrace: string(debug.Stack()),
+		Context:    context,
+	})
+}
+
+func (c *Client) CaptureMessage(level, message string, context map[string]interface{}) bool {
+	return c.Capture(&Event{
+		Level:   level,
+		Message: message,
+		Context: context,
+	})
+}
+
+// SendError is returned by Send when some events were not delivered. The
+// others were stored and must not be sent again.
+type SendError struct {
+	// Failed holds the indexes of the undelivered events in the slice
+	// passed to Send, in order.
+	Failed []int
+	// Unsent is how many of the last entries in Failed were never
+	// attempted because an earlier batch failed; Err doesn't apply to them.
+	Unsent int
+	Err    error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("%d events not delivered: %v", len(e.Failed), e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Send delivers events synchronously, bypassing the buffer but using the same
+// batching and retry policy. It is meant for callers that keep their own
+// durable queue and need to know whether delivery succeeded. Batches are
+// sent in order and sending stops at the first one that fails; the
+// returned *SendError says which events to keep.
+func (c *Client) Send(ctx context.Context, events []Event) error {
+	for start := 0; start < len(events); start += c.opts.BatchSize {
+		end := min(start+c.opts.BatchSize, len(events))
+		batch := make([]wireEvent, 0, end-start)
+		for i := range events[start:end] {
+			batch = append(batch, c.toWire(&events[start+i]))
+		}
+
+		failed, err := c.post(ctx, batch)
+		if err != nil {
+			indexes := make([]int, 0, len(failed)+len(events)-end)
+			for _, i := range failed {
+				indexes = append(indexes, start+i)
+			}
+			for i := end; i < len(events); i++ {
+				indexes = append(indexes, i)
+			}
+			return &SendError{Failed: indexes, Unsent: len(events) - end, Err: err}
+		}
+	}
+	return nil
+}
+
+// Dropped reports how many events were discarded because the buffer was full,
+// the client was closed or delivery failed after all retries.
+func (c *Client) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+// Flush sends everything buffered so far and waits for it to be delivered or
+// for ctx to expire.
+func (c *Client) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case c.flushReq <- ack:
+	case <-c.stopped:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes buffered events and stops the background sender. If ctx
+// expires first, in-flight retries are abandoned.
+func (c *Client) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() { close(c.done) })
+
+	select {
+	case <-c.stopped:
+		c.cancel()
+		return nil
+	case <-ctx.Done():
+		c.cancel()
+		<-c.stopped
+		return ctx.Err()
+	}
+}
+
+func (c *Client) run() {
+	defer close(c.stopped)
+
+	ticker := time.NewTicker(c.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]wireEvent, 0, c.opts.BatchSize)
+	for {
+		select {
+		case event := <-c.queue:
+			batch = append(batch, event)
+			if len(batch) >= c.opts.BatchSize {
+				batch = c.sendBatch(batch)
+			}
+		case <-ticker.C:
+			batch = c.sendBatch(batch)
+		case ack := <-c.flushReq:
+			batch = c.drain(batch)
+			close(ack)
+		case <-c.done:
+			c.drain(batch)
+			return
+		}
+	}
+}
+
+// drain sends the pending batch plus everything currently in the queue.
+func (c *Client) drain(batch []wireEvent) []wireEvent {
+	for {
+		select {
+		case event := <-c.queue:
+			batch = append(batch, event)
+			if len(batch) >= c.opts.BatchSize {
+				batch = c.sendBatch(batch)
+			}
+		default:
+			return c.sendBatch(batch)
+		}
+	}
+}
+
+func (c *Client) sendBatch(batch []wireEvent) []wireEvent {
+	if len(batch) == 0 {
+		return batch
+	}
+	if failed, err := c.post(c.ctx, batch); err != nil {
+		c.dropped.Add(int64(len(failed)))
+		c.opts.OnError(err, len(failed))
+	}
+	return batch[:0]
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func detectRelease() string {
+	if v := firstEnv("RELEASE", "APP_VERSION"); v != "" {
+		return v
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && len(setting.Value) >= 12 {
+			return setting.Value[:12]
+		}
+	}
+	return ""
+}
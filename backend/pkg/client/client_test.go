@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchServer records the messages of every batch it receives and answers
+// with respond, given the batch's messages.
+type batchServer struct {
+	mu      sync.Mutex
+	batches [][]string
+	respond func(w http.ResponseWriter, messages []string)
+}
+
+func (s *batchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var events []map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	messages := make([]string, len(events))
+	for i, e := range events {
+		messages[i], _ = e["message"].(string)
+	}
+	s.mu.Lock()
+	s.batches = append(s.batches, messages)
+	s.mu.Unlock()
+
+	if s.respond != nil {
+		s.respond(w, messages)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func newTestClient(t *testing.T, s *batchServer, opts Options) *Client {
+	t.Helper()
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	opts.Endpoint = server.URL
+	opts.APIKey = "test"
+	opts.InitialBackoff = time.Millisecond
+	opts.MaxBackoff = time.Millisecond
+	opts.OnError = func(error, int) {}
+	c, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close(context.Background()) })
+	return c
+}
+
+func TestCaptureDoesNotModifyEvent(t *testing.T) {
+	s := &batchServer{}
+	c := newTestClient(t, s, Options{Source: "svc", Environment: "prod", Release: "1.0"})
+
+	event := &Event{Message: "boom"}
+	c.Capture(event)
+	if !reflect.DeepEqual(*event, Event{Message: "boom"}) {
+		t.Errorf("Capture modified the event: %+v", *event)
+	}
+
+	events := []Event{{Message: "a"}}
+	if err := c.Send(context.Background(), events); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(events[0], Event{Message: "a"}) {
+		t.Errorf("Send modified the event: %+v", events[0])
+	}
+}
+
+func TestToWireDefaults(t *testing.T) {
+	c := &Client{opts: Options{Source: "svc", Environment: "prod", Release: "1.0"}}
+	got := c.toWire(&Event{Message: "m", Release: "2.0"})
+	want := wireEvent{Level: "error", Message: "m", Source: "svc", Environment: "prod", Release: "2.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("toWire = %+v, want %+v", got, want)
+	}
+
+	c.opts.Passthrough = true
+	if got := c.toWire(&Event{Message: "m"}); !reflect.DeepEqual(got, wireEvent{Message: "m"}) {
+		t.Errorf("toWire with Passthrough = %+v", got)
+	}
+}
+
+func TestWireFormat(t *testing.T) {
+	c := &Client{opts: Options{Passthrough: true}}
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err := json.Marshal(c.toWire(&Event{
+		Level:       "warning",
+		Message:     "m",
+		StackTrace:  "st",
+		Tags:        map[string]string{"k": "v"},
+		Breadcrumbs: []Breadcrumb{{Category: "http", Message: "GET /"}},
+		TraceID:     "t",
+		User:        &User{Email: "a@example.com"},
+		Timestamp:   ts,
+		ClientIP:    "192.0.2.1",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"level":"warning","message":"m","stack_trace":"st","tags":{"k":"v"},` +
+		`"breadcrumbs":[{"category":"http","message":"GET /"}],"trace_id":"t",` +
+		`"user":{"email":"a@example.com"},"timestamp":"2025-01-02T03:04:05Z","client_ip":"192.0.2.1"}`
+	if string(data) != want {
+		t.Errorf("wire format\n got %s\nwant %s", data, want)
+	}
+}
+
+// A partly stored batch must only have its failed items resent.
+func TestPartialBatchRetriesFailedItems(t *testing.T) {
+	attempts := 0
+	s := &batchServer{respond: func(w http.ResponseWriter, messages []string) {
+		attempts++
+		if attempts > 1 {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"accepted": 2,
+			"failed":   []map[string]interface{}{{"index": 1, "error": "x"}},
+		})
+	}}
+	c := newTestClient(t, s, Options{})
+
+	if err := c.Send(context.Background(), []Event{{Message: "a"}, {Message: "b"}, {Message: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"a", "b", "c"}, {"b"}}
+	if !reflect.DeepEqual(s.batches, want) {
+		t.Errorf("batches = %v, want %v", s.batches, want)
+	}
+}
+
+func TestSendErrorListsUndelivered(t *testing.T) {
+	s := &batchServer{respond: func(w http.ResponseWriter, messages []string) {
+		switch messages[0] {
+		case "a": // first batch stored
+			w.WriteHeader(http.StatusCreated)
+		case "c": // second batch partly stored, then the server goes down
+			w.WriteHeader(http.StatusMultiStatus)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"failed": []map[string]interface{}{{"index": len(messages) - 1}},
+			})
+		default:
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}}
+	c := newTestClient(t, s, Options{BatchSize: 2, MaxRetries: 1})
+
+	events := []Event{{Message: "a"}, {Message: "b"}, {Message: "c"}, {Message: "d"}, {Message: "e"}}
+	err := c.Send(context.Background(), events)
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) {
+		t.Fatalf("Send error = %v, want *SendError", err)
+	}
+	// d failed twice; e was never attempted
+	if want := []int{3, 4}; !reflect.DeepEqual(sendErr.Failed, want) || sendErr.Unsent != 1 {
+		t.Errorf("Failed, Unsent = %v, %d, want %v, 1", sendErr.Failed, sendErr.Unsent, want)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Send error = %v, want the last attempt's StatusError", err)
+	}
+	want := [][]string{{"a", "b"}, {"c", "d"}, {"d"}}
+	if !reflect.DeepEqual(s.batches, want) {
+		t.Errorf("batches = %v, want %v", s.batches, want)
+	}
+}
+
+func TestCaptureDeliversBatch(t *testing.T) {
+	s := &batchServer{}
+	c := newTestClient(t, s, Options{FlushInterval: time.Hour})
+
+	c.CaptureMessage("info", "one", nil)
+	c.CaptureError(errors.New("two"), map[string]interface{}{"k": 1})
+	if err := c.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"one", "two"}}; !reflect.DeepEqual(s.batches, want) {
+		t.Errorf("batches = %v, want %v", s.batches, want)
+	}
+}
@@ -0,0 +1,145 @@
+package client
+
+import "time"
+
+// Event is an error or message to report. Empty fields are left out;
+// Level, Source, Environment and Release default to the client's Options
+// unless it is in Passthrough mode.
+type Event struct {
+	Level       string // error, warning, info or debug
+	Message     string
+	StackTrace  string
+	Context     map[string]interface{}
+	Source      string
+	Environment string
+	URL         string
+	Release     string
+	Tags        map[string]string
+	Breadcrumbs []Breadcrumb
+
+	TraceID   string
+	SpanID    string
+	RequestID string
+
+	// User who hit the error, counted towards the issue's affected users
+	User *User
+
+	// Timestamp is when the event happened; zero means when the server
+	// receives it.
+	Timestamp time.Time
+
+	// ClientIP is the address the event came from, for proxies such as
+	// relays that send events on behalf of others. The server ignores it
+	// unless the sender is one of its trusted proxies.
+	ClientIP string
+}
+
+// Breadcrumb is something that happened before an error, such as a request
+// or a navigation.
+type Breadcrumb struct {
+	Timestamp time.Time
+	Category  string
+	Level     string
+	Message   string
+	Data      map[string]interface{}
+}
+
+// User identifies the user who hit an error; any one field is enough.
+type User struct {
+	ID        string
+	Email     string
+	Username  string
+	IPAddress string
+}
+
+// wireEvent is an Event as POST /api/errors/batch expects it.
+type wireEvent struct {
+	Level       string                 `json:"level"`
+	Message     string                 `json:"message"`
+	StackTrace  string                 `json:"stack_trace,omitempty"`
+	Context     map[string]interface{} `json:"context,omitempty"`
+	Source      string                 `json:"source,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	URL         string                 `json:"url,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Breadcrumbs []wireBreadcrumb       `json:"breadcrumbs,omitempty"`
+	TraceID     string                 `json:"trace_id,omitempty"`
+	SpanID      string                 `json:"span_id,omitempty"`
+	RequestID   string                 `json:"request_id,omitempty"`
+	User        *wireUser              `json:"user,omitempty"`
+	Timestamp   *time.Time             `json:"timestamp,omitempty"`
+	ClientIP    string                 `json:"client_ip,omitempty"`
+}
+
+type wireBreadcrumb struct {
+	Timestamp *time.Time             `json:"timestamp,omitempty"`
+	Category  string                 `json:"category,omitempty"`
+	Level     string                 `json:"level,omitempty"`
+	Message   string                 `json:"message,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+type wireUser struct {
+	ID        string `json:"id,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Username  string `json:"username,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+}
+
+// toWire converts an event, filling in defaults. The caller's event is
+// never modified.
+func (c *Client) toWire(e *Event) wireEvent {
+	w := wireEvent{
+		Level:       e.Level,
+		Message:     e.Message,
+		StackTrace:  e.StackTrace,
+		Context:     e.Context,
+		Source:      e.Source,
+		Environment: e.Environment,
+		URL:         e.URL,
+		Release:     e.Release,
+		Tags:        e.Tags,
+		TraceID:     e.TraceID,
+		SpanID:      e.SpanID,
+		RequestID:   e.RequestID,
+		Timestamp:   timePtr(e.Timestamp),
+		ClientIP:    e.ClientIP,
+	}
+	for _, b := range e.Breadcrumbs {
+		w.Breadcrumbs = append(w.Breadcrumbs, wireBreadcrumb{
+			Timestamp: timePtr(b.Timestamp),
+			Category:  b.Category,
+			Level:     b.Level,
+			Message:   b.Message,
+			Data:      b.Data,
+		})
+	}
+	if e.User != nil {
+		w.User = &wireUser{ID: e.User.ID, Email: e.User.Email, Username: e.User.Username, IPAddress: e.User.IPAddress}
+	}
+
+	if c.opts.Passthrough {
+		return w
+	}
+	if w.Level == "" {
+		w.Level = "error"
+	}
+	if w.Source == "" {
+		w.Source = c.opts.Source
+	}
+	if w.Environment == "" {
+		w.Environment = c.opts.Environment
+	}
+	if w.Release == "" {
+		w.Release = c.opts.Release
+	}
+	return w
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
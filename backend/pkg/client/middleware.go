@@ -0,0 +1,74 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"error-logs/internal/tracecontext"
+)
+
+// Middleware recovers panics from downstream handlers, reports them with the
+// goroutine's stack trace and responds with 500. It is a standard net/http
+// middleware and can be installed with chi's r.Use; when running under chi
+// the route pattern and request ID are attached to the event, along with the
+// trace from an incoming traceparent header.
+func (c *Client) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// Let net/http handle deliberate aborts as usual.
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			c.capturePanic(rec, debug.Stack(), r)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (c *Client) capturePanic(rec interface{}, stack []byte, r *http.Request) {
+	context := map[string]interface{}{
+		"panic":  fmt.Sprintf("%v", rec),
+		"method": r.Method,
+		"path":   r.URL.Path,
+	}
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+			context["route"] = pattern
+		}
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	url := scheme + "://" + r.Host + r.URL.RequestURI()
+
+	message := fmt.Sprintf("panic: %v", rec)
+	if err, ok := rec.(error); ok {
+		message = "panic: " + err.Error()
+	}
+
+	event := &Event{
+		Level:      "error",
+		Message:    message,
+		StackTrace: string(stack),
+		Context:    context,
+		URL:        url,
+		RequestID:  middleware.GetReqID(r.Context()),
+	}
+	if traceID, spanID, ok := tracecontext.Parse(r.Header.Get(tracecontext.Header)); ok {
+		event.TraceID, event.SpanID = traceID, spanID
+	}
+	c.Capture(event)
+}
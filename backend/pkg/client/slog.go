@@ -0,0 +1,220 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+type SlogHandlerOptions struct {
+	// Level is the minimum level sent to the service. Defaults to slog.LevelError.
+	Level slog.Leveler
+}
+
+// SlogHandler forwards log records at or above a level to the service while
+// passing every record on to a wrapped handler, so local logging is unchanged.
+//
+//	logger := slog.New(client.NewSlogHandler(c, slog.NewJSONHandler(os.Stderr, nil), nil))
+type SlogHandler struct {
+	client *Client
+	next   slog.Handler
+	level  slog.Leveler
+
+	attrs  []groupedAttr
+	groups []string
+}
+
+// groupedAttr remembers the groups that were open when WithAttrs was called.
+type groupedAttr struct {
+	groups []string
+	attr   slog.Attr
+}
+
+// NewSlogHandler wraps next; a nil next discards local output.
+func NewSlogHandler(c *Client, next slog.Handler, opts *SlogHandlerOptions) *SlogHandler {
+	if next == nil {
+		next = slog.DiscardHandler
+	}
+	var level slog.Leveler = slog.LevelError
+	if opts != nil && opts.Level != nil {
+		level = opts.Level
+	}
+	return &SlogHandler{client: c, next: next, level: level}
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level() || h.next.Enabled(ctx, level)
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	if h.next.Enabled(ctx, r.Level) {
+		err = h.next.Handle(ctx, r)
+	}
+	if r.Level >= h.level.Level() {
+		h.client.Capture(h.toEvent(r))
+	}
+	return err
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := *h
+	clone.next = h.next.WithAttrs(attrs)
+	clone.attrs = make([]groupedAttr, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(clone.attrs, h.attrs)
+	for _, a := range attrs {
+		clone.attrs = append(clone.attrs, groupedAttr{groups: h.groups, attr: a})
+	}
+	return &clone
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	clone.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &clone
+}
+
+func (h *SlogHandler) toEvent(r slog.Record) *Event {
+	context := make(map[string]interface{})
+	for _, ga := range h.attrs {
+		addAttrs(context, ga.groups, ga.attr)
+	}
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	addAttrs(context, h.groups, attrs...)
+
+	event := &Event{
+		Level:   slogLevel(r.Level),
+		Message: r.Message,
+		Context: context,
+	}
+
+	if r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		frame, _ := frames.Next()
+		event.StackTrace = fmt.Sprintf("%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+	}
+	return event
+}
+
+func slogLevel(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "error"
+	case level >= slog.LevelWarn:
+		return "warning"
+	case level >= slog.LevelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// addAttrs adds attrs under a group path. Groups are only created when
+// something ends up in them, as slog's built-in handlers leave out empty
+// groups.
+func addAttrs(root map[string]interface{}, groups []string, attrs ...slog.Attr) {
+	m := make(map[string]interface{})
+	for _, a := range attrs {
+		addAttr(m, a)
+	}
+	if len(m) > 0 {
+		merge(groupMap(root, groups), m)
+	}
+}
+
+// merge copies src into dst, combining groups present in both.
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		child, ok := v.(map[string]interface{})
+		existing, exists := dst[k].(map[string]interface{})
+		if ok && exists {
+			merge(existing, child)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// groupMap returns the nested map for a group path, creating it as needed.
+func groupMap(root map[string]interface{}, groups []string) map[string]interface{} {
+	m := root
+	for _, g := range groups {
+		child, ok := m[g].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			m[g] = child
+		}
+		m = child
+	}
+	return m
+}
+
+func addAttr(m map[string]interface{}, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		// Groups with an empty key are inlined, as slog's built-in handlers do.
+		var groups []string
+		if a.Key != "" {
+			groups = []string{a.Key}
+		}
+		addAttrs(m, groups, a.Value.Group()...)
+		return
+	}
+
+	m[a.Key] = attrValue(a.Value)
+}
+
+// attrValue converts a value to something encoding/json can always marshal,
+// since one bad attribute must not make the whole batch undeliverable.
+func attrValue(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		// JSON has no NaN or infinity
+		f := v.Float64()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return f
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	}
+
+	any := v.Any()
+	if err, ok := any.(error); ok {
+		return err.Error()
+	}
+	if _, err := json.Marshal(any); err != nil {
+		return fmt.Sprintf("%+v", any)
+	}
+	return any
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSlogHandlerContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(h slog.Handler) slog.Handler
+		attrs   []slog.Attr
+		want    map[string]interface{}
+	}{
+		{
+			name:    "no attributes",
+			handler: func(h slog.Handler) slog.Handler { return h },
+			want:    map[string]interface{}{},
+		},
+		{
+			name:    "open group without attributes",
+			handler: func(h slog.Handler) slog.Handler { return h.WithGroup("req") },
+			want:    map[string]interface{}{},
+		},
+		{
+			name:    "empty group attribute",
+			handler: func(h slog.Handler) slog.Handler { return h },
+			attrs:   []slog.Attr{slog.Group("g"), slog.Group("h", slog.Group("i"))},
+			want:    map[string]interface{}{},
+		},
+		{
+			name:    "inlined group",
+			handler: func(h slog.Handler) slog.Handler { return h },
+			attrs:   []slog.Attr{slog.Group("", slog.Int("a", 1))},
+			want:    map[string]interface{}{"a": int64(1)},
+		},
+		{
+			name: "handler and record attributes in the same group",
+			handler: func(h slog.Handler) slog.Handler {
+				return h.WithGroup("req").WithAttrs([]slog.Attr{slog.String("id", "r1")})
+			},
+			attrs: []slog.Attr{slog.String("path", "/")},
+			want:  map[string]interface{}{"req": map[string]interface{}{"id": "r1", "path": "/"}},
+		},
+		{
+			name:    "non-finite floats",
+			handler: func(h slog.Handler) slog.Handler { return h },
+			attrs: []slog.Attr{
+				slog.Float64("nan", math.NaN()),
+				slog.Float64("inf", math.Inf(1)),
+				slog.Float64("ninf", math.Inf(-1)),
+				slog.Any("any", math.Inf(1)),
+				slog.Float64("ok", 1.5),
+			},
+			want: map[string]interface{}{"nan": "NaN", "inf": "+Inf", "ninf": "-Inf", "any": "+Inf", "ok": 1.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.handler(NewSlogHandler(&Client{}, nil, nil)).(*SlogHandler)
+			r := slog.NewRecord(time.Now(), slog.LevelError, "msg", 0)
+			r.AddAttrs(tt.attrs...)
+
+			event := h.toEvent(r)
+			if !reflect.DeepEqual(event.Context, tt.want) {
+				t.Errorf("Context = %#v, want %#v", event.Context, tt.want)
+			}
+			if _, err := json.Marshal((&Client{}).toWire(event)); err != nil {
+				t.Errorf("event does not marshal: %v", err)
+			}
+		})
+	}
+}
+
+func TestSlogHandlerLevel(t *testing.T) {
+	h := NewSlogHandler(&Client{}, nil, &SlogHandlerOptions{Level: slog.LevelWarn})
+	if h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("Enabled(Info) = true with a discarding next handler")
+	}
+	if !h.Enabled(context.Background(), slog.LevelWarn) {
+		t.Error("Enabled(Warn) = false")
+	}
+}
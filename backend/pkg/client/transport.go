@@ -0,0 +1,143 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError is returned when the server rejects a batch.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Body)
+}
+
+// post delivers a batch, retrying network errors, 429 and 5xx responses with
+// exponential backoff. A Retry-After header overrides the computed delay.
+// When the server stores only part of a batch, only the rest is resent. It
+// returns the indexes of the events that were not delivered.
+func (c *Client) post(ctx context.Context, batch []wireEvent) ([]int, error) {
+	pending := make([]int, len(batch))
+	for i := range pending {
+		pending[i] = i
+	}
+
+	for attempt := 0; ; attempt++ {
+		events := make([]wireEvent, len(pending))
+		for i, index := range pending {
+			events[i] = batch[index]
+		}
+		body, err := json.Marshal(events)
+		if err != nil {
+			return pending, fmt.Errorf("failed to marshal batch: %w", err)
+		}
+
+		failed, retryAfter, retryable, err := c.do(ctx, body)
+		if err == nil {
+			return nil, nil
+		}
+		if failed != nil {
+			remaining := make([]int, 0, len(failed))
+			for _, i := range failed {
+				if i >= 0 && i < len(pending) {
+					remaining = append(remaining, pending[i])
+				}
+			}
+			pending = remaining
+		}
+		if !retryable || attempt >= c.opts.MaxRetries {
+			return pending, err
+		}
+
+		wait := c.backoff(attempt)
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return pending, err
+		}
+	}
+}
+
+// batchResult is the body of a 207 response to a partly stored batch.
+type batchResult struct {
+	Failed []struct {
+		Index int `json:"index"`
+	} `json:"failed"`
+}
+
+// do sends one request. On a 207 response it returns the indexes of the
+// items the server failed to store; a nil slice means none were stored.
+func (c *Client) do(ctx context.Context, body []byte) ([]int, time.Duration, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.opts.Endpoint+"/api/errors/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", c.opts.APIKey)
+	req.Header.Set("User-Agent", "error-logs-go-client")
+
+	resp, err := c.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, ctx.Err() == nil, fmt.Errorf("failed to send batch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMultiStatus {
+		var result batchResult
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil || len(result.Failed) == 0 {
+			// We can't tell which items were stored, so everything is
+			// resent: a duplicate is better than a lost event
+			return nil, 0, true, fmt.Errorf("unreadable partial batch response: %v", err)
+		}
+		failed := make([]int, len(result.Failed))
+		for i, f := range result.Failed {
+			failed[i] = f.Index
+		}
+		err := &StatusError{StatusCode: resp.StatusCode, Body: fmt.Sprintf("%d events were not stored", len(failed))}
+		return failed, parseRetryAfter(resp.Header.Get("Retry-After")), true, err
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 300 {
+		return nil, 0, false, nil
+	}
+
+	err = &StatusError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(msg))}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return nil, parseRetryAfter(resp.Header.Get("Retry-After")), retryable, err
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.opts.InitialBackoff << attempt
+	if d <= 0 || d > c.opts.MaxBackoff {
+		d = c.opts.MaxBackoff
+	}
+	// Full jitter between d/2 and d so clients don't retry in lockstep.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
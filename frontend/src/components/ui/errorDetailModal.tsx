@@ -79,6 +79,7 @@ export function ErrorDetailModal({
 }: ErrorDetailModalProps) {
   const [showStackTrace, setShowStackTrace] = useState(false);
   const [showContext, setShowContext] = useState(false);
+  const [showBreadcrumbs, setShowBreadcrumbs] = useState(true);
 
   const {
     data: error,
@@ -352,6 +353,91 @@ export function ErrorDetailModal({
                 </Card>
               )}
 
+              {/* Breadcrumbs */}
+              {error.breadcrumbs && error.breadcrumbs.length > 0 && (
+                <Card>
+                  <CardHeader>
+                    <CardTitle className="flex items-center justify-between">
+                      <span className="text-sm">
+                        Breadcrumbs ({error.breadcrumbs.length})
+                      </span>
+                      <Button
+                        size="sm"
+                        variant="outline"
+                        onClick={() => setShowBreadcrumbs(!showBreadcrumbs)}
+                      >
+                        {showBreadcrumbs ? (
+                          <EyeOff className="h-3 w-3" />
+                        ) : (
+                          <Eye className="h-3 w-3" />
+                        )}
+                        {showBreadcrumbs ? "Hide" : "Show"}
+                      </Button>
+                    </CardTitle>
+                  </CardHeader>
+                  {showBreadcrumbs && (
+                    <CardContent>
+                      <ol className="space-y-2 text-xs">
+                        {error.breadcrumbs.map((crumb, i) => (
+                          <li
+                            key={i}
+                            className="flex gap-3 border-b last:border-0 pb-2"
+                          >
+                            <span className="text-gray-500 whitespace-nowrap">
+                              {crumb.timestamp
+                                ? new Date(crumb.timestamp).toLocaleTimeString()
+                                : "—"}
+                            </span>
+                            {crumb.category && (
+                              <Badge variant="outline">{crumb.category}</Badge>
+                            )}
+                            <div className="flex-1 min-w-0">
+                              <p className="break-words">{crumb.message}</p>
+                              {crumb.data && (
+                                <pre className="mt-1 bg-gray-100 dark:bg-gray-800 p-2 rounded overflow-x-auto">
+                                  {JSON.stringify(crumb.data, null, 2)}
+                                </pre>
+                              )}
+                            </div>
+                          </li>
+                        ))}
+                      </ol>
+                    </CardContent>
+                  )}
+                </Card>
+              )}
+
+              {/* User Feedback */}
+              {error.feedback && error.feedback.length > 0 && (
+                <Card>
+                  <CardHeader>
+                    <CardTitle className="text-sm">
+                      User Feedback ({error.feedback.length})
+                    </CardTitle>
+                  </CardHeader>
+                  <CardContent>
+                    <ul className="space-y-3 text-sm">
+                      {error.feedback.map((item) => (
+                        <li key={item.id} className="border-b last:border-0 pb-3">
+                          <div className="flex items-center gap-2 text-xs text-gray-500">
+                            <User className="h-3 w-3" />
+                            <span>
+                              {item.name || item.email || "Anonymous"}
+                              {item.name && item.email && ` <${item.email}>`}
+                            </span>
+                            <span>·</span>
+                            <span>{new Date(item.created_at).toLocaleString()}</span>
+                          </div>
+                          <p className="mt-1 whitespace-pre-wrap break-words">
+                            {item.comments}
+                          </p>
+                        </li>
+                      ))}
+                    </ul>
+                  </CardContent>
+                </Card>
+              )}
+
               {/* Context */}
               {error.context && Object.keys(error.context).length > 0 && (
                 <Card>
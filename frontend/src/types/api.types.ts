@@ -11,6 +11,12 @@ export interface BackendError {
   user_agent?: string;
   ip_address?: string;
   url?: string;
+  tags?: Record<string, string>;
+  trace_id?: string;
+  span_id?: string;
+  request_id?: string;
+  user?: EventUser;
+  affected_users?: number;
   fingerprint?: string;
   resolved: boolean;
   count: number;
@@ -18,6 +24,57 @@ export interface BackendError {
   last_seen: string;
   created_at: string;
   updated_at: string;
+  breadcrumbs?: Breadcrumb[];
+  feedback?: Feedback[];
+  attachments?: Attachment[];
+  frames?: StackFrame[];
+}
+
+export interface EventUser {
+  id?: string;
+  email?: string;
+  username?: string;
+  ip_address?: string;
+}
+
+export interface Feedback {
+  id: string;
+  event_id: string;
+  name?: string;
+  email?: string;
+  comments: string;
+  created_at: string;
+}
+
+export interface Attachment {
+  id: string;
+  event_id: string;
+  name: string;
+  content_type: string;
+  size: number;
+  created_at: string;
+}
+
+export interface StackFrame {
+  function?: string;
+  file: string;
+  line: number;
+  column: number;
+  resolved?: {
+    function?: string;
+    file: string;
+    line: number;
+    column: number;
+    context_line?: string;
+  };
+}
+
+export interface Breadcrumb {
+  timestamp?: string;
+  category?: string;
+  level?: string;
+  message?: string;
+  data?: Record<string, any>;
 }
 
 export interface ErrorsResponse {